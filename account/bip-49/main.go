@@ -2,92 +2,68 @@ package main
 
 import (
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 
+	"go-btc/hdwallet"
 	"go-btc/helper"
+	"go-btc/network"
+)
 
-	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/btcutil/hdkeychain"
-	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/txscript"
-	"github.com/tyler-smith/go-bip39"
+var (
+	account = flag.Uint("account", 0, "账户索引")
+	change  = flag.Uint("change", 0, "链类型: 0 收款, 1 找零")
+	index   = flag.Uint("index", 0, "地址索引")
+	secrets = helper.RegisterSecretFlags()
+	netOpts = helper.RegisterNetworkFlags()
 )
 
 func main() {
-	mnemonic, err := helper.GetMnemonicFromENV()
+	flag.Parse()
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
 	if err != nil {
 		log.Fatalf("获取助记词失败: %v", err)
 	}
 
 	// 生成比特币地址
-	wif, publicKey, p2shAddress, err := generateBIP49Address(mnemonic)
+	key, fingerprint, err := generateBIP49Address(mnemonic, passphrase, net)
 	if err != nil {
 		log.Fatalf("生成BIP-49地址失败: %v", err)
 	}
 
 	// 输出结果
-	printResults(mnemonic, wif, publicKey, p2shAddress)
+	printResults(mnemonic, fingerprint, key)
 }
 
-func generateBIP49Address(mnemonic string) (*btcutil.WIF, *btcec.PublicKey, *btcutil.AddressScriptHash, error) {
-	seed := bip39.NewSeed(mnemonic, "")
-
-	masterKey, err := hdkeychain.NewMaster(seed, &chaincfg.MainNetParams)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建主私钥失败: %w", err)
-	}
-
-	path := []uint32{
-		49 + hdkeychain.HardenedKeyStart,
-		0 + hdkeychain.HardenedKeyStart,
-		0 + hdkeychain.HardenedKeyStart,
-		0,
-		0,
-	}
-
-	key := masterKey
-	for _, childNum := range path {
-		key, err = key.Derive(childNum)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("派生密钥失败: %w", err)
-		}
-	}
-
-	privateKey, err := key.ECPrivKey()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("获取私钥失败: %w", err)
-	}
-
-	wif, err := btcutil.NewWIF(privateKey, &chaincfg.MainNetParams, true)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建WIF失败: %w", err)
-	}
-
-	publicKey := privateKey.PubKey()
-	pubKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
-
-	redeemScript, err := txscript.NewScriptBuilder().
-		AddOp(txscript.OP_0).
-		AddData(pubKeyHash).
-		Script()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建赎回脚本失败: %w", err)
-	}
-
-	p2shAddress, err := btcutil.NewAddressScriptHash(redeemScript, &chaincfg.MainNetParams)
+func generateBIP49Address(mnemonic, passphrase string, net *network.Network) (*hdwallet.Key, uint32, error) {
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建P2SH地址失败: %w", err)
+		return nil, 0, err
 	}
 
-	return wif, publicKey, p2shAddress, nil
+	key, err := deriver.Derive(hdwallet.Path{
+		Purpose:  hdwallet.PurposeBIP49,
+		CoinType: net.CoinType(),
+		Account:  uint32(*account),
+		Change:   uint32(*change),
+		Index:    uint32(*index),
+	})
+	return key, deriver.MasterFingerprint(), err
 }
 
-func printResults(mnemonic string, wif *btcutil.WIF, publicKey *btcec.PublicKey, p2shAddress *btcutil.AddressScriptHash) {
+func printResults(mnemonic string, fingerprint uint32, key *hdwallet.Key) {
 	fmt.Println("助记词:", mnemonic)
-	fmt.Printf("私钥 (WIF): %s\n", wif.String())
-	fmt.Printf("公钥 (压缩格式): %s\n", hex.EncodeToString(publicKey.SerializeCompressed()))
-	fmt.Printf("公钥 (非压缩格式): %s\n", hex.EncodeToString(publicKey.SerializeUncompressed()))
-	fmt.Println("P2SH 地址:", p2shAddress.EncodeAddress())
+	fmt.Println("主密钥指纹:", hdwallet.FormatFingerprint(fingerprint))
+	fmt.Println("派生路径:", key.Path)
+	fmt.Printf("私钥 (WIF): %s\n", key.WIF.String())
+	fmt.Printf("公钥 (压缩格式): %s\n", hex.EncodeToString(key.PubKey.SerializeCompressed()))
+	fmt.Printf("公钥 (非压缩格式): %s\n", hex.EncodeToString(key.PubKey.SerializeUncompressed()))
+	fmt.Println("P2SH 地址:", key.Address.EncodeAddress())
 }
@@ -2,85 +2,68 @@ package main
 
 import (
 	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 
+	"go-btc/hdwallet"
 	"go-btc/helper"
-
-	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/btcutil/hdkeychain"
-	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/tyler-smith/go-bip39"
+	"go-btc/network"
 )
 
-var NetParams = &chaincfg.TestNet3Params
+var (
+	account = flag.Uint("account", 0, "账户索引")
+	change  = flag.Uint("change", 0, "链类型: 0 收款, 1 找零")
+	index   = flag.Uint("index", 0, "地址索引")
+	secrets = helper.RegisterSecretFlags()
+	netOpts = helper.RegisterNetworkFlags()
+)
 
 func main() {
-	mnemonic, err := helper.GetMnemonicFromENV()
+	flag.Parse()
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
 	if err != nil {
 		log.Fatalf("获取助记词失败: %v", err)
 	}
 
 	// 生成比特币地址
-	wif, publicKey, bech32Address, err := generateBIP84Address(mnemonic)
+	key, fingerprint, err := generateBIP84Address(mnemonic, passphrase, net)
 	if err != nil {
 		log.Fatalf("生成BIP-84地址失败: %v", err)
 	}
 
 	// 输出结果
-	printResults(mnemonic, wif, publicKey, bech32Address)
+	printResults(mnemonic, fingerprint, key)
 }
 
-func generateBIP84Address(mnemonic string) (*btcutil.WIF, *btcec.PublicKey, btcutil.Address, error) {
-	seed := bip39.NewSeed(mnemonic, "")
-
-	masterKey, err := hdkeychain.NewMaster(seed, NetParams)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建主私钥失败: %w", err)
-	}
-
-	path := []uint32{
-		84 + hdkeychain.HardenedKeyStart, // purpose
-		0 + hdkeychain.HardenedKeyStart,  // coin type
-		0 + hdkeychain.HardenedKeyStart,  // account
-		0,                                // external chain
-		0,                                // address index
-	}
-
-	key := masterKey
-	for _, childNum := range path {
-		key, err = key.Derive(childNum)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("派生密钥失败: %w", err)
-		}
-	}
-
-	privateKey, err := key.ECPrivKey()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("获取私钥失败: %w", err)
-	}
-
-	wif, err := btcutil.NewWIF(privateKey, NetParams, true)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建WIF失败: %w", err)
-	}
-
-	publicKey := privateKey.PubKey()
-	pubKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
-
-	bech32Address, err := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, NetParams)
+func generateBIP84Address(mnemonic, passphrase string, net *network.Network) (*hdwallet.Key, uint32, error) {
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建Bech32地址失败: %w", err)
+		return nil, 0, err
 	}
 
-	return wif, publicKey, bech32Address, nil
+	key, err := deriver.Derive(hdwallet.Path{
+		Purpose:  hdwallet.PurposeBIP84, // purpose
+		CoinType: net.CoinType(),        // coin type
+		Account:  uint32(*account),      // account
+		Change:   uint32(*change),       // external chain
+		Index:    uint32(*index),        // address index
+	})
+	return key, deriver.MasterFingerprint(), err
 }
 
-func printResults(mnemonic string, wif *btcutil.WIF, publicKey *btcec.PublicKey, bech32Address btcutil.Address) {
+func printResults(mnemonic string, fingerprint uint32, key *hdwallet.Key) {
 	fmt.Println("助记词:", mnemonic)
-	fmt.Printf("私钥 (WIF): %s\n", wif.String())
-	fmt.Printf("公钥 (压缩格式): %s\n", hex.EncodeToString(publicKey.SerializeCompressed()))
-	fmt.Printf("公钥 (非压缩格式): %s\n", hex.EncodeToString(publicKey.SerializeUncompressed()))
-	fmt.Println("Bech32 地址:", bech32Address.EncodeAddress())
+	fmt.Println("主密钥指纹:", hdwallet.FormatFingerprint(fingerprint))
+	fmt.Println("派生路径:", key.Path)
+	fmt.Printf("私钥 (WIF): %s\n", key.WIF.String())
+	fmt.Printf("公钥 (压缩格式): %s\n", hex.EncodeToString(key.PubKey.SerializeCompressed()))
+	fmt.Printf("公钥 (非压缩格式): %s\n", hex.EncodeToString(key.PubKey.SerializeUncompressed()))
+	fmt.Println("Bech32 地址:", key.Address.EncodeAddress())
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/descriptor"
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/network"
+)
+
+var (
+	account    = flag.Uint("account", 0, "账户索引")
+	secrets    = helper.RegisterSecretFlags()
+	netOpts    = helper.RegisterNetworkFlags()
+	importDesc = flag.String("import", "", "导入描述符并派生地址，不需要助记词")
+	from       = flag.Uint("from", 0, "起始地址索引")
+	count      = flag.Uint("count", 5, "每条链派生的地址数量")
+)
+
+func main() {
+	flag.Parse()
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	if *importDesc != "" {
+		deriveFromDescriptor(*importDesc, net)
+		return
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+
+	// 导出 account/bip-44、bip-49、bip-84 以及 taproot 账户的描述符
+	purposes := []hdwallet.Purpose{
+		hdwallet.PurposeBIP44,
+		hdwallet.PurposeBIP49,
+		hdwallet.PurposeBIP84,
+		hdwallet.PurposeBIP86,
+	}
+	for _, purpose := range purposes {
+		accountKey, err := deriver.AccountPublicKey(purpose, net.CoinType(), uint32(*account))
+		if err != nil {
+			log.Fatalf("导出扩展公钥失败: %v", err)
+		}
+
+		desc, err := descriptor.NewAccount(purpose, deriver.MasterFingerprint(), net.CoinType(), uint32(*account), accountKey, net.Params)
+		if err != nil {
+			log.Fatalf("生成描述符失败: %v", err)
+		}
+		fmt.Printf("%s: %s\n", purpose, desc)
+	}
+}
+
+// deriveFromDescriptor 解析描述符并打印每条链上的地址和锁定脚本
+func deriveFromDescriptor(s string, net *network.Network) {
+	desc, err := descriptor.Parse(s, net.Params)
+	if err != nil {
+		log.Fatalf("解析描述符失败: %v", err)
+	}
+
+	fmt.Println("描述符:", desc)
+	for branch := 0; branch < desc.Key.PathCount(); branch++ {
+		for i := uint32(*from); i < uint32(*from+*count); i++ {
+			out, err := desc.Derive(branch, i)
+			if err != nil {
+				log.Fatalf("派生地址失败: %v", err)
+			}
+			fmt.Printf("[%s]  %s  %s\n", out.Origin, out.Address.EncodeAddress(), hex.EncodeToString(out.PkScript))
+			if !desc.IsRange() {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/keystore"
+	"go-btc/mnemonic"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+var (
+	path            = flag.String("keystore", "wallet.keystore", "keystore 文件路径")
+	passwordFile    = flag.String("password-file", "", "口令文件，不指定时在终端输入")
+	newPasswordFile = flag.String("new-password-file", "", "passwd 命令的新口令文件，不指定时在终端输入")
+	passphraseFrom  = flag.String("passphrase", helper.PassphraseNone, "create 时一并保存的助记词密码来源: none, env, prompt, stdin")
+	generate        = flag.Int("generate", 0, "create 时生成指定位数 (128~256) 的新助记词，为 0 时导入 .env 中的 MNEMONIC")
+)
+
+const usage = `用法: keystore [参数] <命令>
+
+命令:
+  create   创建 keystore，导入 .env 中的助记词或生成新助记词
+  unlock   验证口令并显示主密钥指纹
+  passwd   修改口令
+  export   解密并输出助记词（注意周围环境）
+
+参数:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "create":
+		create()
+	case "unlock":
+		secret := unlock()
+		printFingerprint(secret)
+	case "passwd":
+		changePassword()
+	case "export":
+		secret := unlock()
+		fmt.Fprintln(os.Stderr, "警告: 助记词将以明文显示")
+		fmt.Println("助记词:", secret.Mnemonic)
+		if secret.Passphrase != "" {
+			fmt.Println("助记词密码:", secret.Passphrase)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// create 创建新的 keystore 文件
+func create() {
+	var words string
+	var err error
+	if *generate > 0 {
+		words, err = helper.GenerateMnemonic(*generate, mnemonic.English)
+	} else {
+		words, err = helper.GetMnemonicFromENV()
+	}
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+
+	passphrase, err := helper.GetPassphrase(*passphraseFrom)
+	if err != nil {
+		log.Fatalf("获取助记词密码失败: %v", err)
+	}
+
+	password := newPassword(*passwordFile)
+	secret := &keystore.Secret{Mnemonic: words, Passphrase: passphrase}
+	if err := keystore.Create(*path, secret, password); err != nil {
+		log.Fatalf("创建 keystore 失败: %v", err)
+	}
+
+	fmt.Println("keystore 已创建:", *path)
+	if *generate > 0 {
+		fmt.Println("请抄写并离线保存新助记词:", words)
+	}
+	printFingerprint(secret)
+}
+
+// unlock 读取口令并解密 keystore
+func unlock() *keystore.Secret {
+	var password []byte
+	var err error
+	if *passwordFile != "" {
+		password, err = helper.ReadPasswordFile(*passwordFile)
+	} else {
+		password, err = helper.ReadPassword("请输入 keystore 口令: ")
+	}
+	if err != nil {
+		log.Fatalf("读取口令失败: %v", err)
+	}
+
+	secret, err := keystore.Unlock(*path, password)
+	if err != nil {
+		log.Fatalf("解锁 keystore 失败: %v", err)
+	}
+	return secret
+}
+
+// changePassword 修改 keystore 口令
+func changePassword() {
+	var oldPassword []byte
+	var err error
+	if *passwordFile != "" {
+		oldPassword, err = helper.ReadPasswordFile(*passwordFile)
+	} else {
+		oldPassword, err = helper.ReadPassword("请输入当前口令: ")
+	}
+	if err != nil {
+		log.Fatalf("读取口令失败: %v", err)
+	}
+
+	password := newPassword(*newPasswordFile)
+	if err := keystore.ChangePassword(*path, oldPassword, password); err != nil {
+		log.Fatalf("修改口令失败: %v", err)
+	}
+	fmt.Println("口令已修改")
+}
+
+// newPassword 从文件读取新口令，或在终端输入两次确认
+func newPassword(file string) []byte {
+	if file != "" {
+		password, err := helper.ReadPasswordFile(file)
+		if err != nil {
+			log.Fatalf("读取口令失败: %v", err)
+		}
+		return password
+	}
+
+	password, err := helper.ReadPassword("请输入新口令: ")
+	if err != nil {
+		log.Fatalf("读取口令失败: %v", err)
+	}
+	confirm, err := helper.ReadPassword("请再次输入新口令: ")
+	if err != nil {
+		log.Fatalf("读取口令失败: %v", err)
+	}
+	if !bytes.Equal(password, confirm) {
+		log.Fatal("两次输入的口令不一致")
+	}
+	return password
+}
+
+// printFingerprint 显示主密钥指纹，便于确认 keystore 中保存的是正确的钱包
+func printFingerprint(secret *keystore.Secret) {
+	deriver, err := hdwallet.NewDeriverFromMnemonic(secret.Mnemonic, secret.Passphrase, &chaincfg.MainNetParams)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+	fmt.Println("主密钥指纹:", hdwallet.FormatFingerprint(deriver.MasterFingerprint()))
+}
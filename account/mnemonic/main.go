@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/helper"
+	"go-btc/mnemonic"
+)
+
+var (
+	generate = flag.Bool("generate", false, "生成新的助记词")
+	bits     = flag.Int("bits", 128, "熵的位数: 128、160、192、224、256")
+	lang     = flag.String("lang", string(mnemonic.English), "生成或转换的目标语言")
+	convert  = flag.Bool("convert", false, "将 MNEMONIC 转换为 -lang 指定的语言")
+	secrets  = helper.RegisterSecretFlags()
+)
+
+func main() {
+	flag.Parse()
+
+	target, err := mnemonic.ParseLanguage(*lang)
+	if err != nil {
+		log.Fatalf("解析语言失败: %v", err)
+	}
+
+	if *generate {
+		words, err := helper.GenerateMnemonic(*bits, target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("助记词:", words)
+		return
+	}
+
+	// 校验 .env 或 keystore 中的助记词
+	words, _, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+
+	source, err := mnemonic.Validate(words)
+	if err != nil {
+		log.Fatalf("助记词无效: %v", err)
+	}
+	fmt.Println("助记词有效, 语言:", source)
+
+	if *convert {
+		converted, err := mnemonic.Convert(words, target)
+		if err != nil {
+			log.Fatalf("转换助记词失败: %v", err)
+		}
+		fmt.Printf("%s 助记词: %s\n", target, converted)
+		fmt.Println("注意: 种子由助记词文本计算，转换后的助记词对应不同的钱包，只能在支持按熵恢复的工具中使用")
+	}
+}
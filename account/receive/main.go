@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/walletdb"
+)
+
+var (
+	purpose = flag.Uint("purpose", 86, "地址类型 44/49/84/86")
+	account = flag.Uint("account", 0, "账户索引")
+	label   = flag.String("label", "", "地址标签，例如付款人或用途")
+	list    = flag.Bool("list", false, "列出已分配的收款地址，不分配新地址")
+	dbPath  = flag.String("db", "wallet.json", "钱包数据库文件")
+	secrets = helper.RegisterSecretFlags()
+	netOpts = helper.RegisterNetworkFlags()
+)
+
+func main() {
+	flag.Parse()
+
+	p := hdwallet.Purpose(*purpose)
+	if !p.Valid() {
+		log.Fatalf("不支持的地址类型: %d", *purpose)
+	}
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+
+	db, err := walletdb.Open(*dbPath, net.Name, deriver.MasterFingerprint())
+	if err != nil {
+		log.Fatalf("打开钱包数据库失败: %v", err)
+	}
+	wallet := deriver.Account(p, net.CoinType(), uint32(*account))
+
+	if *list {
+		for _, path := range db.Issued(p, net.CoinType(), uint32(*account), hdwallet.ChainExternal) {
+			key, err := wallet.Derive(path.Change, path.Index)
+			if err != nil {
+				log.Fatalf("派生地址失败: %v", err)
+			}
+			fmt.Printf("%s  %s  %s\n", path, key.Address.EncodeAddress(), db.Label(path))
+		}
+		return
+	}
+
+	// 分配后立即写入数据库，下次运行会得到新的地址
+	path, err := db.Issue(p, net.CoinType(), uint32(*account), hdwallet.ChainExternal, *label)
+	if err != nil {
+		log.Fatalf("分配收款地址失败: %v", err)
+	}
+	key, err := wallet.Derive(path.Change, path.Index)
+	if err != nil {
+		log.Fatalf("派生地址失败: %v", err)
+	}
+	fmt.Println("派生路径:", path)
+	fmt.Println("收款地址:", key.Address.EncodeAddress())
+	if *label != "" {
+		fmt.Println("标签:", *label)
+	}
+}
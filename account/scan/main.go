@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"go-btc/discovery"
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/walletdb"
+)
+
+var (
+	gapLimit = flag.Uint("gap", discovery.DefaultGapLimit, "连续未使用地址数量达到该值时停止扫描")
+	purposes = flag.String("purposes", "44,49,84,86", "要扫描的地址类型，逗号分隔")
+	xpub     = flag.String("xpub", "", "只扫描该账户级扩展公钥对应的账户，不需要助记词")
+	purpose  = flag.Uint("purpose", 0, "配合 -xpub 使用的地址类型，为 0 时根据扩展公钥版本推断")
+	dbPath   = flag.String("db", "", "钱包数据库文件，指定时把发现的已使用地址写入数据库，之后不会再分配这些索引")
+	secrets  = helper.RegisterSecretFlags()
+	netOpts  = helper.RegisterNetworkFlags()
+)
+
+func main() {
+	flag.Parse()
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建 API 客户端失败: %v", err)
+	}
+	scanner := discovery.NewScanner(client, uint32(*gapLimit))
+
+	if *xpub != "" {
+		if *dbPath != "" {
+			log.Fatal("-db 需要助记词，不能与 -xpub 同时使用")
+		}
+		wallet, err := hdwallet.NewWatchOnly(*xpub, hdwallet.Purpose(*purpose), net.Params)
+		if err != nil {
+			log.Fatalf("创建观察钱包失败: %v", err)
+		}
+		external, internal, err := scanner.ScanAccount(wallet)
+		if err != nil {
+			log.Fatalf("扫描账户失败: %v", err)
+		}
+		printAccount(&discovery.AccountResult{
+			Purpose:  wallet.Purpose(),
+			Account:  wallet.Account(),
+			External: *external,
+			Internal: *internal,
+		})
+		return
+	}
+
+	list, err := parsePurposes(*purposes)
+	if err != nil {
+		log.Fatalf("解析地址类型失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+
+	results, err := scanner.Discover(deriver, net.CoinType(), list)
+	if err != nil {
+		log.Fatalf("扫描失败: %v", err)
+	}
+	if *dbPath != "" {
+		if err := recordUsed(*dbPath, net.Name, deriver.MasterFingerprint(), results); err != nil {
+			log.Fatalf("写入钱包数据库失败: %v", err)
+		}
+	}
+	if len(results) == 0 {
+		fmt.Println("没有发现使用过的账户")
+		return
+	}
+
+	var total int64
+	for _, result := range results {
+		printAccount(result)
+		total += result.Balance()
+	}
+	fmt.Printf("\n总余额: %d sat\n", total)
+}
+
+// recordUsed 把扫描到的已使用地址写入钱包数据库
+func recordUsed(path, network string, fingerprint uint32, results []*discovery.AccountResult) error {
+	db, err := walletdb.Open(path, network, fingerprint)
+	if err != nil {
+		return err
+	}
+	var used []hdwallet.Path
+	for _, result := range results {
+		for _, chain := range []discovery.ChainResult{result.External, result.Internal} {
+			for _, u := range chain.Used {
+				used = append(used, u.Key.Path)
+			}
+		}
+	}
+	return db.MarkUsed(used...)
+}
+
+// parsePurposes 解析逗号分隔的地址类型列表
+func parsePurposes(s string) ([]hdwallet.Purpose, error) {
+	var list []hdwallet.Purpose
+	for _, field := range strings.Split(s, ",") {
+		v, err := strconv.ParseUint(strings.TrimSpace(field), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的地址类型 %q", field)
+		}
+		p := hdwallet.Purpose(v)
+		if !p.Valid() {
+			return nil, fmt.Errorf("不支持的地址类型 %d", v)
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+func printAccount(result *discovery.AccountResult) {
+	fmt.Printf("\n账户 %d' (%s)  余额: %d sat\n", result.Account, result.Purpose, result.Balance())
+	for _, chain := range []discovery.ChainResult{result.External, result.Internal} {
+		for _, used := range chain.Used {
+			fmt.Printf("  %d/%d  %s  交易数: %d  余额: %d sat\n",
+				used.Key.Path.Change, used.Key.Path.Index, used.Key.Address.EncodeAddress(), used.TxCount, used.Balance)
+		}
+		fmt.Printf("  链 %d 下一个未使用索引: %d\n", chain.Change, chain.NextIndex)
+	}
+}
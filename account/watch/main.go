@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/hdwallet"
+	"go-btc/helper"
+)
+
+var (
+	xpub    = flag.String("xpub", "", "账户级扩展公钥 (xpub/ypub/zpub/tpub/upub/vpub)")
+	purpose = flag.Uint("purpose", 0, "地址类型 44/49/84/86，为 0 时根据扩展公钥版本推断")
+	change  = flag.Uint("change", 0, "链类型: 0 收款, 1 找零")
+	from    = flag.Uint("from", 0, "起始地址索引")
+	count   = flag.Uint("count", 10, "派生地址数量")
+	netOpts = helper.RegisterNetworkFlags()
+)
+
+func main() {
+	flag.Parse()
+
+	if *xpub == "" {
+		log.Fatal("请通过 -xpub 指定扩展公钥")
+	}
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	// 只用扩展公钥派生地址，不需要助记词
+	wallet, err := hdwallet.NewWatchOnly(*xpub, hdwallet.Purpose(*purpose), net.Params)
+	if err != nil {
+		log.Fatalf("创建观察钱包失败: %v", err)
+	}
+
+	fmt.Println("地址类型:", wallet.Purpose())
+	for i := uint32(*from); i < uint32(*from+*count); i++ {
+		key, err := wallet.Derive(uint32(*change), i)
+		if err != nil {
+			log.Fatalf("派生地址失败: %v", err)
+		}
+		fmt.Printf("%d/%d  %s  %s\n", key.Path.Change, key.Path.Index, key.Address.EncodeAddress(), hex.EncodeToString(key.PkScript))
+	}
+}
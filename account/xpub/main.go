@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/hdwallet"
+	"go-btc/helper"
+)
+
+var (
+	account = flag.Uint("account", 0, "账户索引")
+	secrets = helper.RegisterSecretFlags()
+	netOpts = helper.RegisterNetworkFlags()
+)
+
+func main() {
+	flag.Parse()
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+
+	fmt.Println("主密钥指纹:", hdwallet.FormatFingerprint(deriver.MasterFingerprint()))
+
+	// 导出四种地址类型的账户级扩展公钥
+	purposes := []hdwallet.Purpose{
+		hdwallet.PurposeBIP44,
+		hdwallet.PurposeBIP49,
+		hdwallet.PurposeBIP84,
+		hdwallet.PurposeBIP86,
+	}
+	for _, purpose := range purposes {
+		xpub, err := deriver.AccountXPub(purpose, net.CoinType(), uint32(*account))
+		if err != nil {
+			log.Fatalf("导出扩展公钥失败: %v", err)
+		}
+		fmt.Printf("%s (m/%d'/%d'/%d'): %s\n", purpose, uint32(purpose), net.CoinType(), *account, xpub)
+	}
+}
@@ -0,0 +1,110 @@
+package coinselect
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// bnbMaxTries Branch-and-Bound 最多遍历的节点数，与 Bitcoin Core 相同
+const bnbMaxTries = 100000
+
+// errNoChangelessMatch BnB 找不到无需找零的输入组合
+var errNoChangelessMatch = errors.New("找不到无需找零的输入组合")
+
+// selectBnB 使用 Branch-and-Bound 搜索有效价值之和落在
+// [目标, 目标 + 找零成本] 区间内的输入组合。这样的组合不需要找零输出，
+// 多出的金额直接作为手续费，比创建找零更省。多个组合中取 waste 最小的。
+// 算法与 Bitcoin Core 的 SelectCoinsBnB 一致。
+func selectBnB(coins []Coin, p *Params) (*Result, error) {
+	pool := append([]Coin(nil), coins...)
+	sort.SliceStable(pool, func(i, j int) bool {
+		return p.effectiveValue(pool[i]) > p.effectiveValue(pool[j])
+	})
+
+	n := len(pool)
+	values := make([]int64, n)
+	wastes := make([]int64, n) // 当前费率与长期费率下输入手续费之差
+	fees := make([]int64, n)
+	var available int64
+	for i, c := range pool {
+		values[i] = p.effectiveValue(c)
+		fees[i] = inputFee(c.InputWeight, p.FeeRate)
+		wastes[i] = fees[i] - inputFee(c.InputWeight, p.longTermFeeRate())
+		available += values[i]
+	}
+
+	target := p.Target + Fee(p.BaseWeight, p.FeeRate)
+	costOfChange := p.costOfChange()
+	if available < target {
+		return nil, insufficient(coins, p)
+	}
+	// 当前费率高于长期费率时，输入越多 waste 越大，可以提前剪枝
+	feeRateHigh := n > 0 && wastes[0] > 0
+
+	var (
+		selection []int
+		best      []int
+		bestWaste int64 = math.MaxInt64
+		currValue int64
+		currWaste int64
+		currAvail = available
+		index     int
+	)
+	for try := 0; try < bnbMaxTries; try, index = try+1, index+1 {
+		backtrack := false
+		switch {
+		case currValue+currAvail < target, // 剩下的全部加上也不够
+			currValue > target+costOfChange,      // 超出范围，不如找零
+			currWaste > bestWaste && feeRateHigh: // 不可能比已有结果更好
+			backtrack = true
+		case currValue >= target:
+			// 找到一个组合，多出的金额计入 waste
+			waste := currWaste + currValue - target
+			if waste <= bestWaste {
+				best = append(best[:0], selection...)
+				bestWaste = waste
+			}
+			backtrack = true
+		}
+
+		if backtrack {
+			if len(selection) == 0 {
+				break
+			}
+			// 把最后一个选中输入之后被跳过的输入加回待选余额，再尝试不选该输入的分支
+			for index--; index > selection[len(selection)-1]; index-- {
+				currAvail += values[index]
+			}
+			last := selection[len(selection)-1]
+			currValue -= values[last]
+			currWaste -= wastes[last]
+			selection = selection[:len(selection)-1]
+			continue
+		}
+
+		currAvail -= values[index]
+		// 与上一个被跳过的输入等价时同样跳过，避免重复搜索相同的组合
+		if len(selection) == 0 ||
+			index-1 == selection[len(selection)-1] ||
+			values[index] != values[index-1] ||
+			fees[index] != fees[index-1] {
+			selection = append(selection, index)
+			currValue += values[index]
+			currWaste += wastes[index]
+		}
+	}
+
+	if best == nil {
+		return nil, errNoChangelessMatch
+	}
+	selected := make([]Coin, len(best))
+	for i, idx := range best {
+		selected[i] = pool[idx]
+	}
+	result := finalize(BnB, selected, p)
+	if result == nil {
+		return nil, errNoChangelessMatch
+	}
+	return result, nil
+}
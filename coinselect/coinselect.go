@@ -0,0 +1,333 @@
+// Package coinselect 为交易选择输入（UTXO）。
+//
+// 所有算法都按有效价值（面值减去按目标费率花费该输入所需的手续费）选币，
+// 因此选出的输入一定足够支付收款金额和整笔交易的手续费。
+// 支持的算法：Branch-and-Bound（无找零精确匹配）、knapsack（随机近似最优子集）、
+// largest-first（面值从大到小）和 oldest-first（确认高度从低到高）。
+// 每个结果都带有 Bitcoin Core 定义的 waste 指标，Select 在自动模式下取 waste 最小的结果。
+package coinselect
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Algorithm 选币算法
+type Algorithm string
+
+const (
+	Auto         Algorithm = "auto" // 依次尝试 BnB、knapsack、largest-first，取 waste 最小的结果
+	BnB          Algorithm = "bnb"
+	Knapsack     Algorithm = "knapsack"
+	LargestFirst Algorithm = "largest-first"
+	OldestFirst  Algorithm = "oldest-first"
+	Sweep        Algorithm = "sweep" // SelectAll 花费全部输入
+)
+
+// Algorithms 所有可选的算法，顺序用于命令行帮助
+var Algorithms = []Algorithm{Auto, BnB, Knapsack, LargestFirst, OldestFirst}
+
+// ParseAlgorithm 解析算法名称
+func ParseAlgorithm(s string) (Algorithm, error) {
+	for _, a := range Algorithms {
+		if string(a) == s {
+			return a, nil
+		}
+	}
+	return "", fmt.Errorf("未知的选币算法 %q，可选: auto, bnb, knapsack, largest-first, oldest-first", s)
+}
+
+// Coin 一个可花费的输出
+type Coin struct {
+	ID          string // 输出标识，通常为 txid:vout
+	Value       int64  // 面值 (sat)
+	InputWeight int64  // 花费该输出的输入重量 (WU)，包括见证数据
+	Height      int64  // 确认高度，0 表示未确认
+}
+
+// Params 选币参数，重量单位为 WU，费率单位为 sat/vB
+type Params struct {
+	Target            int64 // 所有收款输出的金额之和
+	FeeRate           int64 // 目标费率
+	LongTermFeeRate   int64 // 长期费率，用于计算 waste，为 0 时等于 FeeRate
+	BaseWeight        int64 // 不含输入和找零输出的交易重量：版本、锁定时间、计数和收款输出
+	ChangeWeight      int64 // 找零输出的重量
+	ChangeSpendWeight int64 // 将来花费找零输出所需的输入重量
+	MinChange         int64 // 最小找零金额，低于该值的找零并入手续费，通常取 policy.MinViableChange
+	SubtractFee       bool  // 手续费从收款金额中扣除，选币只需覆盖收款金额本身
+}
+
+// Result 选币结果
+type Result struct {
+	Algorithm     Algorithm
+	Coins         []Coin
+	InputValue    int64 // 所选输入的面值之和
+	Change        int64 // 找零金额，0 表示不创建找零输出
+	ChangeDropped int64 // 低于 MinChange 而并入手续费的找零金额，0 表示没有丢弃找零
+	RecipientFee  int64 // SubtractFee 时需要从收款输出中扣除的手续费
+	Fee           int64 // 实际手续费
+	Weight        int64 // 交易总重量（含找零输出）
+	Waste         int64
+}
+
+// InsufficientFundsError 可用余额不足以支付收款金额和手续费
+type InsufficientFundsError struct {
+	Available int64 // 所有输入的有效价值之和
+	Needed    int64 // 收款金额加上不含输入的交易手续费
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("余额不足: 扣除输入手续费后可用 %d sat，至少需要 %d sat（还差 %d sat）",
+		e.Available, e.Needed, e.Needed-e.Available)
+}
+
+// Fee 返回指定重量在给定费率下的手续费，虚拟大小向上取整
+func Fee(weight, feeRate int64) int64 {
+	return (weight + 3) / 4 * feeRate
+}
+
+// inputFee 返回单个输入的手续费，按重量比例向上取整
+func inputFee(weight, feeRate int64) int64 {
+	return (weight*feeRate + 3) / 4
+}
+
+// longTermFeeRate 返回计算 waste 使用的长期费率
+func (p *Params) longTermFeeRate() int64 {
+	if p.LongTermFeeRate > 0 {
+		return p.LongTermFeeRate
+	}
+	return p.FeeRate
+}
+
+// costOfChange 返回创建并在将来花费找零输出的成本
+func (p *Params) costOfChange() int64 {
+	return inputFee(p.ChangeWeight, p.FeeRate) + inputFee(p.ChangeSpendWeight, p.longTermFeeRate())
+}
+
+// effectiveValue 返回输出扣除花费手续费后的价值
+func (p *Params) effectiveValue(c Coin) int64 {
+	return c.Value - inputFee(c.InputWeight, p.FeeRate)
+}
+
+// Select 使用指定算法选币
+func Select(algorithm Algorithm, coins []Coin, params Params) (*Result, error) {
+	if params.Target <= 0 {
+		return nil, fmt.Errorf("收款金额必须大于 0")
+	}
+	if params.FeeRate < 0 {
+		return nil, fmt.Errorf("费率不能为负数")
+	}
+
+	if params.SubtractFee {
+		return selectSubtractFee(algorithm, coins, params)
+	}
+
+	// 有效价值不为正的输出花费后反而减少余额，不参与选币
+	var usable []Coin
+	var available int64
+	for _, c := range coins {
+		if ev := params.effectiveValue(c); ev > 0 {
+			usable = append(usable, c)
+			available += ev
+		}
+	}
+	needed := params.Target + Fee(params.BaseWeight, params.FeeRate)
+	if available < needed {
+		return nil, &InsufficientFundsError{Available: available, Needed: needed}
+	}
+
+	switch algorithm {
+	case BnB:
+		return selectBnB(usable, &params)
+	case Knapsack:
+		return selectKnapsack(usable, &params)
+	case LargestFirst:
+		return selectLargestFirst(usable, &params)
+	case OldestFirst:
+		return selectOldestFirst(usable, &params)
+	case Auto, "":
+		return selectAuto(usable, &params)
+	default:
+		return nil, fmt.Errorf("未知的选币算法 %q", algorithm)
+	}
+}
+
+// selectSubtractFee 由收款人承担手续费：按面值选币，只需覆盖收款金额，
+// 再根据选中输入和是否找零计算手续费。手续费不小于收款金额时返回余额不足错误。
+func selectSubtractFee(algorithm Algorithm, coins []Coin, params Params) (*Result, error) {
+	selection := params
+	selection.SubtractFee = false
+	selection.FeeRate = 0
+	selection.LongTermFeeRate = 0
+	result, err := Select(algorithm, coins, selection)
+	if err != nil {
+		return nil, err
+	}
+	result = finalizeSubtractFee(result.Algorithm, result.Coins, &params)
+	if result.RecipientFee >= params.Target {
+		return nil, &InsufficientFundsError{Available: params.Target, Needed: result.RecipientFee}
+	}
+	return result, nil
+}
+
+// SelectAll 花费全部输入且不找零（清空钱包），Target 被忽略，
+// 返回结果的 RecipientFee 为需要从唯一的收款输出中扣除的手续费
+func SelectAll(coins []Coin, params Params) (*Result, error) {
+	if len(coins) == 0 {
+		return nil, &InsufficientFundsError{Needed: Fee(params.BaseWeight, params.FeeRate)}
+	}
+	params.Target = 0
+	params.MinChange = 0
+	for _, c := range coins {
+		params.Target += c.Value
+	}
+	result := finalizeSubtractFee(Sweep, coins, &params)
+	if result.RecipientFee >= result.InputValue {
+		return nil, &InsufficientFundsError{Available: result.InputValue, Needed: result.RecipientFee}
+	}
+	return result, nil
+}
+
+// finalizeSubtractFee 计算由收款人承担手续费时的找零和手续费。
+// 不找零时多出的金额先用于支付手续费，不足部分再从收款输出中扣除。
+func finalizeSubtractFee(algorithm Algorithm, coins []Coin, p *Params) *Result {
+	weight := p.BaseWeight
+	var inputValue, waste int64
+	for _, c := range coins {
+		weight += c.InputWeight
+		inputValue += c.Value
+		waste += inputFee(c.InputWeight, p.FeeRate) - inputFee(c.InputWeight, p.longTermFeeRate())
+	}
+
+	result := &Result{
+		Algorithm:  algorithm,
+		Coins:      coins,
+		InputValue: inputValue,
+	}
+	change := inputValue - p.Target
+	if change >= p.MinChange && change > 0 {
+		result.Change = change
+		result.Weight = weight + p.ChangeWeight
+		result.RecipientFee = Fee(result.Weight, p.FeeRate)
+		result.Waste = waste + p.costOfChange()
+	} else {
+		result.Weight = weight
+		result.ChangeDropped = change
+		result.RecipientFee = Fee(weight, p.FeeRate) - change
+		if result.RecipientFee < 0 {
+			result.RecipientFee = 0
+		}
+		result.Waste = waste + change
+	}
+	result.Fee = inputValue - (p.Target - result.RecipientFee) - result.Change
+	return result
+}
+
+// selectAuto 依次运行各算法，取 waste 最小的结果；waste 相同时选输入更多的结果以整理 UTXO
+func selectAuto(coins []Coin, p *Params) (*Result, error) {
+	var best *Result
+	var lastErr error
+	for _, algorithm := range []Algorithm{BnB, Knapsack, LargestFirst} {
+		var result *Result
+		var err error
+		switch algorithm {
+		case BnB:
+			result, err = selectBnB(coins, p)
+		case Knapsack:
+			result, err = selectKnapsack(coins, p)
+		case LargestFirst:
+			result, err = selectLargestFirst(coins, p)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if best == nil || result.Waste < best.Waste ||
+			(result.Waste == best.Waste && len(result.Coins) > len(best.Coins)) {
+			best = result
+		}
+	}
+	if best == nil {
+		return nil, lastErr
+	}
+	return best, nil
+}
+
+// finalize 根据选中的输入计算手续费、找零和 waste。
+// 找零不低于 MinChange 时创建找零输出，否则多出的金额全部作为手续费。
+// 输入不足以支付收款金额和手续费时返回 nil。
+func finalize(algorithm Algorithm, coins []Coin, p *Params) *Result {
+	weight := p.BaseWeight
+	var inputValue, waste int64
+	for _, c := range coins {
+		weight += c.InputWeight
+		inputValue += c.Value
+		waste += inputFee(c.InputWeight, p.FeeRate) - inputFee(c.InputWeight, p.longTermFeeRate())
+	}
+
+	excess := inputValue - p.Target - Fee(weight, p.FeeRate)
+	if excess < 0 {
+		return nil
+	}
+
+	result := &Result{
+		Algorithm:  algorithm,
+		Coins:      coins,
+		InputValue: inputValue,
+	}
+	change := inputValue - p.Target - Fee(weight+p.ChangeWeight, p.FeeRate)
+	if change >= p.MinChange && change > 0 {
+		result.Change = change
+		result.Weight = weight + p.ChangeWeight
+		result.Waste = waste + p.costOfChange()
+	} else {
+		result.Weight = weight
+		result.Waste = waste + excess
+		if change > 0 {
+			result.ChangeDropped = change
+		}
+	}
+	result.Fee = inputValue - p.Target - result.Change
+	return result
+}
+
+// accumulate 按顺序添加输入，直到足以支付收款金额和手续费
+func accumulate(algorithm Algorithm, coins []Coin, p *Params) (*Result, error) {
+	for i := range coins {
+		if result := finalize(algorithm, coins[:i+1], p); result != nil {
+			return result, nil
+		}
+	}
+	return nil, insufficient(coins, p)
+}
+
+// insufficient 构造余额不足错误
+func insufficient(coins []Coin, p *Params) error {
+	var available int64
+	for _, c := range coins {
+		available += p.effectiveValue(c)
+	}
+	return &InsufficientFundsError{Available: available, Needed: p.Target + Fee(p.BaseWeight, p.FeeRate)}
+}
+
+// selectLargestFirst 按面值从大到小选币，输入数量最少
+func selectLargestFirst(coins []Coin, p *Params) (*Result, error) {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Value > sorted[j].Value
+	})
+	return accumulate(LargestFirst, sorted, p)
+}
+
+// selectOldestFirst 按确认高度从低到高选币，未确认的输出排在最后
+func selectOldestFirst(coins []Coin, p *Params) (*Result, error) {
+	sorted := append([]Coin(nil), coins...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		hi, hj := sorted[i].Height, sorted[j].Height
+		if hi == 0 || hj == 0 {
+			return hi != 0 && hj == 0
+		}
+		return hi < hj
+	})
+	return accumulate(OldestFirst, sorted, p)
+}
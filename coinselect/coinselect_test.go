@@ -0,0 +1,257 @@
+package coinselect
+
+import (
+	"errors"
+	"sort"
+	"testing"
+)
+
+// 测试使用的重量：P2WPKH 输入 68 vB，P2WPKH 找零输出 31 vB
+const (
+	testInputWeight  = 272
+	testChangeWeight = 124
+	testBaseWeight   = 400
+)
+
+func testCoin(id string, value int64) Coin {
+	return Coin{ID: id, Value: value, InputWeight: testInputWeight}
+}
+
+// testParams 费率 10 sat/vB：每个输入的手续费为 680 sat，不含输入的交易手续费为 1000 sat
+func testParams(target int64) Params {
+	return Params{
+		Target:            target,
+		FeeRate:           10,
+		BaseWeight:        testBaseWeight,
+		ChangeWeight:      testChangeWeight,
+		ChangeSpendWeight: testInputWeight,
+	}
+}
+
+func coinIDs(coins []Coin) []string {
+	ids := make([]string, len(coins))
+	for i, c := range coins {
+		ids[i] = c.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkResult 检查结果的金额守恒：输入 = 收款 + 找零 + 手续费
+func checkResult(t *testing.T, result *Result, p Params) {
+	t.Helper()
+	var inputValue, weight int64
+	weight = p.BaseWeight
+	for _, c := range result.Coins {
+		inputValue += c.Value
+		weight += c.InputWeight
+	}
+	if result.Change > 0 {
+		weight += p.ChangeWeight
+	}
+	if inputValue != result.InputValue {
+		t.Errorf("InputValue 为 %d，输入面值之和为 %d", result.InputValue, inputValue)
+	}
+	if weight != result.Weight {
+		t.Errorf("Weight 为 %d，期望 %d", result.Weight, weight)
+	}
+	recipient := p.Target - result.RecipientFee
+	if got := result.InputValue - recipient - result.Change; got != result.Fee {
+		t.Errorf("Fee 为 %d，输入减去输出为 %d", result.Fee, got)
+	}
+	if result.Fee < Fee(result.Weight, p.FeeRate) {
+		t.Errorf("手续费 %d 低于 %d sat/vB 所需的 %d", result.Fee, p.FeeRate, Fee(result.Weight, p.FeeRate))
+	}
+}
+
+// summary 结果中需要比较的字段
+type summary struct {
+	Algorithm     Algorithm
+	Change        int64
+	ChangeDropped int64
+	RecipientFee  int64
+	Fee           int64
+	Waste         int64
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		coins     []Coin
+		params    func(p *Params)
+		target    int64
+		want      summary
+		wantCoins []string
+	}{
+		{
+			// 有效价值 5000 + 6000 恰好等于收款金额加上不含输入的手续费
+			name:      "BnB 无找零精确匹配",
+			algorithm: BnB,
+			coins:     []Coin{testCoin("a", 20680), testCoin("b", 5680), testCoin("c", 6680), testCoin("d", 3680)},
+			target:    10000,
+			want:      summary{Algorithm: BnB, Fee: 2360},
+			wantCoins: []string{"b", "c"},
+		},
+		{
+			name:      "自动模式优先无找零匹配",
+			algorithm: Auto,
+			coins:     []Coin{testCoin("a", 20680), testCoin("b", 5680), testCoin("c", 6680), testCoin("d", 3680)},
+			target:    10000,
+			want:      summary{Algorithm: BnB, Fee: 2360},
+			wantCoins: []string{"b", "c"},
+		},
+		{
+			// 多出 820 sat：扣除找零输出手续费后只剩 510 sat，低于最小找零
+			name:      "找零低于最小找零时并入手续费",
+			algorithm: LargestFirst,
+			coins:     []Coin{testCoin("a", 12500)},
+			params:    func(p *Params) { p.MinChange = 1000 },
+			target:    10000,
+			want:      summary{Algorithm: LargestFirst, ChangeDropped: 510, Fee: 2500, Waste: 820},
+			wantCoins: []string{"a"},
+		},
+		{
+			name:      "找零不低于最小找零时创建找零",
+			algorithm: LargestFirst,
+			coins:     []Coin{testCoin("a", 20000)},
+			params:    func(p *Params) { p.MinChange = 1000 },
+			target:    10000,
+			want:      summary{Algorithm: LargestFirst, Change: 8010, Fee: 1990, Waste: 990},
+			wantCoins: []string{"a"},
+		},
+		{
+			// 长期费率 1 sat/vB 时每个输入的 waste 为 612：
+			// BnB 的三个输入 waste 为 1836，单个大额输入加找零的 waste 为 612 + 378
+			name:      "自动模式取 waste 最小的结果",
+			algorithm: Auto,
+			coins:     []Coin{testCoin("a", 4680), testCoin("b", 4680), testCoin("c", 3680), testCoin("big", 50000)},
+			params:    func(p *Params) { p.LongTermFeeRate = 1 },
+			target:    10000,
+			want:      summary{Algorithm: Knapsack, Change: 38010, Fee: 1990, Waste: 990},
+			wantCoins: []string{"big"},
+		},
+		{
+			name:      "长期费率相同时自动模式选择 BnB",
+			algorithm: Auto,
+			coins:     []Coin{testCoin("a", 4680), testCoin("b", 4680), testCoin("c", 3680), testCoin("big", 50000)},
+			target:    10000,
+			want:      summary{Algorithm: BnB, Fee: 3040},
+			wantCoins: []string{"a", "b", "c"},
+		},
+		{
+			// 按面值选币后手续费 1990 sat 由收款人承担
+			name:      "从收款金额中扣除手续费",
+			algorithm: LargestFirst,
+			coins:     []Coin{testCoin("a", 30000)},
+			params:    func(p *Params) { p.SubtractFee = true },
+			target:    10000,
+			want:      summary{Algorithm: LargestFirst, Change: 20000, RecipientFee: 1990, Fee: 1990, Waste: 990},
+			wantCoins: []string{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams(tt.target)
+			if tt.params != nil {
+				tt.params(&params)
+			}
+			result, err := Select(tt.algorithm, tt.coins, params)
+			if err != nil {
+				t.Fatalf("选币失败: %v", err)
+			}
+			got := summary{
+				Algorithm:     result.Algorithm,
+				Change:        result.Change,
+				ChangeDropped: result.ChangeDropped,
+				RecipientFee:  result.RecipientFee,
+				Fee:           result.Fee,
+				Waste:         result.Waste,
+			}
+			if got != tt.want {
+				t.Errorf("结果为 %+v，期望 %+v", got, tt.want)
+			}
+			if ids := coinIDs(result.Coins); !equalIDs(ids, tt.wantCoins) {
+				t.Errorf("选中的输入为 %v，期望 %v", ids, tt.wantCoins)
+			}
+			checkResult(t, result, params)
+		})
+	}
+}
+
+func TestSelectInsufficientFunds(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		coins     []Coin
+		params    func(p *Params)
+		target    int64
+		available int64
+		needed    int64
+	}{
+		{
+			// 面值 500 的输出有效价值为负，不计入可用余额
+			name:      "余额不足",
+			algorithm: Auto,
+			coins:     []Coin{testCoin("a", 1000), testCoin("b", 500)},
+			target:    5000,
+			available: 320,
+			needed:    6000,
+		},
+		{
+			name:      "没有输入",
+			algorithm: LargestFirst,
+			target:    5000,
+			needed:    6000,
+		},
+		{
+			// 不找零时手续费为 Fee(672) = 1680 sat，超过收款金额
+			name:      "扣除的手续费超过收款金额",
+			algorithm: LargestFirst,
+			coins:     []Coin{testCoin("a", 1000)},
+			params:    func(p *Params) { p.SubtractFee = true },
+			target:    1000,
+			available: 1000,
+			needed:    1680,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := testParams(tt.target)
+			if tt.params != nil {
+				tt.params(&params)
+			}
+			_, err := Select(tt.algorithm, tt.coins, params)
+			var insufficient *InsufficientFundsError
+			if !errors.As(err, &insufficient) {
+				t.Fatalf("错误为 %v，期望 *InsufficientFundsError", err)
+			}
+			if insufficient.Available != tt.available || insufficient.Needed != tt.needed {
+				t.Errorf("可用 %d、需要 %d，期望可用 %d、需要 %d",
+					insufficient.Available, insufficient.Needed, tt.available, tt.needed)
+			}
+		})
+	}
+}
+
+// 没有落在 [目标, 目标 + 找零成本] 区间内的组合时 BnB 失败
+func TestSelectBnBNoMatch(t *testing.T) {
+	coins := []Coin{testCoin("a", 20680), testCoin("b", 30680)}
+	if _, err := Select(BnB, coins, testParams(10000)); !errors.Is(err, errNoChangelessMatch) {
+		t.Fatalf("错误为 %v，期望 %v", err, errNoChangelessMatch)
+	}
+}
@@ -0,0 +1,131 @@
+package coinselect
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// knapsackIterations 随机近似搜索的轮数，与 Bitcoin Core 相同
+const knapsackIterations = 1000
+
+// selectKnapsack 使用 Bitcoin Core 旧版默认的 knapsack 算法：
+// 目标为收款金额、手续费和找零输出手续费之和，优先精确匹配，
+// 否则在较小的输出中随机搜索最接近目标的子集，并与刚好大于目标的单个输出比较。
+func selectKnapsack(coins []Coin, p *Params) (*Result, error) {
+	target := p.Target + Fee(p.BaseWeight+p.ChangeWeight, p.FeeRate)
+	minChange := p.MinChange
+
+	pool := append([]Coin(nil), coins...)
+	rand.Shuffle(len(pool), func(i, j int) {
+		pool[i], pool[j] = pool[j], pool[i]
+	})
+
+	var (
+		lowestLarger *Coin
+		smaller      []Coin
+		totalLower   int64
+	)
+	for i := range pool {
+		c := pool[i]
+		value := p.effectiveValue(c)
+		switch {
+		case value == target:
+			return finalizeOrFail([]Coin{c}, p)
+		case value < target+minChange:
+			smaller = append(smaller, c)
+			totalLower += value
+		case lowestLarger == nil || value < p.effectiveValue(*lowestLarger):
+			lowestLarger = &pool[i]
+		}
+	}
+
+	if totalLower == target {
+		return finalizeOrFail(smaller, p)
+	}
+	if totalLower < target {
+		if lowestLarger == nil {
+			return nil, insufficient(coins, p)
+		}
+		return finalizeOrFail([]Coin{*lowestLarger}, p)
+	}
+
+	sort.SliceStable(smaller, func(i, j int) bool {
+		return p.effectiveValue(smaller[i]) > p.effectiveValue(smaller[j])
+	})
+	values := make([]int64, len(smaller))
+	for i, c := range smaller {
+		values[i] = p.effectiveValue(c)
+	}
+
+	bestSet, bestValue := approximateBestSubset(values, totalLower, target)
+	if bestValue != target && totalLower >= target+minChange {
+		bestSet, bestValue = approximateBestSubset(values, totalLower, target+minChange)
+	}
+
+	// 子集既不精确也留不出足够找零，或者单个较大输出更接近目标时，使用该输出
+	if lowestLarger != nil &&
+		((bestValue != target && bestValue < target+minChange) || p.effectiveValue(*lowestLarger) <= bestValue) {
+		return finalizeOrFail([]Coin{*lowestLarger}, p)
+	}
+
+	var selected []Coin
+	for i, included := range bestSet {
+		if included {
+			selected = append(selected, smaller[i])
+		}
+	}
+	return finalizeOrFail(selected, p)
+}
+
+// approximateBestSubset 随机搜索总和不小于 target 且尽量小的子集
+func approximateBestSubset(values []int64, total, target int64) ([]bool, int64) {
+	best := make([]bool, len(values))
+	for i := range best {
+		best[i] = true
+	}
+	bestValue := total
+
+	included := make([]bool, len(values))
+	for rep := 0; rep < knapsackIterations && bestValue != target; rep++ {
+		for i := range included {
+			included[i] = false
+		}
+		var sum int64
+		reached := false
+		// 第一轮随机选择，第二轮补上未选的输出
+		for pass := 0; pass < 2 && !reached; pass++ {
+			for i, v := range values {
+				var pick bool
+				if pass == 0 {
+					pick = rand.Intn(2) == 1
+				} else {
+					pick = !included[i]
+				}
+				if !pick {
+					continue
+				}
+				sum += v
+				included[i] = true
+				if sum >= target {
+					reached = true
+					if sum < bestValue {
+						bestValue = sum
+						copy(best, included)
+					}
+					// 去掉刚加入的输出，继续寻找更小的子集
+					sum -= v
+					included[i] = false
+				}
+			}
+		}
+	}
+	return best, bestValue
+}
+
+// finalizeOrFail 计算 knapsack 结果，选中的输入不足时返回余额不足错误
+func finalizeOrFail(coins []Coin, p *Params) (*Result, error) {
+	if result := finalize(Knapsack, coins, p); result != nil {
+		return result, nil
+	}
+	return nil, insufficient(coins, p)
+}
@@ -0,0 +1,96 @@
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 描述符校验和所用的字符集，定义见 BIP-380
+const (
+	inputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	checksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// polyMod 以 GF(32) 上的 BCH 码更新校验和状态
+func polyMod(c uint64, val int) uint64 {
+	c0 := c >> 35
+	c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+	if c0&1 != 0 {
+		c ^= 0xf5dee51989
+	}
+	if c0&2 != 0 {
+		c ^= 0xa9fdca3312
+	}
+	if c0&4 != 0 {
+		c ^= 0x1bab10e32d
+	}
+	if c0&8 != 0 {
+		c ^= 0x3706b1677a
+	}
+	if c0&16 != 0 {
+		c ^= 0x644d626ffd
+	}
+	return c
+}
+
+// Checksum 计算描述符（不含 # 部分）的 8 位校验和
+func Checksum(desc string) (string, error) {
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(inputCharset, ch)
+		if pos < 0 {
+			return "", fmt.Errorf("描述符包含无效字符 %q", ch)
+		}
+		c = polyMod(c, pos&31)
+		cls = cls*3 + pos>>5
+		clsCount++
+		if clsCount == 3 {
+			c = polyMod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polyMod(c, cls)
+	}
+	for i := 0; i < 8; i++ {
+		c = polyMod(c, 0)
+	}
+	c ^= 1
+
+	var sum [8]byte
+	for i := range sum {
+		sum[i] = checksumCharset[(c>>(5*(7-i)))&31]
+	}
+	return string(sum[:]), nil
+}
+
+// AddChecksum 返回附带校验和的描述符 "desc#checksum"
+func AddChecksum(desc string) (string, error) {
+	sum, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	return desc + "#" + sum, nil
+}
+
+// splitChecksum 拆分并校验描述符末尾的校验和，没有校验和时原样返回
+func splitChecksum(s string) (string, error) {
+	i := strings.LastIndexByte(s, '#')
+	if i < 0 {
+		return s, nil
+	}
+
+	desc, sum := s[:i], s[i+1:]
+	if len(sum) != 8 {
+		return "", fmt.Errorf("描述符校验和长度错误: %q", sum)
+	}
+	expected, err := Checksum(desc)
+	if err != nil {
+		return "", err
+	}
+	if sum != expected {
+		return "", fmt.Errorf("描述符校验和不匹配: 期望 %s, 实际 %s", expected, sum)
+	}
+	return desc, nil
+}
@@ -0,0 +1,44 @@
+package descriptor
+
+import "testing"
+
+// BIP-380 的校验和测试向量
+func TestChecksum(t *testing.T) {
+	sum, err := Checksum("raw(deadbeef)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != "89f8spxm" {
+		t.Fatalf("校验和为 %s，期望 89f8spxm", sum)
+	}
+
+	valid := []string{
+		"raw(deadbeef)#89f8spxm",
+		"raw(deadbeef)", // 没有校验和时不校验
+	}
+	for _, s := range valid {
+		desc, err := splitChecksum(s)
+		if err != nil {
+			t.Errorf("%s: %v", s, err)
+		} else if desc != "raw(deadbeef)" {
+			t.Errorf("%s: 拆分结果为 %s", s, desc)
+		}
+	}
+
+	invalid := []struct {
+		name string
+		desc string
+	}{
+		{"缺少校验和", "raw(deadbeef)#"},
+		{"校验和过长", "raw(deadbeef)#89f8spxmx"},
+		{"校验和过短", "raw(deadbeef)#89f8spx"},
+		{"描述符有错误", "raw(dedbeef)#89f8spxm"},
+		{"校验和有错误", "raw(deadbeef)##9f8spxm"},
+		{"描述符包含无效字符", "raw(Ü)#00000000"},
+	}
+	for _, tt := range invalid {
+		if _, err := splitChecksum(tt.desc); err == nil {
+			t.Errorf("%s: %s 校验通过", tt.name, tt.desc)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Package descriptor 实现输出脚本描述符 (BIP-380~386, BIP-389) 的生成与解析，
+// 支持 pkh()、sh(wpkh())、wpkh() 和不带脚本树的 tr()，
+// 用于与 Bitcoin Core、Sparrow 等钱包交换账户信息。
+package descriptor
+
+import (
+	"fmt"
+	"strings"
+
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// 各描述符外层函数与地址类型的对应关系
+var wrappers = []struct {
+	purpose hdwallet.Purpose
+	prefix  string
+	suffix  string
+}{
+	{hdwallet.PurposeBIP49, "sh(wpkh(", "))"},
+	{hdwallet.PurposeBIP44, "pkh(", ")"},
+	{hdwallet.PurposeBIP84, "wpkh(", ")"},
+	{hdwallet.PurposeBIP86, "tr(", ")"},
+}
+
+// Descriptor 单密钥输出脚本描述符，地址类型用 BIP-43 purpose 表示
+type Descriptor struct {
+	Purpose   hdwallet.Purpose
+	Key       *Key
+	netParams *chaincfg.Params
+}
+
+// Output 描述符派生出的一个输出：公钥、地址、锁定脚本以及完整的密钥来源
+type Output struct {
+	PubKey       *btcec.PublicKey
+	Origin       *KeyOrigin
+	Address      btcutil.Address
+	PkScript     []byte
+	RedeemScript []byte // 仅 sh(wpkh()) 有赎回脚本
+}
+
+// NewAccount 为 BIP-44/49/84/86 账户生成描述符：
+// [fingerprint/purpose'/coin_type'/account']xpub/<0;1>/*，同时覆盖收款链和找零链
+func NewAccount(purpose hdwallet.Purpose, fingerprint, coinType, account uint32, accountKey *hdkeychain.ExtendedKey, netParams *chaincfg.Params) (*Descriptor, error) {
+	path := hdwallet.Path{Purpose: purpose, CoinType: coinType, Account: account}
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+	if accountKey.IsPrivate() {
+		return nil, fmt.Errorf("描述符中只支持扩展公钥")
+	}
+
+	return &Descriptor{
+		Purpose: purpose,
+		Key: &Key{
+			Origin: &KeyOrigin{
+				Fingerprint: fingerprint,
+				Path:        path.AccountIndexes(),
+			},
+			XPub:     accountKey,
+			Steps:    [][]uint32{{hdwallet.ChainExternal, hdwallet.ChainInternal}},
+			Wildcard: true,
+		},
+		netParams: netParams,
+	}, nil
+}
+
+// Parse 解析描述符字符串，末尾带 #checksum 时会校验
+func Parse(s string, netParams *chaincfg.Params) (*Descriptor, error) {
+	desc, err := splitChecksum(strings.TrimSpace(s))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range wrappers {
+		if !strings.HasPrefix(desc, w.prefix) || !strings.HasSuffix(desc, w.suffix) {
+			continue
+		}
+		inner := desc[len(w.prefix) : len(desc)-len(w.suffix)]
+		if w.purpose == hdwallet.PurposeBIP86 && strings.Contains(inner, ",") {
+			return nil, fmt.Errorf("暂不支持带脚本树的 tr() 描述符")
+		}
+
+		key, err := parseKey(inner, netParams, w.purpose == hdwallet.PurposeBIP86)
+		if err != nil {
+			return nil, err
+		}
+		return &Descriptor{Purpose: w.purpose, Key: key, netParams: netParams}, nil
+	}
+
+	return nil, fmt.Errorf("不支持的描述符: %q", desc)
+}
+
+// String 返回带校验和的描述符字符串
+func (d *Descriptor) String() string {
+	desc := d.body()
+	sum, err := Checksum(desc)
+	if err != nil {
+		// 描述符由本包生成，只包含合法字符
+		panic(err)
+	}
+	return desc + "#" + sum
+}
+
+// body 返回不带校验和的描述符字符串
+func (d *Descriptor) body() string {
+	for _, w := range wrappers {
+		if w.purpose == d.Purpose {
+			return w.prefix + d.Key.String() + w.suffix
+		}
+	}
+	return ""
+}
+
+// IsRange 判断描述符是否带通配符，可派生一系列地址
+func (d *Descriptor) IsRange() bool {
+	return d.Key.Wildcard
+}
+
+// Split 按 BIP-389 将多路径描述符拆分为单路径描述符，例如 <0;1> 拆成收款和找零两个
+func (d *Descriptor) Split() []*Descriptor {
+	count := d.Key.PathCount()
+	if count == 1 {
+		return []*Descriptor{d}
+	}
+
+	descs := make([]*Descriptor, count)
+	for branch := range descs {
+		key := *d.Key
+		key.Steps = make([][]uint32, len(d.Key.Steps))
+		for i, step := range d.Key.Steps {
+			if len(step) > 1 {
+				key.Steps[i] = []uint32{step[branch]}
+			} else {
+				key.Steps[i] = step
+			}
+		}
+		descs[branch] = &Descriptor{Purpose: d.Purpose, Key: &key, netParams: d.netParams}
+	}
+	return descs
+}
+
+// Derive 派生第 branch 个多路径分支（<0;1> 中 0 为收款、1 为找零）上索引为 index 的输出，
+// 没有多路径时 branch 传 0，没有通配符时忽略 index
+func (d *Descriptor) Derive(branch int, index uint32) (*Output, error) {
+	pubKey, origin, err := d.Key.derive(branch, index)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, redeemScript, err := hdwallet.AddressFromPubKey(d.Purpose, pubKey, d.netParams)
+	if err != nil {
+		return nil, err
+	}
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("生成锁定脚本失败: %w", err)
+	}
+
+	return &Output{
+		PubKey:       pubKey,
+		Origin:       origin,
+		Address:      addr,
+		PkScript:     pkScript,
+		RedeemScript: redeemScript,
+	}, nil
+}
@@ -0,0 +1,38 @@
+package descriptor
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// BIP-386 的 tr() 测试向量：x-only 公钥和压缩公钥得到相同的输出
+func TestParseTaprootKey(t *testing.T) {
+	const script = "512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11"
+	tests := []string{
+		"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		"tr(02a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+	}
+	for _, s := range tests {
+		desc, err := Parse(s, &chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if desc.body() != s {
+			t.Errorf("%s: 字符串形式为 %s", s, desc.body())
+		}
+		out, err := desc.Derive(0, 0)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if got := hex.EncodeToString(out.PkScript); got != script {
+			t.Errorf("%s: 锁定脚本为 %s，期望 %s", s, got, script)
+		}
+	}
+
+	// x-only 公钥只能用于 tr()
+	if _, err := Parse("wpkh(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", &chaincfg.MainNetParams); err == nil {
+		t.Error("wpkh() 接受了 x-only 公钥")
+	}
+}
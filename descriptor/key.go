@@ -0,0 +1,294 @@
+package descriptor
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// KeyOrigin 密钥来源信息 [fingerprint/path]，记录密钥从哪个主密钥沿哪条路径派生而来
+type KeyOrigin struct {
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// String 返回 "73c5da0a/84h/0h/0h" 形式的来源信息（不含方括号）
+func (o KeyOrigin) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x", o.Fingerprint)
+	for _, index := range o.Path {
+		b.WriteByte('/')
+		b.WriteString(formatIndex(index))
+	}
+	return b.String()
+}
+
+// Key 描述符中的密钥表达式：单个公钥，或扩展公钥加上后续派生步骤。
+// 派生步骤中最多有一步为多路径 <a;b>，末尾可以是通配符 *。
+type Key struct {
+	Origin   *KeyOrigin
+	PubKey   *btcec.PublicKey
+	XOnly    bool // PubKey 以 32 字节 x-only 形式给出，只用于 tr()
+	XPub     *hdkeychain.ExtendedKey
+	Steps    [][]uint32 // 每一步的候选索引，长度大于 1 时为多路径
+	Wildcard bool
+}
+
+// parseKey 解析密钥表达式，扩展公钥必须属于 netParams 对应的网络。
+// xOnly 为 true 时（tr() 中）单个公钥还可以是 64 位十六进制的 x-only 公钥 (BIP-386)
+func parseKey(s string, netParams *chaincfg.Params, xOnly bool) (*Key, error) {
+	key := &Key{}
+
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return nil, fmt.Errorf("密钥来源缺少 ]: %q", s)
+		}
+		origin, err := parseOrigin(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		key.Origin = origin
+		s = s[end+1:]
+	}
+
+	parts := strings.Split(s, "/")
+
+	// 单个十六进制公钥，不能再有派生步骤
+	if len(parts[0]) == 66 || (xOnly && len(parts[0]) == 64) {
+		if len(parts) > 1 {
+			return nil, fmt.Errorf("单个公钥不能带派生步骤: %q", s)
+		}
+		raw, err := hex.DecodeString(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("解析公钥失败: %w", err)
+		}
+		var pubKey *btcec.PublicKey
+		if len(raw) == schnorr.PubKeyBytesLen {
+			pubKey, err = schnorr.ParsePubKey(raw)
+			key.XOnly = true
+		} else {
+			pubKey, err = btcec.ParsePubKey(raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析公钥失败: %w", err)
+		}
+		key.PubKey = pubKey
+		return key, nil
+	}
+
+	xpub, err := hdkeychain.NewKeyFromString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("解析扩展公钥失败: %w", err)
+	}
+	if xpub.IsPrivate() {
+		return nil, fmt.Errorf("描述符中只支持扩展公钥")
+	}
+	if !xpub.IsForNet(netParams) {
+		return nil, fmt.Errorf("扩展公钥不属于网络 %s", netParams.Name)
+	}
+	key.XPub = xpub
+
+	multipath := false
+	for i, part := range parts[1:] {
+		if part == "*" {
+			if i != len(parts)-2 {
+				return nil, fmt.Errorf("通配符 * 只能出现在最后: %q", s)
+			}
+			key.Wildcard = true
+			break
+		}
+
+		var step []uint32
+		if strings.HasPrefix(part, "<") && strings.HasSuffix(part, ">") {
+			if multipath {
+				return nil, fmt.Errorf("只允许一个多路径步骤: %q", s)
+			}
+			multipath = true
+			for _, alt := range strings.Split(part[1:len(part)-1], ";") {
+				index, err := parseUnhardened(alt)
+				if err != nil {
+					return nil, err
+				}
+				step = append(step, index)
+			}
+			if len(step) < 2 {
+				return nil, fmt.Errorf("多路径步骤至少需要两个索引: %q", part)
+			}
+		} else {
+			index, err := parseUnhardened(part)
+			if err != nil {
+				return nil, err
+			}
+			step = []uint32{index}
+		}
+		key.Steps = append(key.Steps, step)
+	}
+
+	return key, nil
+}
+
+// parseOrigin 解析 "fingerprint/path" 形式的密钥来源
+func parseOrigin(s string) (*KeyOrigin, error) {
+	parts := strings.Split(s, "/")
+	if len(parts[0]) != 8 {
+		return nil, fmt.Errorf("密钥来源指纹必须为 8 位十六进制: %q", parts[0])
+	}
+	fp, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("解析密钥来源指纹失败: %w", err)
+	}
+
+	origin := &KeyOrigin{Fingerprint: binary.BigEndian.Uint32(fp)}
+	for _, part := range parts[1:] {
+		index, err := parseIndex(part)
+		if err != nil {
+			return nil, err
+		}
+		origin.Path = append(origin.Path, index)
+	}
+	return origin, nil
+}
+
+// parseIndex 解析单个路径索引，硬化标记支持 ' 和 h
+func parseIndex(s string) (uint32, error) {
+	hardened := strings.HasSuffix(s, "'") || strings.HasSuffix(s, "h")
+	if hardened {
+		s = s[:len(s)-1]
+	}
+	v, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || v >= hdkeychain.HardenedKeyStart {
+		return 0, fmt.Errorf("无效的路径索引: %q", s)
+	}
+	if hardened {
+		v += hdkeychain.HardenedKeyStart
+	}
+	return uint32(v), nil
+}
+
+// parseUnhardened 解析扩展公钥之后的派生索引，扩展公钥无法进行硬化派生
+func parseUnhardened(s string) (uint32, error) {
+	index, err := parseIndex(s)
+	if err != nil {
+		return 0, err
+	}
+	if index >= hdkeychain.HardenedKeyStart {
+		return 0, fmt.Errorf("扩展公钥之后不能使用硬化派生: %q", s)
+	}
+	return index, nil
+}
+
+// formatIndex 格式化路径索引，硬化索引使用 h 标记
+func formatIndex(index uint32) string {
+	if index >= hdkeychain.HardenedKeyStart {
+		return strconv.FormatUint(uint64(index-hdkeychain.HardenedKeyStart), 10) + "h"
+	}
+	return strconv.FormatUint(uint64(index), 10)
+}
+
+// String 返回密钥表达式的字符串形式
+func (k *Key) String() string {
+	var b strings.Builder
+	if k.Origin != nil {
+		b.WriteString("[" + k.Origin.String() + "]")
+	}
+	if k.PubKey != nil {
+		if k.XOnly {
+			b.WriteString(hex.EncodeToString(schnorr.SerializePubKey(k.PubKey)))
+		} else {
+			b.WriteString(hex.EncodeToString(k.PubKey.SerializeCompressed()))
+		}
+		return b.String()
+	}
+
+	b.WriteString(k.XPub.String())
+	for _, step := range k.Steps {
+		b.WriteByte('/')
+		if len(step) == 1 {
+			b.WriteString(formatIndex(step[0]))
+			continue
+		}
+		alts := make([]string, len(step))
+		for i, index := range step {
+			alts[i] = formatIndex(index)
+		}
+		b.WriteString("<" + strings.Join(alts, ";") + ">")
+	}
+	if k.Wildcard {
+		b.WriteString("/*")
+	}
+	return b.String()
+}
+
+// PathCount 返回多路径的分支数量，没有多路径时为 1
+func (k *Key) PathCount() int {
+	for _, step := range k.Steps {
+		if len(step) > 1 {
+			return len(step)
+		}
+	}
+	return 1
+}
+
+// derive 派生第 branch 个多路径分支上的第 index 个公钥，并返回完整的密钥来源
+func (k *Key) derive(branch int, index uint32) (*btcec.PublicKey, *KeyOrigin, error) {
+	if branch < 0 || branch >= k.PathCount() {
+		return nil, nil, fmt.Errorf("多路径分支 %d 超出范围", branch)
+	}
+
+	origin := &KeyOrigin{}
+	if k.Origin != nil {
+		origin.Fingerprint = k.Origin.Fingerprint
+		origin.Path = append(origin.Path, k.Origin.Path...)
+	} else if k.XPub != nil {
+		// 没有来源信息时，以扩展公钥自身作为派生起点
+		pubKey, err := k.XPub.ECPubKey()
+		if err != nil {
+			return nil, nil, fmt.Errorf("获取公钥失败: %w", err)
+		}
+		origin.Fingerprint = binary.BigEndian.Uint32(btcutil.Hash160(pubKey.SerializeCompressed())[:4])
+	}
+
+	if k.PubKey != nil {
+		return k.PubKey, origin, nil
+	}
+
+	var path []uint32
+	for _, step := range k.Steps {
+		if len(step) > 1 {
+			path = append(path, step[branch])
+		} else {
+			path = append(path, step[0])
+		}
+	}
+	if k.Wildcard {
+		if index >= hdkeychain.HardenedKeyStart {
+			return nil, nil, fmt.Errorf("地址索引超出范围: %d", index)
+		}
+		path = append(path, index)
+	}
+
+	key := k.XPub
+	for _, childNum := range path {
+		var err error
+		key, err = key.Derive(childNum)
+		if err != nil {
+			return nil, nil, fmt.Errorf("派生公钥失败: %w", err)
+		}
+	}
+	origin.Path = append(origin.Path, path...)
+
+	pubKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取公钥失败: %w", err)
+	}
+	return pubKey, origin, nil
+}
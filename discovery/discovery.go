@@ -0,0 +1,146 @@
+// Package discovery 按 BIP-44 的 gap limit 规则扫描账户，找出已使用的地址和
+// 每条链上下一个未使用的索引。
+package discovery
+
+import (
+	"fmt"
+
+	"go-btc/esplora"
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// DefaultGapLimit BIP-44 建议的连续未使用地址数量
+const DefaultGapLimit = 20
+
+// Backend 查询地址使用情况的数据源，*esplora.Client 实现了该接口
+type Backend interface {
+	AddressStats(address string) (*esplora.AddressStats, error)
+}
+
+// AddressDeriver 按链和索引派生地址，*hdwallet.Account 和 *hdwallet.WatchOnly 都实现了该接口
+type AddressDeriver interface {
+	Derive(change, index uint32) (*hdwallet.Key, error)
+}
+
+// UsedAddress 一个有过交易的地址
+type UsedAddress struct {
+	Key     *hdwallet.Key
+	TxCount int
+	Balance int64
+}
+
+// ChainResult 一条链（收款链或找零链）的扫描结果
+type ChainResult struct {
+	Change    uint32
+	Used      []UsedAddress
+	NextIndex uint32 // 最后一个已使用地址之后的索引，即下一个可用的地址
+}
+
+// Balance 返回该链上所有地址的余额之和
+func (r *ChainResult) Balance() int64 {
+	var total int64
+	for _, u := range r.Used {
+		total += u.Balance
+	}
+	return total
+}
+
+// AccountResult 一个账户的扫描结果
+type AccountResult struct {
+	Purpose  hdwallet.Purpose
+	Account  uint32
+	External ChainResult
+	Internal ChainResult
+}
+
+// Used 账户是否有过交易
+func (r *AccountResult) Used() bool {
+	return len(r.External.Used) > 0 || len(r.Internal.Used) > 0
+}
+
+// Balance 返回账户余额
+func (r *AccountResult) Balance() int64 {
+	return r.External.Balance() + r.Internal.Balance()
+}
+
+// Scanner 扫描器
+type Scanner struct {
+	backend  Backend
+	gapLimit uint32
+}
+
+// NewScanner 创建扫描器，gapLimit 为 0 时使用 DefaultGapLimit
+func NewScanner(backend Backend, gapLimit uint32) *Scanner {
+	if gapLimit == 0 {
+		gapLimit = DefaultGapLimit
+	}
+	return &Scanner{backend: backend, gapLimit: gapLimit}
+}
+
+// ScanChain 从索引 0 开始扫描一条链，遇到连续 gapLimit 个未使用的地址时停止
+func (s *Scanner) ScanChain(d AddressDeriver, change uint32) (*ChainResult, error) {
+	result := &ChainResult{Change: change}
+	var gap uint32
+	for index := uint32(0); gap < s.gapLimit; index++ {
+		key, err := d.Derive(change, index)
+		if err != nil {
+			return nil, fmt.Errorf("派生地址失败: %w", err)
+		}
+		stats, err := s.backend.AddressStats(key.Address.EncodeAddress())
+		if err != nil {
+			return nil, fmt.Errorf("查询地址 %s 失败: %w", key.Address.EncodeAddress(), err)
+		}
+		if stats.TxCount() == 0 {
+			gap++
+			continue
+		}
+		gap = 0
+		result.Used = append(result.Used, UsedAddress{
+			Key:     key,
+			TxCount: stats.TxCount(),
+			Balance: stats.Balance(),
+		})
+		result.NextIndex = index + 1
+	}
+	return result, nil
+}
+
+// ScanAccount 扫描账户的收款链和找零链
+func (s *Scanner) ScanAccount(d AddressDeriver) (external, internal *ChainResult, err error) {
+	external, err = s.ScanChain(d, hdwallet.ChainExternal)
+	if err != nil {
+		return nil, nil, err
+	}
+	internal, err = s.ScanChain(d, hdwallet.ChainInternal)
+	if err != nil {
+		return nil, nil, err
+	}
+	return external, internal, nil
+}
+
+// Discover 按 BIP-44 账户发现规则扫描各地址类型的账户：从账户 0 开始依次扫描，
+// 遇到收款链上没有任何交易的账户时停止该地址类型的扫描。
+// 返回所有有过交易的账户。
+func (s *Scanner) Discover(deriver *hdwallet.Deriver, coinType uint32, purposes []hdwallet.Purpose) ([]*AccountResult, error) {
+	var results []*AccountResult
+	for _, purpose := range purposes {
+		for account := uint32(0); account < hdkeychain.HardenedKeyStart; account++ {
+			external, internal, err := s.ScanAccount(deriver.Account(purpose, coinType, account))
+			if err != nil {
+				return nil, fmt.Errorf("扫描账户 %d'（%s）失败: %w", account, purpose, err)
+			}
+			if len(external.Used) == 0 {
+				break
+			}
+			results = append(results, &AccountResult{
+				Purpose:  purpose,
+				Account:  account,
+				External: *external,
+				Internal: *internal,
+			})
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,224 @@
+// Package esplora 是 mempool.space / Blockstream Esplora REST API 的客户端，
+// 用于查询地址、UTXO、交易和手续费率，以及广播交易。
+package esplora
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client REST API 客户端
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewClient 创建客户端，baseURL 形如 https://mempool.space/testnet/api
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// TxStatus 交易的确认状态
+type TxStatus struct {
+	Confirmed   bool   `json:"confirmed"`
+	BlockHeight int64  `json:"block_height"`
+	BlockHash   string `json:"block_hash"`
+	BlockTime   int64  `json:"block_time"`
+}
+
+// UTXO 地址上的一个未花费输出
+type UTXO struct {
+	TxID   string   `json:"txid"`
+	Vout   uint32   `json:"vout"`
+	Value  int64    `json:"value"`
+	Status TxStatus `json:"status"`
+}
+
+// Stats 地址在链上或内存池中的收支统计
+type Stats struct {
+	FundedTxoCount int   `json:"funded_txo_count"`
+	FundedTxoSum   int64 `json:"funded_txo_sum"`
+	SpentTxoCount  int   `json:"spent_txo_count"`
+	SpentTxoSum    int64 `json:"spent_txo_sum"`
+	TxCount        int   `json:"tx_count"`
+}
+
+// AddressStats 地址的统计信息
+type AddressStats struct {
+	Address      string `json:"address"`
+	ChainStats   Stats  `json:"chain_stats"`
+	MempoolStats Stats  `json:"mempool_stats"`
+}
+
+// TxCount 返回已确认和未确认的交易总数，大于 0 即说明地址被使用过
+func (s *AddressStats) TxCount() int {
+	return s.ChainStats.TxCount + s.MempoolStats.TxCount
+}
+
+// ConfirmedBalance 返回已确认余额
+func (s *AddressStats) ConfirmedBalance() int64 {
+	return s.ChainStats.FundedTxoSum - s.ChainStats.SpentTxoSum
+}
+
+// Balance 返回包含内存池交易在内的余额
+func (s *AddressStats) Balance() int64 {
+	return s.ConfirmedBalance() + s.MempoolStats.FundedTxoSum - s.MempoolStats.SpentTxoSum
+}
+
+// FeeRates mempool.space 推荐的手续费率，单位 sat/vB
+type FeeRates struct {
+	FastestFee  int64 `json:"fastestFee"`
+	HalfHourFee int64 `json:"halfHourFee"`
+	HourFee     int64 `json:"hourFee"`
+	EconomyFee  int64 `json:"economyFee"`
+	MinimumFee  int64 `json:"minimumFee"`
+}
+
+// TxVin 交易输入
+type TxVin struct {
+	TxID     string   `json:"txid"`
+	Vout     uint32   `json:"vout"`
+	Prevout  *TxVout  `json:"prevout"`
+	Sequence uint32   `json:"sequence"`
+	Witness  []string `json:"witness"`
+}
+
+// TxVout 交易输出
+type TxVout struct {
+	ScriptPubKey        string `json:"scriptpubkey"`
+	ScriptPubKeyType    string `json:"scriptpubkey_type"`
+	ScriptPubKeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+// Tx 交易详情
+type Tx struct {
+	TxID     string   `json:"txid"`
+	Version  int32    `json:"version"`
+	LockTime uint32   `json:"locktime"`
+	Vin      []TxVin  `json:"vin"`
+	Vout     []TxVout `json:"vout"`
+	Size     int      `json:"size"`
+	Weight   int      `json:"weight"`
+	Fee      int64    `json:"fee"`
+	Status   TxStatus `json:"status"`
+}
+
+// AddressStats 查询地址统计信息
+func (c *Client) AddressStats(address string) (*AddressStats, error) {
+	var stats AddressStats
+	if err := c.getJSON("/address/"+address, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// UTXOs 查询地址上的所有未花费输出
+func (c *Client) UTXOs(address string) ([]UTXO, error) {
+	var utxos []UTXO
+	if err := c.getJSON("/address/"+address+"/utxo", &utxos); err != nil {
+		return nil, err
+	}
+	return utxos, nil
+}
+
+// AddressTxs 查询地址的交易历史，返回最新的一页（未确认交易在前）
+func (c *Client) AddressTxs(address string) ([]Tx, error) {
+	var txs []Tx
+	if err := c.getJSON("/address/"+address+"/txs", &txs); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+// Tx 查询交易详情
+func (c *Client) Tx(txid string) (*Tx, error) {
+	var tx Tx
+	if err := c.getJSON("/tx/"+txid, &tx); err != nil {
+		return nil, err
+	}
+	return &tx, nil
+}
+
+// RawTx 查询交易的原始十六进制
+func (c *Client) RawTx(txid string) (string, error) {
+	body, err := c.get("/tx/" + txid + "/hex")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// TipHeight 查询当前区块高度
+func (c *Client) TipHeight() (int64, error) {
+	var height int64
+	if err := c.getJSON("/blocks/tip/height", &height); err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// FeeRates 查询推荐手续费率（mempool.space 扩展接口）
+func (c *Client) FeeRates() (*FeeRates, error) {
+	var rates FeeRates
+	if err := c.getJSON("/v1/fees/recommended", &rates); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// Broadcast 广播十六进制的原始交易，返回交易 ID
+func (c *Client) Broadcast(rawTx string) (string, error) {
+	resp, err := c.httpClient.Post(c.baseURL+"/tx", "text/plain", bytes.NewBufferString(rawTx))
+	if err != nil {
+		return "", fmt.Errorf("广播交易失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("广播交易失败: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// get 发送 GET 请求并返回响应内容，非 200 状态码视为错误
+func (c *Client) get(path string) ([]byte, error) {
+	resp, err := c.httpClient.Get(c.baseURL + path)
+	if err != nil {
+		return nil, fmt.Errorf("请求 %s 失败: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 响应失败: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求 %s 失败: %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// getJSON 发送 GET 请求并解析 JSON 响应
+func (c *Client) getJSON(path string, v interface{}) error {
+	body, err := c.get(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("解析 %s 响应失败: %w", path, err)
+	}
+	return nil
+}
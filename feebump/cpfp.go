@@ -0,0 +1,127 @@
+package feebump
+
+import (
+	"fmt"
+
+	"go-btc/coinselect"
+	"go-btc/policy"
+	"go-btc/txsize"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// CPFPParams 子交易的参数，费率单位为 sat/vB
+type CPFPParams struct {
+	Parent    *wire.MsgTx // 已签名的未确认父交易
+	ParentFee int64
+	Vout      uint32 // 子交易花费的父交易输出，必须属于本钱包
+
+	// OutputScript 子交易唯一输出的锁定脚本，通常是本钱包的找零地址
+	OutputScript []byte
+
+	FeeRate         int64 // 交易包的目标费率
+	MinRelayFeeRate int64 // 子交易自身的最低费率，为 0 时使用 policy.DefaultMinRelayFeeRate
+	MinOutput       int64 // 子交易输出的最小金额，低于该值时追加输入
+	Candidates      []Candidate
+
+	// LockTime 子交易的 nLockTime，通常为 timelock.AntiFeeSniping 返回的当前高度。
+	// 输入都声明可替换，nSequence 不是 0xffffffff，nLockTime 会生效
+	LockTime uint32
+}
+
+// CPFPResult 子交易
+type CPFPResult struct {
+	Tx           *wire.MsgTx // 未签名
+	PrevOuts     *txscript.MultiPrevOutFetcher
+	Fee          int64
+	Weight       int64 // 估算的签名后重量
+	AddedInputs  int
+	PackageFee   int64 // 父交易与子交易的手续费之和
+	PackageVSize int64 // 父交易与子交易的虚拟大小之和
+}
+
+// PackageFeeRate 交易包的费率 (sat/vB)
+func (r *CPFPResult) PackageFeeRate() float64 {
+	return float64(r.PackageFee) / float64(r.PackageVSize)
+}
+
+// CPFP 构造花费父交易输出的子交易，子交易声明可替换，子交易的手续费补足整个交易包按目标费率应付的手续费。
+// 只计算一层父交易，父交易本身还有未确认祖先时实际的交易包费率会更低。
+func CPFP(p CPFPParams) (*CPFPResult, error) {
+	if p.FeeRate <= 0 {
+		return nil, fmt.Errorf("费率必须大于 0")
+	}
+	if int(p.Vout) >= len(p.Parent.TxOut) {
+		return nil, fmt.Errorf("父交易没有输出 %d", p.Vout)
+	}
+	minRelay := p.MinRelayFeeRate
+	if minRelay == 0 {
+		minRelay = policy.DefaultMinRelayFeeRate
+	}
+
+	parentVSize := txsize.TxVSize(p.Parent)
+	if p.ParentFee >= p.FeeRate*parentVSize {
+		return nil, fmt.Errorf("父交易费率 %.2f sat/vB 已不低于目标费率 %d sat/vB",
+			float64(p.ParentFee)/float64(parentVSize), p.FeeRate)
+	}
+
+	parentOut := p.Parent.TxOut[p.Vout]
+	inputs := []Candidate{{
+		OutPoint: wire.OutPoint{Hash: p.Parent.TxHash(), Index: p.Vout},
+		PrevOut:  parentOut,
+	}}
+	inValue := parentOut.Value
+
+	// requiredFee 子交易需要支付的手续费：交易包按目标费率的手续费减去父交易已付的部分，
+	// 且子交易自身满足最低转发费率
+	requiredFee := func(weight int64) int64 {
+		fee := p.FeeRate*(parentVSize+txsize.VSize(weight)) - p.ParentFee
+		if minFee := coinselect.Fee(weight, minRelay); fee < minFee {
+			fee = minFee
+		}
+		return fee
+	}
+
+	candidates := append([]Candidate(nil), p.Candidates...)
+	sortLargestFirst(candidates)
+	added := 0
+	for {
+		weight, err := estimateWeight(inputs, nil, p.OutputScript, true)
+		if err != nil {
+			return nil, err
+		}
+		fee := requiredFee(weight)
+		if value := inValue - fee; value >= p.MinOutput && value > 0 {
+			tx := wire.NewMsgTx(2)
+			tx.LockTime = p.LockTime
+			fetcher := txscript.NewMultiPrevOutFetcher(nil)
+			for _, in := range inputs {
+				txIn := wire.NewTxIn(&in.OutPoint, nil, nil)
+				txIn.Sequence = policy.MaxRBFSequence
+				tx.AddTxIn(txIn)
+				fetcher.AddPrevOut(in.OutPoint, in.PrevOut)
+			}
+			tx.AddTxOut(wire.NewTxOut(value, p.OutputScript))
+			return &CPFPResult{
+				Tx:           tx,
+				PrevOuts:     fetcher,
+				Fee:          fee,
+				Weight:       weight,
+				AddedInputs:  added,
+				PackageFee:   p.ParentFee + fee,
+				PackageVSize: parentVSize + txsize.VSize(weight),
+			}, nil
+		}
+
+		if added == len(candidates) {
+			return nil, &coinselect.InsufficientFundsError{
+				Available: inValue,
+				Needed:    fee + p.MinOutput,
+			}
+		}
+		inputs = append(inputs, candidates[added])
+		inValue += candidates[added].PrevOut.Value
+		added++
+	}
+}
@@ -0,0 +1,211 @@
+// Package feebump 为卡在内存池中的交易提高手续费。
+//
+// RBF (BIP-125) 构造一笔花费相同输入、手续费更高的替换交易：先减少找零，
+// 找零不够时追加已确认的 UTXO。替换交易满足 BIP-125 的绝对手续费和增量转发费率规则。
+//
+// CPFP 花费未确认交易中属于本钱包的输出，构造一笔手续费足够高的子交易，
+// 让父子交易整体（交易包）的费率达到目标，矿工为了子交易的手续费会一起打包父交易。
+package feebump
+
+import (
+	"fmt"
+	"sort"
+
+	"go-btc/coinselect"
+	"go-btc/policy"
+	"go-btc/txsize"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Candidate 一个可追加到替换交易中的 UTXO
+type Candidate struct {
+	OutPoint wire.OutPoint
+	PrevOut  *wire.TxOut
+}
+
+// RBFParams 替换交易的参数，费率单位为 sat/vB
+type RBFParams struct {
+	Original    *wire.MsgTx
+	PrevOuts    txscript.PrevOutputFetcher // 原交易所有输入花费的输出
+	OriginalFee int64
+
+	// ChangeIndex 原交易中找零输出的下标，-1 表示没有找零
+	ChangeIndex int
+	// ChangeScript 原交易没有找零、追加输入后需要找零时使用的锁定脚本，为 nil 时不创建找零
+	ChangeScript []byte
+
+	FeeRate                 int64
+	IncrementalRelayFeeRate int64 // 为 0 时使用 policy.DefaultIncrementalRelayFeeRate
+	MinChange               int64 // 低于该值的找零并入手续费
+	Candidates              []Candidate
+}
+
+// RBFResult 替换交易
+type RBFResult struct {
+	Tx            *wire.MsgTx // 未签名
+	PrevOuts      *txscript.MultiPrevOutFetcher
+	Fee           int64
+	Weight        int64 // 估算的签名后重量
+	ChangeIndex   int   // 替换交易中找零输出的下标，-1 表示没有找零
+	AddedInputs   int
+	ChangeDropped int64 // 找零低于 MinChange 而并入手续费的金额
+}
+
+// RBF 构造替换交易。原交易的收款输出保持不变，只调整找零和输入。
+func RBF(p RBFParams) (*RBFResult, error) {
+	if p.FeeRate <= 0 {
+		return nil, fmt.Errorf("费率必须大于 0")
+	}
+	incremental := p.IncrementalRelayFeeRate
+	if incremental == 0 {
+		incremental = policy.DefaultIncrementalRelayFeeRate
+	}
+
+	var (
+		inputs   []Candidate
+		payments []*wire.TxOut
+		inValue  int64
+		payValue int64
+	)
+	for _, in := range p.Original.TxIn {
+		prevOut := p.PrevOuts.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			return nil, fmt.Errorf("无法获取输入 %s 的前一笔输出", in.PreviousOutPoint)
+		}
+		inputs = append(inputs, Candidate{OutPoint: in.PreviousOutPoint, PrevOut: prevOut})
+		inValue += prevOut.Value
+	}
+	changeScript := p.ChangeScript
+	for i, out := range p.Original.TxOut {
+		if i == p.ChangeIndex {
+			changeScript = out.PkScript
+			continue
+		}
+		payments = append(payments, out)
+		payValue += out.Value
+	}
+
+	// BIP-125 要求替换交易的费率高于原交易，Original 为已签名的交易
+	originalVSize := txsize.TxVSize(p.Original)
+	if p.FeeRate*originalVSize <= p.OriginalFee {
+		return nil, fmt.Errorf("新费率 %d sat/vB 必须高于原交易的 %.2f sat/vB",
+			p.FeeRate, float64(p.OriginalFee)/float64(originalVSize))
+	}
+
+	// requiredFee 满足目标费率和 BIP-125 规则的最低手续费
+	requiredFee := func(weight int64) int64 {
+		fee := coinselect.Fee(weight, p.FeeRate)
+		if minFee := policy.MinReplacementFee(p.OriginalFee, txsize.VSize(weight), incremental); fee < minFee {
+			fee = minFee
+		}
+		return fee
+	}
+
+	candidates := append([]Candidate(nil), p.Candidates...)
+	sortLargestFirst(candidates)
+	added := 0
+	for {
+		withChange, err := estimateWeight(inputs, payments, changeScript, true)
+		if err != nil {
+			return nil, err
+		}
+		if changeScript != nil {
+			if change := inValue - payValue - requiredFee(withChange); change >= p.MinChange && change > 0 {
+				return buildReplacement(p.Original, inputs, payments, &wire.TxOut{Value: change, PkScript: changeScript},
+					inValue-payValue-change, withChange, added, 0)
+			}
+		}
+
+		withoutChange, err := estimateWeight(inputs, payments, nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if excess := inValue - payValue - requiredFee(withoutChange); excess >= 0 {
+			var dropped int64
+			if changeScript != nil {
+				dropped = excess
+			}
+			return buildReplacement(p.Original, inputs, payments, nil,
+				inValue-payValue, withoutChange, added, dropped)
+		}
+
+		if added == len(candidates) {
+			return nil, &coinselect.InsufficientFundsError{
+				Available: inValue - payValue,
+				Needed:    requiredFee(withoutChange),
+			}
+		}
+		inputs = append(inputs, candidates[added])
+		inValue += candidates[added].PrevOut.Value
+		added++
+	}
+}
+
+// buildReplacement 组装替换交易，所有输入都声明可替换，便于再次提高手续费。
+// 原交易中已经声明可替换的 nSequence 原样保留，其中可能带有 BIP-68 相对时间锁，
+// 只有不可替换的值和新增的输入才设为 MaxRBFSequence
+func buildReplacement(original *wire.MsgTx, inputs []Candidate, payments []*wire.TxOut, change *wire.TxOut,
+	fee, weight int64, added int, dropped int64) (*RBFResult, error) {
+	sequences := make(map[wire.OutPoint]uint32, len(original.TxIn))
+	for _, in := range original.TxIn {
+		sequences[in.PreviousOutPoint] = in.Sequence
+	}
+
+	tx := wire.NewMsgTx(original.Version)
+	tx.LockTime = original.LockTime
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for _, in := range inputs {
+		txIn := wire.NewTxIn(&in.OutPoint, nil, nil)
+		txIn.Sequence = policy.MaxRBFSequence
+		if seq, ok := sequences[in.OutPoint]; ok && seq <= policy.MaxRBFSequence {
+			txIn.Sequence = seq
+		}
+		tx.AddTxIn(txIn)
+		fetcher.AddPrevOut(in.OutPoint, in.PrevOut)
+	}
+	for _, out := range payments {
+		tx.AddTxOut(wire.NewTxOut(out.Value, out.PkScript))
+	}
+	result := &RBFResult{
+		Tx:            tx,
+		PrevOuts:      fetcher,
+		Fee:           fee,
+		Weight:        weight,
+		ChangeIndex:   -1,
+		AddedInputs:   added,
+		ChangeDropped: dropped,
+	}
+	if change != nil {
+		result.ChangeIndex = len(tx.TxOut)
+		tx.AddTxOut(change)
+	}
+	return result, nil
+}
+
+// estimateWeight 估算签名后的交易重量
+func estimateWeight(inputs []Candidate, payments []*wire.TxOut, changeScript []byte, withChange bool) (int64, error) {
+	estimator := &txsize.Estimator{}
+	for _, in := range inputs {
+		input, err := txsize.InputForPkScript(in.PrevOut.PkScript)
+		if err != nil {
+			return 0, err
+		}
+		estimator.AddInput(input)
+	}
+	for _, out := range payments {
+		estimator.AddOutput(out.PkScript)
+	}
+	if withChange && changeScript != nil {
+		estimator.AddOutput(changeScript)
+	}
+	return estimator.Weight(), nil
+}
+
+// sortLargestFirst 按金额从大到小排序，追加的输入数量最少
+func sortLargestFirst(candidates []Candidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].PrevOut.Value > candidates[j].PrevOut.Value
+	})
+}
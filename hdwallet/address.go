@@ -0,0 +1,59 @@
+package hdwallet
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// AddressFromPubKey 根据 purpose 为公钥生成对应类型的地址。
+// BIP-49 地址会同时返回 P2SH 的赎回脚本，其余类型返回 nil。
+func AddressFromPubKey(purpose Purpose, publicKey *btcec.PublicKey, netParams *chaincfg.Params) (btcutil.Address, []byte, error) {
+	pubKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
+
+	switch purpose {
+	case PurposeBIP44:
+		addr, err := btcutil.NewAddressPubKeyHash(pubKeyHash, netParams)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建Legacy地址失败: %w", err)
+		}
+		return addr, nil, nil
+
+	case PurposeBIP49:
+		redeemScript, err := txscript.NewScriptBuilder().
+			AddOp(txscript.OP_0).
+			AddData(pubKeyHash).
+			Script()
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建赎回脚本失败: %w", err)
+		}
+		addr, err := btcutil.NewAddressScriptHash(redeemScript, netParams)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建P2SH地址失败: %w", err)
+		}
+		return addr, redeemScript, nil
+
+	case PurposeBIP84:
+		addr, err := btcutil.NewAddressWitnessPubKeyHash(pubKeyHash, netParams)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建Bech32地址失败: %w", err)
+		}
+		return addr, nil, nil
+
+	case PurposeBIP86:
+		addr, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(
+			txscript.ComputeTaprootKeyNoScript(publicKey)),
+			netParams,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("创建taprootAddress地址失败: %w", err)
+		}
+		return addr, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("不支持的 purpose: %d", uint32(purpose))
+}
@@ -0,0 +1,192 @@
+// Package hdwallet 提供基于 BIP-32 的分层确定性密钥派生，
+// 覆盖 BIP-44/49/84/86 四种地址类型，可指定任意 coin type、账户、链和地址索引。
+package hdwallet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"go-btc/mnemonic"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Key 派生结果：私钥、公钥、地址以及对应的锁定脚本。
+// 由观察钱包派生时 PrivKey 和 WIF 为 nil。
+type Key struct {
+	Path         Path
+	PrivKey      *btcec.PrivateKey
+	WIF          *btcutil.WIF
+	PubKey       *btcec.PublicKey
+	Address      btcutil.Address
+	PkScript     []byte
+	RedeemScript []byte // 仅 BIP-49 (P2SH-P2WPKH) 地址有赎回脚本
+}
+
+// Deriver 从主私钥派生任意 BIP-44 风格路径的密钥，可被多个 goroutine 共享
+type Deriver struct {
+	master    *hdkeychain.ExtendedKey
+	netParams *chaincfg.Params
+
+	mu       sync.Mutex
+	accounts map[[3]uint32]*hdkeychain.ExtendedKey // 账户级扩展私钥缓存
+}
+
+// NewDeriver 使用种子创建派生器
+func NewDeriver(seed []byte, netParams *chaincfg.Params) (*Deriver, error) {
+	masterKey, err := hdkeychain.NewMaster(seed, netParams)
+	if err != nil {
+		return nil, fmt.Errorf("创建主私钥失败: %w", err)
+	}
+
+	return &Deriver{
+		master:    masterKey,
+		netParams: netParams,
+		accounts:  make(map[[3]uint32]*hdkeychain.ExtendedKey),
+	}, nil
+}
+
+// NewDeriverFromMnemonic 使用助记词和可选的 BIP-39 密码创建派生器，
+// 密码不同会得到完全不同的钱包
+func NewDeriverFromMnemonic(words, passphrase string, netParams *chaincfg.Params) (*Deriver, error) {
+	seed := mnemonic.NewSeed(words, passphrase)
+	return NewDeriver(seed, netParams)
+}
+
+// NetParams 返回派生器使用的网络参数
+func (d *Deriver) NetParams() *chaincfg.Params {
+	return d.netParams
+}
+
+// MasterFingerprint 返回主公钥 hash160 的前 4 字节，用于确认助记词和密码输入正确
+func (d *Deriver) MasterFingerprint() uint32 {
+	pubKey, err := d.master.ECPubKey()
+	if err != nil {
+		// 主私钥在 NewMaster 中已校验，这里不会出错
+		panic(err)
+	}
+	return binary.BigEndian.Uint32(btcutil.Hash160(pubKey.SerializeCompressed())[:4])
+}
+
+// FormatFingerprint 将指纹格式化为钱包软件通用的 8 位十六进制形式
+func FormatFingerprint(fingerprint uint32) string {
+	return fmt.Sprintf("%08x", fingerprint)
+}
+
+// DerivePath 解析路径字符串并派生密钥
+func (d *Deriver) DerivePath(path string) (*Key, error) {
+	p, err := ParsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return d.Derive(p)
+}
+
+// Derive 派生指定路径的密钥、地址和锁定脚本
+func (d *Deriver) Derive(path Path) (*Key, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	accountKey, err := d.accountKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := accountKey
+	for _, childNum := range []uint32{path.Change, path.Index} {
+		key, err = key.Derive(childNum)
+		if err != nil {
+			return nil, fmt.Errorf("派生密钥失败: %w", err)
+		}
+	}
+
+	privateKey, err := key.ECPrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("获取私钥失败: %w", err)
+	}
+
+	wif, err := btcutil.NewWIF(privateKey, d.netParams, true)
+	if err != nil {
+		return nil, fmt.Errorf("创建WIF失败: %w", err)
+	}
+
+	publicKey := privateKey.PubKey()
+	addr, redeemScript, err := AddressFromPubKey(path.Purpose, publicKey, d.netParams)
+	if err != nil {
+		return nil, err
+	}
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("生成锁定脚本失败: %w", err)
+	}
+
+	return &Key{
+		Path:         path,
+		PrivKey:      privateKey,
+		WIF:          wif,
+		PubKey:       publicKey,
+		Address:      addr,
+		PkScript:     pkScript,
+		RedeemScript: redeemScript,
+	}, nil
+}
+
+// accountKey 派生并缓存账户级扩展私钥 m/purpose'/coin_type'/account'
+func (d *Deriver) accountKey(path Path) (*hdkeychain.ExtendedKey, error) {
+	id := [3]uint32{uint32(path.Purpose), path.CoinType, path.Account}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if key, ok := d.accounts[id]; ok {
+		return key, nil
+	}
+
+	key := d.master
+	for _, childNum := range path.AccountIndexes() {
+		var err error
+		key, err = key.Derive(childNum)
+		if err != nil {
+			return nil, fmt.Errorf("派生密钥失败: %w", err)
+		}
+	}
+	d.accounts[id] = key
+	return key, nil
+}
+
+// Account 派生器上的一个账户 m/purpose'/coin_type'/account'，
+// 与 WatchOnly 一样按链和索引派生地址
+type Account struct {
+	deriver  *Deriver
+	purpose  Purpose
+	coinType uint32
+	account  uint32
+}
+
+// Account 返回指定账户
+func (d *Deriver) Account(purpose Purpose, coinType, account uint32) *Account {
+	return &Account{deriver: d, purpose: purpose, coinType: coinType, account: account}
+}
+
+// Purpose 返回账户的地址类型
+func (a *Account) Purpose() Purpose {
+	return a.purpose
+}
+
+// Derive 派生账户下指定链和索引的密钥
+func (a *Account) Derive(change, index uint32) (*Key, error) {
+	return a.deriver.Derive(Path{
+		Purpose:  a.purpose,
+		CoinType: a.coinType,
+		Account:  a.account,
+		Change:   change,
+		Index:    index,
+	})
+}
@@ -0,0 +1,173 @@
+package hdwallet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+)
+
+// Purpose BIP-43 中的 purpose 字段，决定派生出的地址脚本类型
+type Purpose uint32
+
+const (
+	PurposeBIP44 Purpose = 44 // Legacy 地址 (P2PKH)
+	PurposeBIP49 Purpose = 49 // 兼容 SegWit 地址 (P2SH-P2WPKH)
+	PurposeBIP84 Purpose = 84 // Native SegWit 地址 (P2WPKH)
+	PurposeBIP86 Purpose = 86 // Taproot 地址 (P2TR)
+)
+
+// 链类型：外部链用于收款地址，内部链用于找零地址
+const (
+	ChainExternal uint32 = 0
+	ChainInternal uint32 = 1
+)
+
+// Valid 判断是否为支持的 purpose
+func (p Purpose) Valid() bool {
+	switch p {
+	case PurposeBIP44, PurposeBIP49, PurposeBIP84, PurposeBIP86:
+		return true
+	}
+	return false
+}
+
+// String 返回 purpose 对应的脚本类型名称
+func (p Purpose) String() string {
+	switch p {
+	case PurposeBIP44:
+		return "p2pkh"
+	case PurposeBIP49:
+		return "p2sh-p2wpkh"
+	case PurposeBIP84:
+		return "p2wpkh"
+	case PurposeBIP86:
+		return "p2tr"
+	}
+	return fmt.Sprintf("purpose(%d)", uint32(p))
+}
+
+// Path BIP-44 风格的五级派生路径 m / purpose' / coin_type' / account' / change / index
+type Path struct {
+	Purpose  Purpose
+	CoinType uint32
+	Account  uint32
+	Change   uint32
+	Index    uint32
+}
+
+// ParsePath 解析形如 "m/86'/1'/0'/0/5" 的路径字符串，硬化标记支持 ' 和 h
+func ParsePath(s string) (Path, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) != 6 || parts[0] != "m" {
+		return Path{}, fmt.Errorf("路径格式错误 %q: 需要 m/purpose'/coin'/account'/change/index", s)
+	}
+
+	var values [5]uint32
+	for i, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		// 前三级必须硬化派生，后两级必须普通派生
+		if hardened != (i < 3) {
+			return Path{}, fmt.Errorf("路径 %q 第 %d 级的硬化标记错误", s, i+1)
+		}
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || v >= hdkeychain.HardenedKeyStart {
+			return Path{}, fmt.Errorf("路径 %q 第 %d 级的索引无效", s, i+1)
+		}
+		values[i] = uint32(v)
+	}
+
+	path := Path{
+		Purpose:  Purpose(values[0]),
+		CoinType: values[1],
+		Account:  values[2],
+		Change:   values[3],
+		Index:    values[4],
+	}
+	if err := path.Validate(); err != nil {
+		return Path{}, err
+	}
+	return path, nil
+}
+
+// Validate 校验路径各级取值
+func (p Path) Validate() error {
+	if !p.Purpose.Valid() {
+		return fmt.Errorf("不支持的 purpose: %d", uint32(p.Purpose))
+	}
+	if p.CoinType >= hdkeychain.HardenedKeyStart || p.Account >= hdkeychain.HardenedKeyStart {
+		return fmt.Errorf("coin type 或 account 超出范围")
+	}
+	if p.Change != ChainExternal && p.Change != ChainInternal {
+		return fmt.Errorf("change 只能为 0 或 1, 实际为 %d", p.Change)
+	}
+	if p.Index >= hdkeychain.HardenedKeyStart {
+		return fmt.Errorf("地址索引超出范围: %d", p.Index)
+	}
+	return nil
+}
+
+// AccountIndexes 返回账户级的三个硬化派生索引
+func (p Path) AccountIndexes() []uint32 {
+	return []uint32{
+		uint32(p.Purpose) + hdkeychain.HardenedKeyStart, // purpose
+		p.CoinType + hdkeychain.HardenedKeyStart,        // coin type
+		p.Account + hdkeychain.HardenedKeyStart,         // account
+	}
+}
+
+// Indexes 返回完整的五级派生索引
+func (p Path) Indexes() []uint32 {
+	return append(p.AccountIndexes(),
+		p.Change, // external / internal chain
+		p.Index,  // address index
+	)
+}
+
+// String 返回路径的字符串形式，例如 m/86'/1'/0'/0/5
+func (p Path) String() string {
+	return fmt.Sprintf("m/%d'/%d'/%d'/%d/%d", uint32(p.Purpose), p.CoinType, p.Account, p.Change, p.Index)
+}
+
+// MarshalText 以路径字符串形式编码，便于保存到 JSON
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText 从路径字符串解码
+func (p *Path) UnmarshalText(text []byte) error {
+	path, err := ParsePath(string(text))
+	if err != nil {
+		return err
+	}
+	*p = path
+	return nil
+}
+
+// PathFromIndexes 由五级派生索引还原路径，是 Indexes 的逆操作，
+// 用于解析 PSBT 中的 BIP-32 派生信息
+func PathFromIndexes(indexes []uint32) (Path, error) {
+	if len(indexes) != 5 {
+		return Path{}, fmt.Errorf("派生路径应为 5 级，实际为 %d 级", len(indexes))
+	}
+	for i, index := range indexes {
+		if (index >= hdkeychain.HardenedKeyStart) != (i < 3) {
+			return Path{}, fmt.Errorf("派生路径第 %d 级的硬化标记错误", i+1)
+		}
+	}
+	path := Path{
+		Purpose:  Purpose(indexes[0] - hdkeychain.HardenedKeyStart),
+		CoinType: indexes[1] - hdkeychain.HardenedKeyStart,
+		Account:  indexes[2] - hdkeychain.HardenedKeyStart,
+		Change:   indexes[3],
+		Index:    indexes[4],
+	}
+	if err := path.Validate(); err != nil {
+		return Path{}, err
+	}
+	return path, nil
+}
@@ -0,0 +1,216 @@
+package hdwallet
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// slip132Version SLIP-132 定义的扩展公钥版本字节
+type slip132Version struct {
+	purpose Purpose
+	mainnet bool
+	prefix  string
+	pub     [4]byte
+}
+
+// slip132Versions 各 purpose 在主网和测试网下的扩展公钥版本字节。
+// BIP-86 没有单独注册版本，沿用 xpub/tpub。
+var slip132Versions = []slip132Version{
+	{PurposeBIP44, true, "xpub", [4]byte{0x04, 0x88, 0xb2, 0x1e}},
+	{PurposeBIP49, true, "ypub", [4]byte{0x04, 0x9d, 0x7c, 0xb2}},
+	{PurposeBIP84, true, "zpub", [4]byte{0x04, 0xb2, 0x47, 0x46}},
+	{PurposeBIP86, true, "xpub", [4]byte{0x04, 0x88, 0xb2, 0x1e}},
+	{PurposeBIP44, false, "tpub", [4]byte{0x04, 0x35, 0x87, 0xcf}},
+	{PurposeBIP49, false, "upub", [4]byte{0x04, 0x4a, 0x52, 0x62}},
+	{PurposeBIP84, false, "vpub", [4]byte{0x04, 0x5f, 0x1c, 0xf6}},
+	{PurposeBIP86, false, "tpub", [4]byte{0x04, 0x35, 0x87, 0xcf}},
+}
+
+// isMainnet 判断网络是否使用主网的扩展密钥版本
+func isMainnet(netParams *chaincfg.Params) bool {
+	return netParams.HDPublicKeyID == chaincfg.MainNetParams.HDPublicKeyID
+}
+
+// xpubVersion 返回 purpose 在指定网络下的 SLIP-132 扩展公钥版本
+func xpubVersion(purpose Purpose, netParams *chaincfg.Params) ([]byte, error) {
+	mainnet := isMainnet(netParams)
+	for _, v := range slip132Versions {
+		if v.purpose == purpose && v.mainnet == mainnet {
+			return v.pub[:], nil
+		}
+	}
+	return nil, fmt.Errorf("不支持的 purpose: %d", uint32(purpose))
+}
+
+// AccountPublicKey 返回账户级扩展公钥 m/purpose'/coin_type'/account'，使用网络标准版本 (xpub/tpub)
+func (d *Deriver) AccountPublicKey(purpose Purpose, coinType, account uint32) (*hdkeychain.ExtendedKey, error) {
+	path := Path{Purpose: purpose, CoinType: coinType, Account: account}
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	accountKey, err := d.accountKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, err := accountKey.Neuter()
+	if err != nil {
+		return nil, fmt.Errorf("生成扩展公钥失败: %w", err)
+	}
+	return pubKey, nil
+}
+
+// AccountXPub 导出账户级扩展公钥，版本字节遵循 SLIP-132：
+// BIP-44/86 为 xpub/tpub，BIP-49 为 ypub/upub，BIP-84 为 zpub/vpub
+func (d *Deriver) AccountXPub(purpose Purpose, coinType, account uint32) (string, error) {
+	pubKey, err := d.AccountPublicKey(purpose, coinType, account)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := xpubVersion(purpose, d.netParams)
+	if err != nil {
+		return "", err
+	}
+
+	pubKey, err = pubKey.CloneWithVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("设置扩展公钥版本失败: %w", err)
+	}
+	return pubKey.String(), nil
+}
+
+// WatchOnly 只持有账户级扩展公钥的观察钱包，可以派生收款和找零地址但无法签名
+type WatchOnly struct {
+	accountKey *hdkeychain.ExtendedKey
+	purpose    Purpose
+	account    uint32
+	netParams  *chaincfg.Params
+}
+
+// NewWatchOnly 从账户级扩展公钥创建观察钱包。
+// purpose 为 0 时根据 SLIP-132 版本推断，xpub/tpub 默认视为 BIP-44；
+// 导出 BIP-86 账户的 xpub/tpub 时需要显式传入 PurposeBIP86。
+func NewWatchOnly(extendedKey string, purpose Purpose, netParams *chaincfg.Params) (*WatchOnly, error) {
+	key, err := hdkeychain.NewKeyFromString(extendedKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析扩展公钥失败: %w", err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("观察钱包只接受扩展公钥，不要传入扩展私钥")
+	}
+	if key.Depth() != 3 {
+		return nil, fmt.Errorf("需要账户级扩展公钥 (深度 3)，实际深度为 %d", key.Depth())
+	}
+
+	var matched []slip132Version
+	for _, v := range slip132Versions {
+		if bytes.Equal(key.Version(), v.pub[:]) {
+			matched = append(matched, v)
+		}
+	}
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("未知的扩展公钥版本: %x", key.Version())
+	}
+	if matched[0].mainnet != isMainnet(netParams) {
+		return nil, fmt.Errorf("扩展公钥 (%s) 与网络 %s 不匹配", matched[0].prefix, netParams.Name)
+	}
+
+	if purpose == 0 {
+		purpose = matched[0].purpose
+	}
+	found := false
+	for _, v := range matched {
+		if v.purpose == purpose {
+			found = true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("扩展公钥 (%s) 不能用于 %s 地址", matched[0].prefix, purpose)
+	}
+
+	// 统一转换为网络标准版本，便于之后导出 xpub/tpub
+	key, err = key.CloneWithVersion(netParams.HDPublicKeyID[:])
+	if err != nil {
+		return nil, fmt.Errorf("设置扩展公钥版本失败: %w", err)
+	}
+
+	account := key.ChildIndex()
+	if account >= hdkeychain.HardenedKeyStart {
+		account -= hdkeychain.HardenedKeyStart
+	}
+
+	return &WatchOnly{
+		accountKey: key,
+		purpose:    purpose,
+		account:    account,
+		netParams:  netParams,
+	}, nil
+}
+
+// Purpose 返回观察钱包的地址类型
+func (w *WatchOnly) Purpose() Purpose {
+	return w.purpose
+}
+
+// Account 返回扩展公钥记录的账户索引
+func (w *WatchOnly) Account() uint32 {
+	return w.account
+}
+
+// AccountKey 返回账户级扩展公钥 (xpub/tpub 版本)
+func (w *WatchOnly) AccountKey() *hdkeychain.ExtendedKey {
+	return w.accountKey
+}
+
+// Derive 派生指定链和索引的公钥、地址和锁定脚本，返回的 Key 不含私钥。
+// 扩展公钥本身不记录 coin type，路径中按网络默认值填写。
+func (w *WatchOnly) Derive(change, index uint32) (*Key, error) {
+	path := Path{
+		Purpose:  w.purpose,
+		CoinType: w.netParams.HDCoinType,
+		Account:  w.account,
+		Change:   change,
+		Index:    index,
+	}
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := w.accountKey
+	for _, childNum := range []uint32{change, index} {
+		var err error
+		key, err = key.Derive(childNum)
+		if err != nil {
+			return nil, fmt.Errorf("派生公钥失败: %w", err)
+		}
+	}
+
+	publicKey, err := key.ECPubKey()
+	if err != nil {
+		return nil, fmt.Errorf("获取公钥失败: %w", err)
+	}
+
+	addr, redeemScript, err := AddressFromPubKey(w.purpose, publicKey, w.netParams)
+	if err != nil {
+		return nil, err
+	}
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("生成锁定脚本失败: %w", err)
+	}
+
+	return &Key{
+		Path:         path,
+		PubKey:       publicKey,
+		Address:      addr,
+		PkScript:     pkScript,
+		RedeemScript: redeemScript,
+	}, nil
+}
@@ -6,35 +6,26 @@ import (
 	"log"
 	"os"
 
+	"go-btc/hdwallet"
+	"go-btc/mnemonic"
+
 	"github.com/btcsuite/btcd/btcec/v2"
-	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/btcutil/hdkeychain"
 	"github.com/btcsuite/btcd/chaincfg"
-	"github.com/btcsuite/btcd/rpcclient"
-	"github.com/btcsuite/btcd/txscript"
 	"github.com/joho/godotenv"
-
-	"github.com/tyler-smith/go-bip39"
 )
 
-// GenerateMnemonic 生成助记词
-func GenerateMnemonic() (string, error) {
-	// 生成助记词
-	entropy, err := bip39.NewEntropy(128) // 128-bit entropy, 可以使用 256-bit
-	if err != nil {
-		return "", fmt.Errorf("生成熵失败: %w", err)
-	}
-
-	mnemonic, err := bip39.NewMnemonic(entropy)
+// GenerateMnemonic 生成助记词，bits 为 128~256 位熵，lang 为词表语言
+func GenerateMnemonic(bits int, lang mnemonic.Language) (string, error) {
+	words, err := mnemonic.Generate(bits, lang)
 	if err != nil {
 		return "", fmt.Errorf("生成助记词失败: %w", err)
 	}
 
-	return mnemonic, nil
+	return words, nil
 }
 
-// GetMnemonicFromENV 从环境变量中获取助记词
+// GetMnemonicFromENV 从环境变量中获取助记词，并校验 BIP-39 词表和校验和
 func GetMnemonicFromENV() (string, error) {
 	// 加载 .env 文件
 	err := godotenv.Load(".env")
@@ -42,59 +33,38 @@ func GetMnemonicFromENV() (string, error) {
 		log.Fatal("Error loading .env file")
 	}
 
-	mnemonic := os.Getenv("MNEMONIC")
-	if mnemonic == "" {
+	words := os.Getenv("MNEMONIC")
+	if words == "" {
 		return "", fmt.Errorf("助记词为空")
 	}
-	return mnemonic, nil
-}
-
-func GenerateTaprootAddress(mnemonic string, netParams *chaincfg.Params, addressIndex uint32) (*btcutil.WIF, *btcec.PublicKey, btcutil.Address, error) {
-	seed := bip39.NewSeed(mnemonic, "")
-
-	masterKey, err := hdkeychain.NewMaster(seed, netParams)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建主私钥失败: %w", err)
-	}
-
-	path := []uint32{
-		86 + hdkeychain.HardenedKeyStart, // purpose
-		0 + hdkeychain.HardenedKeyStart,  // coin type
-		0 + hdkeychain.HardenedKeyStart,  // account
-		0,                                // external chain
-		addressIndex,                     // address index
-	}
-
-	key := masterKey
-	for _, childNum := range path {
-		key, err = key.Derive(childNum)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("派生密钥失败: %w", err)
-		}
-	}
 
-	privateKey, err := key.ECPrivKey()
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("获取私钥失败: %w", err)
+	// 校验单词和校验和，避免抄错的助记词派生出一个空钱包
+	if _, err := mnemonic.Validate(words); err != nil {
+		return "", fmt.Errorf("助记词无效: %w", err)
 	}
+	return words, nil
+}
 
-	wif, err := btcutil.NewWIF(privateKey, netParams, true)
+// GenerateTaprootAddress 派生 BIP-86 路径 m/86'/coin_type'/0'/0/addressIndex 下的 Taproot 地址，
+// coin type 由网络决定（主网 0，测试网络 1），passphrase 为 BIP-39 助记词密码，没有设置时传空字符串
+func GenerateTaprootAddress(mnemonic, passphrase string, netParams *chaincfg.Params, addressIndex uint32) (*btcutil.WIF, *btcec.PublicKey, btcutil.Address, error) {
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, netParams)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建WIF失败: %w", err)
+		return nil, nil, nil, err
 	}
 
-	publicKey := privateKey.PubKey()
-	// pubKeyHash := btcutil.Hash160(publicKey.SerializeCompressed())
-
-	taprootAddress, err := btcutil.NewAddressTaproot(schnorr.SerializePubKey(
-		txscript.ComputeTaprootKeyNoScript(wif.PrivKey.PubKey())),
-		netParams,
-	)
+	key, err := deriver.Derive(hdwallet.Path{
+		Purpose:  hdwallet.PurposeBIP86,
+		CoinType: netParams.HDCoinType,
+		Account:  0,
+		Change:   hdwallet.ChainExternal,
+		Index:    addressIndex,
+	})
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("创建taprootAddress地址失败: %w", err)
+		return nil, nil, nil, err
 	}
 
-	return wif, publicKey, taprootAddress, nil
+	return key.WIF, key.PubKey, key.Address, nil
 }
 
 func PrintResults(mnemonic string, wif *btcutil.WIF, publicKey *btcec.PublicKey, bech32Address btcutil.Address) {
@@ -104,18 +74,3 @@ func PrintResults(mnemonic string, wif *btcutil.WIF, publicKey *btcec.PublicKey,
 	fmt.Printf("公钥 (非压缩格式): %s\n", hex.EncodeToString(publicKey.SerializeUncompressed()))
 	fmt.Println("地址:", bech32Address.EncodeAddress())
 }
-
-func NewClient(url, user, pass string) (*rpcclient.Client, error) {
-	connCfg := &rpcclient.ConnConfig{
-		Host:         url, // 不加 https://
-		User:         user,
-		Pass:         pass,
-		HTTPPostMode: true,
-		DisableTLS:   false, // 使用 https
-	}
-	client, err := rpcclient.New(connCfg, nil)
-	if err != nil {
-		log.Fatalf("连接到 RPC 节点失败: %v", err)
-	}
-	return client, nil
-}
@@ -0,0 +1,51 @@
+package helper
+
+import (
+	"flag"
+	"os"
+
+	"go-btc/network"
+
+	"github.com/joho/godotenv"
+)
+
+// NetworkOptions 命令行中与网络相关的参数
+type NetworkOptions struct {
+	Name            string
+	SignetChallenge string
+	EsploraURL      string
+}
+
+// RegisterNetworkFlags 在默认 FlagSet 上注册 -network、-signet-challenge 和 -esplora 参数
+func RegisterNetworkFlags() *NetworkOptions {
+	opts := &NetworkOptions{}
+	flag.StringVar(&opts.Name, "network", "", "比特币网络: mainnet, testnet3, testnet4, signet, regtest，默认读取 BTC_NETWORK，未设置时为 testnet3")
+	flag.StringVar(&opts.SignetChallenge, "signet-challenge", "", "自定义 signet 的挑战脚本 (十六进制)，默认读取 SIGNET_CHALLENGE")
+	flag.StringVar(&opts.EsploraURL, "esplora", "", "mempool.space 风格的 API 地址，默认读取 ESPLORA_URL")
+	return opts
+}
+
+// Network 解析网络配置，命令行参数优先于环境变量和 .env 文件
+func (o *NetworkOptions) Network() (*network.Network, error) {
+	_ = godotenv.Load(".env")
+
+	name := firstNonEmpty(o.Name, os.Getenv("BTC_NETWORK"), network.TestNet3)
+
+	// 环境变量中的挑战脚本只对 signet 生效，命令行参数则总是传入以便报错
+	challenge := o.SignetChallenge
+	if challenge == "" && name == network.SigNet {
+		challenge = os.Getenv("SIGNET_CHALLENGE")
+	}
+
+	return network.Parse(name, challenge, firstNonEmpty(o.EsploraURL, os.Getenv("ESPLORA_URL")))
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
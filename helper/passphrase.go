@@ -0,0 +1,98 @@
+package helper
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// 助记词密码（BIP-39 passphrase，俗称"第 25 个词"）的来源
+const (
+	PassphraseNone   = "none"   // 不使用密码
+	PassphraseEnv    = "env"    // 从环境变量 BIP39_PASSPHRASE 读取，未设置时视为空
+	PassphrasePrompt = "prompt" // 在终端中输入，不回显
+	PassphraseStdin  = "stdin"  // 从标准输入读取第一行
+)
+
+// stdin 标准输入的唯一读取器。每次新建 bufio.Reader 会把后面几行读进缓冲区后丢掉，
+// 通过管道依次输入 keystore 口令、助记词密码和确认时，后面的读取会直接遇到 EOF
+var stdin = bufio.NewReader(os.Stdin)
+
+// GetPassphrase 按指定来源获取 BIP-39 助记词密码
+func GetPassphrase(source string) (string, error) {
+	switch source {
+	case "", PassphraseNone:
+		return "", nil
+
+	case PassphraseEnv:
+		// 与 GetMnemonicFromENV 共用 .env 文件，文件不存在时只读取进程环境变量
+		_ = godotenv.Load(".env")
+		return os.Getenv("BIP39_PASSPHRASE"), nil
+
+	case PassphrasePrompt:
+		return promptSecret("请输入助记词密码: ")
+
+	case PassphraseStdin:
+		// 空行表示密码为空，没有任何输入则是调用方忘了提供密码，不能当作空密码派生出另一个钱包
+		passphrase, err := readLine()
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("标准输入中没有助记词密码")
+		}
+		return passphrase, err
+	}
+
+	return "", fmt.Errorf("未知的助记词密码来源: %s", source)
+}
+
+// promptSecret 在终端中提示并读取一行不回显的输入，标准输入不是终端时按普通行读取
+func promptSecret(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		secret, err := readLine()
+		if errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("读取输入失败: 标准输入已结束")
+		}
+		return secret, err
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("读取输入失败: %w", err)
+	}
+	return string(secret), nil
+}
+
+// Confirm 在终端提示并等待输入 y 或 yes 确认，其它输入视为取消
+func Confirm(prompt string) (bool, error) {
+	fmt.Fprint(os.Stderr, prompt+" [y/N]: ")
+	answer, err := readLine()
+	if errors.Is(err, io.EOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// readLine 从标准输入读取一行并去掉行尾换行符，密码中的首尾空格会被保留。
+// 最后一行可以没有换行符；没有读到任何内容时返回 io.EOF
+func readLine() (string, error) {
+	line, err := stdin.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("读取输入失败: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
@@ -0,0 +1,101 @@
+package helper
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/btcsuite/btcd/rpcclient"
+	"github.com/joho/godotenv"
+)
+
+// RPCOptions 命令行中连接 Bitcoin Core 节点的参数
+type RPCOptions struct {
+	URL  string
+	User string
+	Pass string
+}
+
+// RegisterRPCFlags 在默认 FlagSet 上注册 -rpc-url、-rpc-user 和 -rpc-pass 参数
+func RegisterRPCFlags() *RPCOptions {
+	opts := &RPCOptions{}
+	flag.StringVar(&opts.URL, "rpc-url", "", "Bitcoin Core RPC 地址，http:// 开头时不使用 TLS，默认读取 RPC_URL")
+	flag.StringVar(&opts.User, "rpc-user", "", "RPC 用户名，默认读取 RPC_USER，未设置时为 user")
+	flag.StringVar(&opts.Pass, "rpc-pass", "", "RPC 密码，默认读取 RPC_PASS，未设置时为 pass")
+	return opts
+}
+
+// Client 连接 RPC 节点，命令行参数优先于环境变量和 .env 文件
+func (o *RPCOptions) Client() (*rpcclient.Client, error) {
+	_ = godotenv.Load(".env")
+
+	url := firstNonEmpty(o.URL, os.Getenv("RPC_URL"))
+	if url == "" {
+		return nil, fmt.Errorf("请通过 -rpc-url 或 RPC_URL 指定 RPC 节点")
+	}
+	connCfg := &rpcclient.ConnConfig{
+		Host:         url,
+		User:         firstNonEmpty(o.User, os.Getenv("RPC_USER"), "user"),
+		Pass:         firstNonEmpty(o.Pass, os.Getenv("RPC_PASS"), "pass"),
+		HTTPPostMode: true,
+	}
+	switch {
+	case strings.HasPrefix(url, "http://"):
+		connCfg.Host = strings.TrimPrefix(url, "http://")
+		connCfg.DisableTLS = true
+	case strings.HasPrefix(url, "https://"):
+		connCfg.Host = strings.TrimPrefix(url, "https://")
+	}
+	client, err := rpcclient.New(connCfg, nil)
+	if err != nil {
+		return nil, fmt.Errorf("连接到 RPC 节点失败: %w", err)
+	}
+	return client, nil
+}
+
+// PackageTxResult submitpackage 返回的单笔交易结果
+type PackageTxResult struct {
+	TxID  string `json:"txid"`
+	VSize int64  `json:"vsize"`
+	Fees  struct {
+		Base             float64 `json:"base"`
+		EffectiveFeeRate float64 `json:"effective-feerate"`
+	} `json:"fees"`
+	Error string `json:"error"`
+}
+
+// PackageResult submitpackage 的返回值，TxResults 以 wtxid 为键
+type PackageResult struct {
+	PackageMsg           string                     `json:"package_msg"`
+	TxResults            map[string]PackageTxResult `json:"tx-results"`
+	ReplacedTransactions []string                   `json:"replaced-transactions"`
+}
+
+// SubmitPackage 通过 submitpackage（Bitcoin Core 26 及以上）把一组十六进制原始交易作为交易包提交，
+// 交易按父在前、子在后的顺序排列。父交易费率低于节点内存池最低费率时也能随子交易一起被接受。
+func SubmitPackage(client *rpcclient.Client, rawTxs ...string) (*PackageResult, error) {
+	param, err := json.Marshal(rawTxs)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.RawRequest("submitpackage", []json.RawMessage{param})
+	if err != nil {
+		return nil, fmt.Errorf("submitpackage 失败: %w", err)
+	}
+	var result PackageResult
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, fmt.Errorf("解析 submitpackage 响应失败: %w", err)
+	}
+	if result.PackageMsg != "success" {
+		var errs []string
+		for wtxid, tx := range result.TxResults {
+			if tx.Error != "" {
+				errs = append(errs, fmt.Sprintf("%s: %s", wtxid, tx.Error))
+			}
+		}
+		return &result, fmt.Errorf("交易包未被接受: %s %s", result.PackageMsg, strings.Join(errs, "; "))
+	}
+	return &result, nil
+}
@@ -0,0 +1,105 @@
+package helper
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"go-btc/keystore"
+
+	"github.com/joho/godotenv"
+)
+
+// SecretOptions 命令行中与助记词来源相关的参数
+type SecretOptions struct {
+	Keystore       string // keystore 文件路径，为空时读取环境变量 KEYSTORE
+	PasswordFile   string // keystore 口令文件，为空时读取环境变量 KEYSTORE_PASSWORD_FILE，仍为空则在终端输入
+	PassphraseFrom string // 助记词密码来源，keystore 中已保存密码时忽略
+}
+
+// RegisterSecretFlags 在默认 FlagSet 上注册 -keystore、-password-file 和 -passphrase 参数
+func RegisterSecretFlags() *SecretOptions {
+	opts := &SecretOptions{}
+	flag.StringVar(&opts.Keystore, "keystore", "", "加密 keystore 文件路径，不指定时读取 .env 中的 MNEMONIC")
+	flag.StringVar(&opts.PasswordFile, "password-file", "", "keystore 口令文件，不指定时在终端输入")
+	flag.StringVar(&opts.PassphraseFrom, "passphrase", PassphraseEnv, "助记词密码来源: none, env, prompt, stdin")
+	return opts
+}
+
+// Load 获取助记词和助记词密码：指定了 keystore 时解密 keystore，否则读取 .env 中的 MNEMONIC
+func (o *SecretOptions) Load() (string, string, error) {
+	// 加载 .env 文件，其中可以配置 KEYSTORE 和 KEYSTORE_PASSWORD_FILE
+	_ = godotenv.Load(".env")
+
+	path := o.Keystore
+	if path == "" {
+		path = os.Getenv("KEYSTORE")
+	}
+	if path == "" {
+		mnemonic, err := GetMnemonicFromENV()
+		if err != nil {
+			return "", "", err
+		}
+		passphrase, err := GetPassphrase(o.PassphraseFrom)
+		if err != nil {
+			return "", "", fmt.Errorf("获取助记词密码失败: %w", err)
+		}
+		return mnemonic, passphrase, nil
+	}
+
+	passwordFile := o.PasswordFile
+	if passwordFile == "" {
+		passwordFile = os.Getenv("KEYSTORE_PASSWORD_FILE")
+	}
+
+	var password []byte
+	var err error
+	if passwordFile != "" {
+		password, err = ReadPasswordFile(passwordFile)
+	} else {
+		password, err = ReadPassword("请输入 keystore 口令: ")
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	secret, err := keystore.Unlock(path, password)
+	if err != nil {
+		return "", "", err
+	}
+
+	passphrase := secret.Passphrase
+	if passphrase == "" {
+		passphrase, err = GetPassphrase(o.PassphraseFrom)
+		if err != nil {
+			return "", "", fmt.Errorf("获取助记词密码失败: %w", err)
+		}
+	}
+	return secret.Mnemonic, passphrase, nil
+}
+
+// ReadPassword 在终端中提示并读取口令，不回显
+func ReadPassword(prompt string) ([]byte, error) {
+	password, err := promptSecret(prompt)
+	if err != nil {
+		return nil, err
+	}
+	if password == "" {
+		return nil, fmt.Errorf("口令不能为空")
+	}
+	return []byte(password), nil
+}
+
+// ReadPasswordFile 从文件读取口令，去掉末尾的换行符
+func ReadPasswordFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取口令文件失败: %w", err)
+	}
+	password := bytes.TrimRight(data, "\r\n")
+	if len(password) == 0 {
+		return nil, fmt.Errorf("口令文件为空: %s", path)
+	}
+	return password, nil
+}
@@ -0,0 +1,91 @@
+// Package fileutil 提供钱包数据库和 keystore 共用的文件操作：原子写入、
+// 只在文件不存在时创建，以及跨进程的文件锁。
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrExist 目标文件已存在，WriteNew 不会覆盖它
+var ErrExist = errors.New("文件已存在")
+
+// WriteAtomic 先写入同目录下的临时文件再重命名，保证文件要么是旧内容要么是完整的新内容
+func WriteAtomic(path string, data []byte) error {
+	tmp, err := writeTemp(path, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("替换文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// WriteNew 与 WriteAtomic 一样先写临时文件，再用硬链接放到目标路径。
+// 链接在目标已存在时失败，所以即使其他进程同时创建同名文件，也不会覆盖已有内容。
+func WriteNew(path string, data []byte) error {
+	tmp, err := writeTemp(path, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	if err := os.Link(tmp, path); err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("%w: %s", ErrExist, path)
+		}
+		return fmt.Errorf("创建文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// writeTemp 在目标文件所在目录写入权限为 0600 的临时文件并同步到磁盘，返回临时文件路径
+func writeTemp(path string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return "", fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	name := tmp.Name()
+	fail := func(format string, err error) (string, error) {
+		tmp.Close()
+		os.Remove(name)
+		return "", fmt.Errorf(format, err)
+	}
+
+	if err := tmp.Chmod(0600); err != nil {
+		return fail("设置文件权限失败: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fail("写入临时文件失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail("同步临时文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(name)
+		return "", fmt.Errorf("关闭临时文件失败: %w", err)
+	}
+	return name, nil
+}
+
+// Lock 以独占方式锁定 path（不存在时创建），阻塞直到其他进程释放，返回释放锁的函数。
+// 锁由操作系统维护，进程退出后自动释放，不会留下失效的锁。
+func Lock(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开锁文件失败: %w", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("锁定 %s 失败: %w", path, err)
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
@@ -0,0 +1,170 @@
+// Package keyring 维护本钱包派生过的密钥，按锁定脚本找回对应的派生路径和私钥。
+//
+// 花费时每个输入都用其所在地址自己的私钥签名，输入可以分布在多个地址类型、
+// 收款链和找零链的任意地址上。Keyring 实现 signer.Keys，可直接交给 signer.Sign。
+// 密钥来自助记词派生器，或者只有公钥的观察钱包 (WatchOnly)，后者用于在联网机器上创建待签名交易。
+package keyring
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go-btc/hdwallet"
+	"go-btc/walletdb"
+)
+
+// Purposes 钱包使用的全部地址类型
+var Purposes = []hdwallet.Purpose{
+	hdwallet.PurposeBIP44,
+	hdwallet.PurposeBIP49,
+	hdwallet.PurposeBIP84,
+	hdwallet.PurposeBIP86,
+}
+
+// Source 按完整路径派生密钥。*hdwallet.Deriver 派生的密钥带私钥，WatchOnly 派生的只有公钥
+type Source interface {
+	Derive(path hdwallet.Path) (*hdwallet.Key, error)
+}
+
+// chainID 一条链：purpose、coin type、账户和 change
+type chainID [4]uint32
+
+// Keyring 锁定脚本到密钥的映射，可被多个 goroutine 共享
+type Keyring struct {
+	source Source
+
+	mu      sync.Mutex
+	keys    map[string]*hdwallet.Key // 十六进制锁定脚本 -> 密钥
+	derived map[chainID]uint32       // 每条链已派生的地址数量
+}
+
+// New 创建空的 Keyring
+func New(source Source) *Keyring {
+	return &Keyring{
+		source:  source,
+		keys:    make(map[string]*hdwallet.Key),
+		derived: make(map[chainID]uint32),
+	}
+}
+
+// Add 派生指定路径的密钥并加入 Keyring
+func (r *Keyring) Add(path hdwallet.Path) (*hdwallet.Key, error) {
+	key, err := r.source.Derive(path)
+	if err != nil {
+		return nil, fmt.Errorf("派生 %s 失败: %w", path, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[hex.EncodeToString(key.PkScript)] = key
+	return key, nil
+}
+
+// DeriveChain 派生一条链上索引 0 到 count-1 的全部地址，已派生的部分不会重复派生，
+// 返回本次新增的密钥
+func (r *Keyring) DeriveChain(purpose hdwallet.Purpose, coinType, account, change, count uint32) ([]*hdwallet.Key, error) {
+	id := chainID{uint32(purpose), coinType, account, change}
+	r.mu.Lock()
+	start := r.derived[id]
+	r.mu.Unlock()
+
+	var added []*hdwallet.Key
+	for index := start; index < count; index++ {
+		key, err := r.Add(hdwallet.Path{
+			Purpose:  purpose,
+			CoinType: coinType,
+			Account:  account,
+			Change:   change,
+			Index:    index,
+		})
+		if err != nil {
+			return added, err
+		}
+		added = append(added, key)
+	}
+
+	r.mu.Lock()
+	if count > r.derived[id] {
+		r.derived[id] = count
+	}
+	r.mu.Unlock()
+	return added, nil
+}
+
+// DeriveAccount 派生账户收款链和找零链上的地址，两条链分别派生 external 和 internal 个
+func (r *Keyring) DeriveAccount(purpose hdwallet.Purpose, coinType, account, external, internal uint32) ([]*hdwallet.Key, error) {
+	keys, err := r.DeriveChain(purpose, coinType, account, hdwallet.ChainExternal, external)
+	if err != nil {
+		return nil, err
+	}
+	change, err := r.DeriveChain(purpose, coinType, account, hdwallet.ChainInternal, internal)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, change...), nil
+}
+
+// DeriveIssued 派生钱包数据库中账户 0 在每个地址类型、每条链上已分配的地址，
+// 以及之后 lookahead 个尚未分配的地址。观察钱包没有导入的地址类型会被跳过。
+func (r *Keyring) DeriveIssued(db *walletdb.DB, coinType, lookahead uint32) error {
+	for _, purpose := range Purposes {
+		_, err := r.DeriveAccount(purpose, coinType, 0,
+			db.NextIndex(purpose, coinType, 0, hdwallet.ChainExternal)+lookahead,
+			db.NextIndex(purpose, coinType, 0, hdwallet.ChainInternal)+lookahead)
+		if errors.Is(err, ErrUnknownAccount) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyByScript 返回锁定脚本对应的密钥，实现 signer.Keys
+func (r *Keyring) KeyByScript(pkScript []byte) (*hdwallet.Key, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[hex.EncodeToString(pkScript)]
+	return key, ok
+}
+
+// Path 返回锁定脚本对应的派生路径
+func (r *Keyring) Path(pkScript []byte) (hdwallet.Path, bool) {
+	key, ok := r.KeyByScript(pkScript)
+	if !ok {
+		return hdwallet.Path{}, false
+	}
+	return key.Path, true
+}
+
+// Keys 返回所有密钥，按 purpose、账户、链和索引排序
+func (r *Keyring) Keys() []*hdwallet.Key {
+	r.mu.Lock()
+	keys := make([]*hdwallet.Key, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i].Path.Indexes(), keys[j].Path.Indexes()
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return keys
+}
+
+// Len 返回密钥数量
+func (r *Keyring) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.keys)
+}
@@ -0,0 +1,136 @@
+package keyring
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"go-btc/descriptor"
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/btcutil/hdkeychain"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// ErrUnknownAccount 观察钱包没有导入该账户的描述符
+var ErrUnknownAccount = errors.New("观察钱包中没有该账户")
+
+// branch 描述符中的一条链：描述符和它在多路径 <0;1> 中的分支序号
+type branch struct {
+	desc  *descriptor.Descriptor
+	index int
+}
+
+// WatchOnly 由账户描述符组成的观察钱包，只能派生公钥、地址和锁定脚本。
+// 描述符必须带密钥来源 [fingerprint/purpose'/coin_type'/account']，并且都来自同一个主密钥，
+// 这样创建的 PSBT 才能带上离线签名方需要的派生信息。
+type WatchOnly struct {
+	fingerprint uint32
+	chains      map[chainID]branch
+}
+
+// NewWatchOnly 由 account/descriptor 导出的账户描述符创建观察钱包，
+// 描述符可以是 <0;1>/* 多路径形式，也可以是拆开的 0/* 和 1/*
+func NewWatchOnly(descs ...*descriptor.Descriptor) (*WatchOnly, error) {
+	if len(descs) == 0 {
+		return nil, fmt.Errorf("观察钱包至少需要一个描述符")
+	}
+
+	w := &WatchOnly{chains: make(map[chainID]branch)}
+	for i, desc := range descs {
+		key := desc.Key
+		if key.Origin == nil || len(key.Origin.Path) != 3 {
+			return nil, fmt.Errorf("描述符 %s 缺少账户级密钥来源 [fingerprint/purpose'/coin_type'/account']", desc)
+		}
+		if key.XPub == nil || !key.Wildcard || len(key.Steps) != 1 {
+			return nil, fmt.Errorf("描述符 %s 不是 xpub/<0;1>/* 形式的账户描述符", desc)
+		}
+		if i == 0 {
+			w.fingerprint = key.Origin.Fingerprint
+		} else if key.Origin.Fingerprint != w.fingerprint {
+			return nil, fmt.Errorf("描述符 %s 的主密钥指纹与 %s 不一致", desc, hdwallet.FormatFingerprint(w.fingerprint))
+		}
+
+		origin := key.Origin.Path
+		for _, index := range origin {
+			if index < hdkeychain.HardenedKeyStart {
+				return nil, fmt.Errorf("描述符 %s 的账户路径必须全部为硬化派生", desc)
+			}
+		}
+		purpose := hdwallet.Purpose(origin[0] - hdkeychain.HardenedKeyStart)
+		if purpose != desc.Purpose {
+			return nil, fmt.Errorf("描述符 %s 的地址类型与路径中的 purpose %d 不一致", desc, uint32(purpose))
+		}
+		coinType := origin[1] - hdkeychain.HardenedKeyStart
+		account := origin[2] - hdkeychain.HardenedKeyStart
+
+		for b, change := range key.Steps[0] {
+			id := chainID{uint32(purpose), coinType, account, change}
+			if _, ok := w.chains[id]; ok {
+				return nil, fmt.Errorf("描述符 %s 与之前的描述符重复", desc)
+			}
+			w.chains[id] = branch{desc: desc, index: b}
+		}
+	}
+	return w, nil
+}
+
+// LoadWatchOnly 读取描述符文件，每行一个描述符，忽略空行和 # 开头的注释。
+// 行首可以带 "p2tr: " 这样的说明，直接保存 account/descriptor 的输出即可。
+func LoadWatchOnly(path string, netParams *chaincfg.Params) (*WatchOnly, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取描述符文件失败: %w", err)
+	}
+	defer f.Close()
+
+	var descs []*descriptor.Descriptor
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		// 描述符本身不含空白，取最后一个字段
+		fields := strings.Fields(text)
+		desc, err := descriptor.Parse(fields[len(fields)-1], netParams)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 行: %w", line, err)
+		}
+		descs = append(descs, desc)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取描述符文件失败: %w", err)
+	}
+	return NewWatchOnly(descs...)
+}
+
+// MasterFingerprint 返回描述符记录的主密钥指纹
+func (w *WatchOnly) MasterFingerprint() uint32 {
+	return w.fingerprint
+}
+
+// Derive 派生指定路径的公钥、地址和锁定脚本，返回的 Key 不含私钥，实现 Source
+func (w *WatchOnly) Derive(path hdwallet.Path) (*hdwallet.Key, error) {
+	if err := path.Validate(); err != nil {
+		return nil, err
+	}
+	b, ok := w.chains[chainID{uint32(path.Purpose), path.CoinType, path.Account, path.Change}]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, path)
+	}
+
+	out, err := b.desc.Derive(b.index, path.Index)
+	if err != nil {
+		return nil, err
+	}
+	return &hdwallet.Key{
+		Path:         path,
+		PubKey:       out.PubKey,
+		Address:      out.Address,
+		PkScript:     out.PkScript,
+		RedeemScript: out.RedeemScript,
+	}, nil
+}
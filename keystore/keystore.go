@@ -0,0 +1,249 @@
+// Package keystore 将助记词和 BIP-39 密码加密保存到本地文件，替代明文 .env。
+//
+// 文件为 JSON 格式：使用 Argon2id 从口令派生密钥，AES-256-GCM 加密并认证，
+// 版本号、KDF 参数、盐和随机数组成的文件头作为附加认证数据，任何改动都会导致解密失败。
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"go-btc/internal/fileutil"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Version 当前的文件格式版本
+const Version = 1
+
+const (
+	kdfArgon2id     = "argon2id"
+	cipherAES256GCM = "aes-256-gcm"
+	keyLen          = 32
+	saltLen         = 16
+
+	// KDF 参数的上限。参数来自文件头，损坏或伪造的文件可能要求数 TiB 内存或无限轮数，
+	// 在派生密钥前拒绝，以免进程卡死或被系统杀掉
+	maxKDFTime   = 64
+	maxKDFMemory = 4 * 1024 * 1024 // 4 GiB
+)
+
+var (
+	// ErrWrongPassword 口令错误或文件被篡改
+	ErrWrongPassword = errors.New("口令错误或 keystore 文件已损坏")
+
+	// ErrUnsupportedVersion 文件版本或算法不受支持
+	ErrUnsupportedVersion = errors.New("不支持的 keystore 版本")
+)
+
+// KDFParams Argon2id 参数，Memory 单位为 KiB
+type KDFParams struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    string `json:"salt"`
+}
+
+// DefaultKDFParams 默认参数：3 轮、64 MiB 内存、4 线程，普通机器上解锁约需 0.1~0.5 秒
+var DefaultKDFParams = KDFParams{
+	Time:    3,
+	Memory:  64 * 1024,
+	Threads: 4,
+}
+
+// Secret keystore 中保存的秘密数据
+type Secret struct {
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// header 文件头，序列化结果作为 GCM 的附加认证数据
+type header struct {
+	Version   int       `json:"version"`
+	KDF       string    `json:"kdf"`
+	KDFParams KDFParams `json:"kdfparams"`
+	Cipher    string    `json:"cipher"`
+	Nonce     string    `json:"nonce"`
+}
+
+// file keystore 文件内容
+type file struct {
+	header
+	Ciphertext string `json:"ciphertext"`
+}
+
+// Encrypt 用口令加密秘密数据，返回 keystore 文件内容
+func Encrypt(secret *Secret, password []byte, params KDFParams) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, fmt.Errorf("口令不能为空")
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("生成盐失败: %w", err)
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	aead, err := newAEAD(password, params)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成随机数失败: %w", err)
+	}
+
+	f := file{
+		header: header{
+			Version:   Version,
+			KDF:       kdfArgon2id,
+			KDFParams: params,
+			Cipher:    cipherAES256GCM,
+			Nonce:     hex.EncodeToString(nonce),
+		},
+	}
+	aad, err := json.Marshal(f.header)
+	if err != nil {
+		return nil, fmt.Errorf("序列化文件头失败: %w", err)
+	}
+
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("序列化秘密数据失败: %w", err)
+	}
+	defer zero(plaintext)
+
+	f.Ciphertext = hex.EncodeToString(aead.Seal(nil, nonce, plaintext, aad))
+	return json.MarshalIndent(f, "", "  ")
+}
+
+// Decrypt 用口令解密 keystore 文件内容
+func Decrypt(data, password []byte) (*Secret, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析 keystore 文件失败: %w", err)
+	}
+	if f.Version != Version || f.KDF != kdfArgon2id || f.Cipher != cipherAES256GCM {
+		return nil, fmt.Errorf("%w: version=%d kdf=%s cipher=%s", ErrUnsupportedVersion, f.Version, f.KDF, f.Cipher)
+	}
+
+	nonce, err := hex.DecodeString(f.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("解析随机数失败: %w", err)
+	}
+	ciphertext, err := hex.DecodeString(f.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("解析密文失败: %w", err)
+	}
+
+	aead, err := newAEAD(password, f.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("随机数长度错误: %d", len(nonce))
+	}
+
+	aad, err := json.Marshal(f.header)
+	if err != nil {
+		return nil, fmt.Errorf("序列化文件头失败: %w", err)
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	defer zero(plaintext)
+
+	var secret Secret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		return nil, fmt.Errorf("解析秘密数据失败: %w", err)
+	}
+	return &secret, nil
+}
+
+// newAEAD 用 Argon2id 派生密钥并创建 AES-256-GCM 实例
+func newAEAD(password []byte, params KDFParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil || len(salt) < saltLen {
+		return nil, fmt.Errorf("盐无效")
+	}
+	if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+		return nil, fmt.Errorf("KDF 参数无效")
+	}
+	if params.Time > maxKDFTime || params.Memory > maxKDFMemory {
+		return nil, fmt.Errorf("KDF 参数超出上限: time %d (最大 %d), memory %d KiB (最大 %d KiB)",
+			params.Time, maxKDFTime, params.Memory, maxKDFMemory)
+	}
+
+	key := argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, keyLen)
+	defer zero(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建 AES 实例失败: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建 GCM 实例失败: %w", err)
+	}
+	return aead, nil
+}
+
+// Create 创建新的 keystore 文件，文件已存在时返回错误以免覆盖。
+// 加密前先检查一次，省去无用的密钥派生；写入时用 fileutil.WriteNew 再确认，
+// 检查之后其他进程创建的同名文件也不会被覆盖
+func Create(path string, secret *Secret, password []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("keystore 文件已存在: %s", path)
+	}
+
+	data, err := Encrypt(secret, password, DefaultKDFParams)
+	if err != nil {
+		return err
+	}
+	if err := fileutil.WriteNew(path, data); err != nil {
+		if errors.Is(err, fileutil.ErrExist) {
+			return fmt.Errorf("keystore 文件已存在: %s", path)
+		}
+		return err
+	}
+	return nil
+}
+
+// Unlock 读取并解密 keystore 文件
+func Unlock(path string, password []byte) (*Secret, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 keystore 文件失败: %w", err)
+	}
+	return Decrypt(data, password)
+}
+
+// ChangePassword 用新口令重新加密 keystore 文件，同时生成新的盐和随机数
+func ChangePassword(path string, oldPassword, newPassword []byte) error {
+	secret, err := Unlock(path, oldPassword)
+	if err != nil {
+		return err
+	}
+
+	data, err := Encrypt(secret, newPassword, DefaultKDFParams)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(path, data)
+}
+
+// zero 清除内存中的敏感数据
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
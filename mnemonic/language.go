@@ -0,0 +1,106 @@
+package mnemonic
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/tyler-smith/go-bip39/wordlists"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Language 助记词词表语言
+type Language string
+
+const (
+	English            Language = "english"
+	ChineseSimplified  Language = "chinese_simplified"
+	ChineseTraditional Language = "chinese_traditional"
+	Czech              Language = "czech"
+	French             Language = "french"
+	Italian            Language = "italian"
+	Japanese           Language = "japanese"
+	Korean             Language = "korean"
+	Spanish            Language = "spanish"
+)
+
+// Languages 按自动识别时的尝试顺序列出所有支持的语言。
+// 简体和繁体中文有大量相同的字，简体排在前面。
+var Languages = []Language{
+	English,
+	ChineseSimplified,
+	ChineseTraditional,
+	Japanese,
+	Korean,
+	Spanish,
+	French,
+	Italian,
+	Czech,
+}
+
+// wordList 一种语言的词表及单词到索引的映射，单词统一为 NFKD 形式
+type wordList struct {
+	words []string
+	index map[string]int
+}
+
+var (
+	wordListsOnce sync.Once
+	wordListsMap  map[Language]*wordList
+)
+
+// loadWordLists 加载 go-bip39 自带的词表
+func loadWordLists() {
+	raw := map[Language][]string{
+		English:            wordlists.English,
+		ChineseSimplified:  wordlists.ChineseSimplified,
+		ChineseTraditional: wordlists.ChineseTraditional,
+		Czech:              wordlists.Czech,
+		French:             wordlists.French,
+		Italian:            wordlists.Italian,
+		Japanese:           wordlists.Japanese,
+		Korean:             wordlists.Korean,
+		Spanish:            wordlists.Spanish,
+	}
+
+	wordListsMap = make(map[Language]*wordList, len(raw))
+	for lang, words := range raw {
+		list := &wordList{
+			words: make([]string, len(words)),
+			index: make(map[string]int, len(words)),
+		}
+		for i, w := range words {
+			w = norm.NFKD.String(strings.TrimSpace(w))
+			list.words[i] = w
+			list.index[w] = i
+		}
+		wordListsMap[lang] = list
+	}
+}
+
+// getWordList 返回指定语言的词表
+func getWordList(lang Language) (*wordList, error) {
+	wordListsOnce.Do(loadWordLists)
+	list, ok := wordListsMap[lang]
+	if !ok {
+		return nil, fmt.Errorf("不支持的助记词语言: %s", lang)
+	}
+	return list, nil
+}
+
+// ParseLanguage 解析语言名称，不区分大小写
+func ParseLanguage(name string) (Language, error) {
+	lang := Language(strings.ToLower(strings.TrimSpace(name)))
+	if _, err := getWordList(lang); err != nil {
+		return "", err
+	}
+	return lang, nil
+}
+
+// separator 返回助记词单词之间的分隔符，日语使用全角空格
+func (l Language) separator() string {
+	if l == Japanese {
+		return "　"
+	}
+	return " "
+}
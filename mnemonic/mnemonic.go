@@ -0,0 +1,191 @@
+// Package mnemonic 实现 BIP-39 助记词的生成、校验和跨语言转换，
+// 支持 go-bip39 自带的全部词表以及 128~256 位熵。
+package mnemonic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrChecksum 助记词的所有单词都在词表中，但校验和不正确
+var ErrChecksum = errors.New("助记词校验和错误")
+
+// UnknownWordError 助记词中出现词表之外的单词
+type UnknownWordError struct {
+	Position int // 从 1 开始的单词位置
+	Word     string
+}
+
+func (e *UnknownWordError) Error() string {
+	return fmt.Sprintf("第 %d 个单词 %q 不在词表中", e.Position, e.Word)
+}
+
+// WordCountError 助记词单词数量不是 12/15/18/21/24
+type WordCountError struct {
+	Count int
+}
+
+func (e *WordCountError) Error() string {
+	return fmt.Sprintf("助记词单词数量错误: %d，应为 12、15、18、21 或 24", e.Count)
+}
+
+// ValidateBits 校验熵的位数是否在 128~256 之间且为 32 的倍数
+func ValidateBits(bits int) error {
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return fmt.Errorf("熵的位数必须为 128、160、192、224 或 256，实际为 %d", bits)
+	}
+	return nil
+}
+
+// Generate 生成指定熵位数和语言的助记词，128 位对应 12 个单词，256 位对应 24 个单词
+func Generate(bits int, lang Language) (string, error) {
+	if err := ValidateBits(bits); err != nil {
+		return "", err
+	}
+
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", fmt.Errorf("生成熵失败: %w", err)
+	}
+	return FromEntropy(entropy, lang)
+}
+
+// FromEntropy 将熵编码为指定语言的助记词
+func FromEntropy(entropy []byte, lang Language) (string, error) {
+	if err := ValidateBits(len(entropy) * 8); err != nil {
+		return "", err
+	}
+	list, err := getWordList(lang)
+	if err != nil {
+		return "", err
+	}
+
+	// 熵后面追加 SHA256 的前 len/32 位作为校验和，再每 11 位取一个单词
+	checksumBits := len(entropy) * 8 / 32
+	data := append(append([]byte{}, entropy...), sha256.Sum256(entropy)[0])
+	count := (len(entropy)*8 + checksumBits) / 11
+
+	words := make([]string, count)
+	for i := range words {
+		words[i] = list.words[readBits(data, i*11, 11)]
+	}
+	return strings.Join(words, lang.separator()), nil
+}
+
+// ToEntropy 按指定语言解码助记词并校验校验和
+func ToEntropy(mnemonic string, lang Language) ([]byte, error) {
+	list, err := getWordList(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	words := splitWords(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, &WordCountError{Count: len(words)}
+	}
+
+	totalBits := len(words) * 11
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+
+	data := make([]byte, (totalBits+7)/8)
+	for i, w := range words {
+		index, ok := list.index[w]
+		if !ok {
+			return nil, &UnknownWordError{Position: i + 1, Word: w}
+		}
+		writeBits(data, i*11, 11, index)
+	}
+
+	entropy := data[:entropyBits/8]
+	expected := sha256.Sum256(entropy)[0] >> (8 - checksumBits)
+	if readBits(data, entropyBits, checksumBits) != int(expected) {
+		return nil, ErrChecksum
+	}
+	return entropy, nil
+}
+
+// Validate 校验助记词并返回识别出的语言。
+// 所有语言都无法识别时，返回连续识别出最多单词的语言下的错误，
+// 例如校验和错误，或第 N 个单词拼写错误。
+func Validate(mnemonic string) (Language, error) {
+	var bestErr error
+	bestPos := 0
+	for _, lang := range Languages {
+		_, err := ToEntropy(mnemonic, lang)
+		if err == nil {
+			return lang, nil
+		}
+
+		var countErr *WordCountError
+		if errors.As(err, &countErr) {
+			return "", err
+		}
+
+		// 校验和错误说明所有单词都已识别，位置视为无穷大
+		pos := len(splitWords(mnemonic)) + 1
+		var wordErr *UnknownWordError
+		if errors.As(err, &wordErr) {
+			pos = wordErr.Position
+		}
+		if pos > bestPos {
+			bestPos, bestErr = pos, err
+		}
+	}
+	return "", bestErr
+}
+
+// Convert 将助记词转换为另一种语言，两者对应同一份熵。
+// 注意：种子由助记词文本计算，换语言后的助记词会得到不同的钱包，转换只用于抄写备份。
+func Convert(mnemonic string, to Language) (string, error) {
+	from, err := Validate(mnemonic)
+	if err != nil {
+		return "", err
+	}
+	entropy, err := ToEntropy(mnemonic, from)
+	if err != nil {
+		return "", err
+	}
+	return FromEntropy(entropy, to)
+}
+
+// NewSeed 按 BIP-39 从助记词和密码计算 64 字节种子，两者都先做 NFKD 规范化
+func NewSeed(mnemonic, passphrase string) []byte {
+	m := strings.Join(splitWords(mnemonic), " ")
+	salt := "mnemonic" + norm.NFKD.String(passphrase)
+	return pbkdf2.Key([]byte(m), []byte(salt), 2048, 64, sha512.New)
+}
+
+// splitWords 规范化并拆分助记词，全角空格等各种空白字符都视为分隔符
+func splitWords(mnemonic string) []string {
+	return strings.Fields(norm.NFKD.String(mnemonic))
+}
+
+// readBits 从 data 的第 offset 位开始读取 n 位（大端）
+func readBits(data []byte, offset, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := offset + i
+		v = v<<1 | int(data[bit/8]>>(7-bit%8)&1)
+	}
+	return v
+}
+
+// writeBits 将 v 的低 n 位写入 data 的第 offset 位起始处（大端）
+func writeBits(data []byte, offset, n, v int) {
+	for i := 0; i < n; i++ {
+		if v>>(n-1-i)&1 == 1 {
+			bit := offset + i
+			data[bit/8] |= 1 << (7 - bit%8)
+		}
+	}
+}
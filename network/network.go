@@ -0,0 +1,118 @@
+// Package network 统一管理各命令使用的比特币网络：链参数、BIP-44 coin type
+// 以及 mempool.space 风格的 REST API 地址，避免不同命令混用主网和测试网。
+package network
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"go-btc/esplora"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// 支持的网络名称
+const (
+	MainNet  = "mainnet"
+	TestNet3 = "testnet3"
+	TestNet4 = "testnet4"
+	SigNet   = "signet"
+	RegTest  = "regtest"
+)
+
+// Network 一个比特币网络的完整配置
+type Network struct {
+	Name       string
+	Params     *chaincfg.Params
+	EsploraURL string // REST API 根地址，例如 https://mempool.space/testnet/api
+}
+
+// Parse 按名称创建网络配置。
+// signetChallenge 为十六进制的自定义 signet 挑战脚本，为空时使用默认的公共 signet；
+// esploraURL 不为空时覆盖默认的 API 地址，regtest 和自定义 signet 必须指定。
+func Parse(name, signetChallenge, esploraURL string) (*Network, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if signetChallenge != "" && key != SigNet {
+		return nil, fmt.Errorf("只有 signet 网络可以指定挑战脚本")
+	}
+
+	var n *Network
+	switch key {
+	case MainNet, "main", "bitcoin":
+		n = &Network{Name: MainNet, Params: &chaincfg.MainNetParams, EsploraURL: "https://mempool.space/api"}
+	case TestNet3, "testnet", "test":
+		n = &Network{Name: TestNet3, Params: &chaincfg.TestNet3Params, EsploraURL: "https://mempool.space/testnet/api"}
+	case TestNet4:
+		n = &Network{Name: TestNet4, Params: &TestNet4Params, EsploraURL: "https://mempool.space/testnet4/api"}
+	case SigNet:
+		n = &Network{Name: SigNet, Params: &chaincfg.SigNetParams, EsploraURL: "https://mempool.space/signet/api"}
+		if signetChallenge != "" {
+			challenge, err := hex.DecodeString(signetChallenge)
+			if err != nil || len(challenge) == 0 {
+				return nil, fmt.Errorf("signet 挑战脚本无效: %q", signetChallenge)
+			}
+			if bytes.Equal(challenge, chaincfg.DefaultSignetChallenge) {
+				break
+			}
+			params := chaincfg.CustomSignetParams(challenge, nil)
+			n.Name = CustomSigNetName(challenge)
+			n.Params = &params
+			n.EsploraURL = ""
+		}
+	case RegTest:
+		n = &Network{Name: RegTest, Params: &chaincfg.RegressionNetParams}
+	default:
+		return nil, fmt.Errorf("未知的网络: %q，可选 mainnet、testnet3、testnet4、signet、regtest", name)
+	}
+
+	if esploraURL != "" {
+		n.EsploraURL = strings.TrimRight(esploraURL, "/")
+	}
+	return n, nil
+}
+
+// CustomSigNetName 返回自定义 signet 的网络名称 signet-<挑战脚本 SHA-256 的前 4 字节>，
+// 不同挑战脚本的 signet 名称不同，钱包数据库不会在它们之间混用
+func CustomSigNetName(challenge []byte) string {
+	hash := sha256.Sum256(challenge)
+	return SigNet + "-" + hex.EncodeToString(hash[:4])
+}
+
+// CoinType 返回 BIP-44 coin type：主网为 0，所有测试网络为 1
+func (n *Network) CoinType() uint32 {
+	return n.Params.HDCoinType
+}
+
+// API 返回 REST API 的完整地址，未配置 API 时返回错误
+func (n *Network) API(format string, args ...interface{}) (string, error) {
+	if n.EsploraURL == "" {
+		return "", fmt.Errorf("网络 %s 没有默认的 API 地址，请通过 -esplora 或 ESPLORA_URL 指定", n.Name)
+	}
+	return n.EsploraURL + fmt.Sprintf(format, args...), nil
+}
+
+// Client 返回当前网络的 REST API 客户端，未配置 API 时返回错误
+func (n *Network) Client() (*esplora.Client, error) {
+	if n.EsploraURL == "" {
+		return nil, fmt.Errorf("网络 %s 没有默认的 API 地址，请通过 -esplora 或 ESPLORA_URL 指定", n.Name)
+	}
+	return esplora.NewClient(n.EsploraURL), nil
+}
+
+// DecodeAddress 解码地址并确认它属于当前网络。
+// btcutil.DecodeAddress 对 bech32 地址只按地址自身的前缀解码，不检查网络，
+// 所以需要额外校验，避免把主网地址用在测试网（或反过来）。
+func (n *Network) DecodeAddress(addr string) (btcutil.Address, error) {
+	decoded, err := btcutil.DecodeAddress(addr, n.Params)
+	if err != nil {
+		return nil, fmt.Errorf("解码地址 %s 失败: %w", addr, err)
+	}
+	if !decoded.IsForNet(n.Params) {
+		return nil, fmt.Errorf("地址 %s 不属于网络 %s", addr, n.Name)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TestNet4Net testnet4 的网络魔数，消息头字节为 1c 16 3f 28 (BIP-94)
+const TestNet4Net wire.BitcoinNet = 0x283f161c
+
+// testNet4PowLimit 最低难度目标 2^224 - 1，与 testnet3 相同
+var testNet4PowLimit = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224), big.NewInt(1))
+
+// testNet4GenesisCoinbaseTx testnet4 创世区块的 coinbase 交易，
+// 输入脚本带有 "03/May/2024 <主网区块哈希>" 消息，输出为 33 字节全零公钥的 P2PK
+var testNet4GenesisCoinbaseTx = wire.MsgTx{
+	Version: 1,
+	TxIn: []*wire.TxIn{
+		{
+			PreviousOutPoint: wire.OutPoint{
+				Hash:  chainhash.Hash{},
+				Index: 0xffffffff,
+			},
+			SignatureScript: append([]byte{
+				0x04, 0xff, 0xff, 0x00, 0x1d, // 486604799
+				0x01, 0x04, // 4
+				0x4c, 0x4c, // OP_PUSHDATA1 76
+			}, "03/May/2024 000000000000000000001ebd58c244970b3aa9d783bb001011fbe8ea8e98e00e"...),
+			Sequence: 0xffffffff,
+		},
+	},
+	TxOut: []*wire.TxOut{
+		{
+			Value: 50 * 1e8,
+			PkScript: append(append([]byte{0x21}, make([]byte, 33)...),
+				0xac, // OP_CHECKSIG
+			),
+		},
+	},
+	LockTime: 0,
+}
+
+// testNet4GenesisBlock testnet4 创世区块
+var testNet4GenesisBlock = wire.MsgBlock{
+	Header: wire.BlockHeader{
+		Version:    1,
+		PrevBlock:  chainhash.Hash{},
+		MerkleRoot: mustHash("7aa0a7ae1e223414cb807e40cd57e667b718e42aaf9306db9102fe28912b7b4e"),
+		Timestamp:  time.Unix(1714777860, 0), // 2024-05-03 23:11:00 +0000 UTC
+		Bits:       0x1d00ffff,
+		Nonce:      393743547,
+	},
+	Transactions: []*wire.MsgTx{&testNet4GenesisCoinbaseTx},
+}
+
+// testNet4GenesisHash testnet4 创世区块哈希
+var testNet4GenesisHash = mustHash("00000000da84f2bafbbc53dee25a72ae507ff4914b867c565be350b0da8bf043")
+
+// TestNet4Params testnet4 (BIP-94) 的网络参数。
+//
+// 地址前缀、扩展密钥版本和 bech32 HRP 都与 testnet3 相同，所以 testnet3 与 testnet4
+// 的地址在格式上无法区分，只能靠所选网络的 API 区分。所有软分叉从创世区块起即生效。
+// BIP-94 对难度调整的修改（20 分钟最低难度块不再重置难度、防时间扭曲攻击）属于区块验证规则，
+// 本项目不验证区块，这里只保留 ReduceMinDifficulty 等参数用于描述网络。
+// Bitcoin Core 没有为 testnet4 设置检查点，这里同样为空。
+var TestNet4Params = chaincfg.Params{
+	Name:        TestNet4,
+	Net:         TestNet4Net,
+	DefaultPort: "48333",
+	DNSSeeds: []chaincfg.DNSSeed{
+		{Host: "seed.testnet4.bitcoin.sprovoost.nl", HasFiltering: true},
+		{Host: "seed.testnet4.wiz.biz", HasFiltering: true},
+	},
+
+	// Chain parameters
+	GenesisBlock:             &testNet4GenesisBlock,
+	GenesisHash:              &testNet4GenesisHash,
+	PowLimit:                 testNet4PowLimit,
+	PowLimitBits:             0x1d00ffff,
+	BIP0034Height:            1,
+	BIP0065Height:            1,
+	BIP0066Height:            1,
+	CoinbaseMaturity:         100,
+	SubsidyReductionInterval: 210000,
+	TargetTimespan:           time.Hour * 24 * 14, // 14 days
+	TargetTimePerBlock:       time.Minute * 10,    // 10 minutes
+	RetargetAdjustmentFactor: 4,                   // 25% less, 400% more
+	ReduceMinDifficulty:      true,
+	MinDiffReductionTime:     time.Minute * 20, // TargetTimePerBlock * 2
+	GenerateSupported:        false,
+
+	Checkpoints: nil,
+
+	// Consensus rule change deployments, all active since genesis.
+	RuleChangeActivationThreshold: 1512, // 75% of MinerConfirmationWindow
+	MinerConfirmationWindow:       2016,
+	Deployments: [chaincfg.DefinedDeployments]chaincfg.ConsensusDeployment{
+		chaincfg.DeploymentTestDummy: {
+			BitNumber:         28,
+			DeploymentStarter: chaincfg.NewMedianTimeDeploymentStarter(time.Time{}),
+			DeploymentEnder:   chaincfg.NewMedianTimeDeploymentEnder(time.Time{}),
+		},
+		chaincfg.DeploymentTestDummyMinActivation: {
+			BitNumber:                 22,
+			CustomActivationThreshold: 1815,
+			MinActivationHeight:       10_0000,
+			DeploymentStarter:         chaincfg.NewMedianTimeDeploymentStarter(time.Time{}),
+			DeploymentEnder:           chaincfg.NewMedianTimeDeploymentEnder(time.Time{}),
+		},
+		chaincfg.DeploymentCSV: {
+			BitNumber:         0,
+			DeploymentStarter: chaincfg.NewMedianTimeDeploymentStarter(time.Time{}),
+			DeploymentEnder:   chaincfg.NewMedianTimeDeploymentEnder(time.Time{}),
+		},
+		chaincfg.DeploymentSegwit: {
+			BitNumber:         1,
+			DeploymentStarter: chaincfg.NewMedianTimeDeploymentStarter(time.Time{}),
+			DeploymentEnder:   chaincfg.NewMedianTimeDeploymentEnder(time.Time{}),
+		},
+		chaincfg.DeploymentTaproot: {
+			BitNumber:                 2,
+			DeploymentStarter:         chaincfg.NewMedianTimeDeploymentStarter(time.Time{}),
+			DeploymentEnder:           chaincfg.NewMedianTimeDeploymentEnder(time.Time{}),
+			CustomActivationThreshold: 1512, // 75%
+		},
+	},
+
+	// Mempool parameters
+	RelayNonStdTxs: true,
+
+	// Human-readable part for Bech32 encoded segwit addresses, as defined in
+	// BIP 173.
+	Bech32HRPSegwit: "tb",
+
+	// Address encoding magics
+	PubKeyHashAddrID:        0x6f, // starts with m or n
+	ScriptHashAddrID:        0xc4, // starts with 2
+	WitnessPubKeyHashAddrID: 0x03, // starts with QW
+	WitnessScriptHashAddrID: 0x28, // starts with T7n
+	PrivateKeyID:            0xef, // starts with 9 (uncompressed) or c (compressed)
+
+	// BIP32 hierarchical deterministic extended key magics
+	HDPrivateKeyID: [4]byte{0x04, 0x35, 0x83, 0x94}, // starts with tprv
+	HDPublicKeyID:  [4]byte{0x04, 0x35, 0x87, 0xcf}, // starts with tpub
+
+	// BIP44 coin type used in the hierarchical deterministic path for
+	// address generation.
+	HDCoinType: 1,
+}
+
+// mustHash 解析硬编码的区块哈希，只会在包初始化时因代码错误而 panic
+func mustHash(s string) chainhash.Hash {
+	hash, err := chainhash.NewHashFromStr(s)
+	if err != nil {
+		panic(err)
+	}
+	return *hash
+}
+
+func init() {
+	// 注册 testnet4，使 btcutil.DecodeAddress 等依赖 chaincfg 注册表的函数能识别它
+	if err := chaincfg.Register(&TestNet4Params); err != nil {
+		panic("注册 testnet4 网络失败: " + err.Error())
+	}
+}
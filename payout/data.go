@@ -0,0 +1,91 @@
+package payout
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Data 一个 OP_RETURN 数据输出，金额为 0，不可花费
+type Data struct {
+	Payload  []byte
+	PkScript []byte // OP_RETURN <Payload>
+	Source   string // 数据来源的说明，例如文件名
+}
+
+// ParseData 解析数据输出的描述：
+//
+//	hex:<十六进制>   原样写入
+//	text:<文本>      写入 UTF-8 编码
+//	file:<路径>      写入文件内容的 SHA-256，用于在链上锚定文档
+//
+// 没有这三种前缀时整个字符串按文本处理，例如 "Invoice: 42"。
+func ParseData(spec string) (Data, error) {
+	kind, value, ok := strings.Cut(spec, ":")
+	if !ok || (kind != "hex" && kind != "text" && kind != "file") {
+		kind, value = "text", spec
+	}
+
+	var d Data
+	switch kind {
+	case "hex":
+		payload, err := hex.DecodeString(value)
+		if err != nil {
+			return Data{}, fmt.Errorf("数据 %q 不是有效的十六进制: %w", value, err)
+		}
+		d = Data{Payload: payload, Source: "hex"}
+	case "text":
+		if !utf8.ValidString(value) {
+			return Data{}, fmt.Errorf("数据 %q 不是有效的 UTF-8 文本", value)
+		}
+		d = Data{Payload: []byte(value), Source: "text"}
+	case "file":
+		hash, err := hashFile(value)
+		if err != nil {
+			return Data{}, err
+		}
+		d = Data{Payload: hash, Source: "sha256(" + value + ")"}
+	}
+	if len(d.Payload) == 0 {
+		return Data{}, fmt.Errorf("数据为空")
+	}
+
+	// 节点只按脚本总大小限制数据输出，大小由 policy.Check 检查。
+	// 单次推送最多 520 字节，与脚本元素的共识上限一致
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).AddData(d.Payload).Script()
+	if err != nil {
+		return Data{}, fmt.Errorf("生成 OP_RETURN 脚本失败: %w", err)
+	}
+	d.PkScript = pkScript
+	return d, nil
+}
+
+// hashFile 计算文件内容的 SHA-256
+func hashFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开文件失败: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	return h.Sum(nil), nil
+}
+
+// DataSize 返回所有数据输出的脚本总字节数，节点按该值限制 OP_RETURN 输出 (-datacarriersize)
+func DataSize(data []Data) int {
+	var size int
+	for _, d := range data {
+		size += len(d.PkScript)
+	}
+	return size
+}
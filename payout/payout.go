@@ -0,0 +1,311 @@
+// Package payout 从 CSV 或 JSON 文件读取批量付款列表，并按网络校验每一行。
+//
+// CSV 每行为 address,amount[,label]，第一行可以是表头；JSON 为对象数组：
+//
+//	[{"address": "tb1q...", "amount": 10000, "label": "alice"}]
+//
+// 金额单位为 sat。所有行的错误会一次性报告，而不是遇到第一个错误就停止。
+package payout
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go-btc/network"
+	"go-btc/policy"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// Payment 一笔付款
+type Payment struct {
+	Line     int // 在文件中的行号（JSON 为数组下标加 1），用于报错
+	Address  btcutil.Address
+	PkScript []byte
+	Amount   int64 // sat
+	Label    string
+}
+
+// RowError 某一行的校验错误
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("第 %d 行: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 付款列表中所有行的校验错误
+type ValidationError struct {
+	Rows []*RowError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Rows))
+	for i, row := range e.Rows {
+		lines[i] = row.Error()
+	}
+	return fmt.Sprintf("付款列表有 %d 处错误:\n%s", len(e.Rows), strings.Join(lines, "\n"))
+}
+
+// Unwrap 返回每一行的错误，便于用 errors.As 取出 *policy.DustError 等具体错误
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, len(e.Rows))
+	for i, row := range e.Rows {
+		errs[i] = row
+	}
+	return errs
+}
+
+// ErrDuplicateAddress 同一个地址在列表中出现多次，通常是复制粘贴错误
+var ErrDuplicateAddress = errors.New("重复的收款地址")
+
+// record 解析前的一行
+type record struct {
+	Line    int
+	Address string
+	Amount  string
+	Label   string
+}
+
+// Load 按扩展名读取 .csv 或 .json 付款文件，金额低于按 dustRelayFeeRate (sat/vB) 计算的粉尘阈值时报错
+func Load(path string, net *network.Network, dustRelayFeeRate int64) ([]Payment, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开付款文件失败: %w", err)
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return ParseCSV(f, net, dustRelayFeeRate)
+	case ".json":
+		return ParseJSON(f, net, dustRelayFeeRate)
+	default:
+		return nil, fmt.Errorf("不支持的付款文件格式 %q，请使用 .csv 或 .json", filepath.Ext(path))
+	}
+}
+
+// New 校验单个收款地址和金额，构造一笔付款
+func New(address string, amount int64, label string, net *network.Network, dustRelayFeeRate int64) (Payment, error) {
+	payments, err := validate([]record{{
+		Line:    1,
+		Address: address,
+		Amount:  strconv.FormatInt(amount, 10),
+		Label:   label,
+	}}, net, dustRelayFeeRate)
+	if err != nil {
+		var verr *ValidationError
+		if errors.As(err, &verr) {
+			return Payment{}, verr.Rows[0].Err
+		}
+		return Payment{}, err
+	}
+	return payments[0], nil
+}
+
+// ParseCSV 解析 CSV 付款列表
+func ParseCSV(r io.Reader, net *network.Network, dustRelayFeeRate int64) ([]Payment, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	var records []record
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 CSV 失败: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		// 第一行不是金额时视为表头
+		if len(records) == 0 && len(fields) >= 2 && isHeader(fields) {
+			continue
+		}
+		if len(fields) < 2 || len(fields) > 3 {
+			records = append(records, record{Line: line})
+			continue
+		}
+		rec := record{Line: line, Address: fields[0], Amount: fields[1]}
+		if len(fields) == 3 {
+			rec.Label = fields[2]
+		}
+		records = append(records, rec)
+	}
+	return validate(records, net, dustRelayFeeRate)
+}
+
+// isHeader 判断 CSV 第一行是否为表头
+func isHeader(fields []string) bool {
+	_, err := strconv.ParseInt(strings.TrimSpace(fields[1]), 10, 64)
+	return err != nil && strings.EqualFold(strings.TrimSpace(fields[0]), "address")
+}
+
+// ParseJSON 解析 JSON 付款列表
+func ParseJSON(r io.Reader, net *network.Network, dustRelayFeeRate int64) ([]Payment, error) {
+	var rows []struct {
+		Address string      `json:"address"`
+		Amount  json.Number `json:"amount"`
+		Label   string      `json:"label"`
+	}
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("解析 JSON 失败: %w", err)
+	}
+
+	records := make([]record, len(rows))
+	for i, row := range rows {
+		records[i] = record{Line: i + 1, Address: row.Address, Amount: row.Amount.String(), Label: row.Label}
+	}
+	return validate(records, net, dustRelayFeeRate)
+}
+
+// validate 校验每一行的地址和金额，并检查重复地址
+func validate(records []record, net *network.Network, dustRelayFeeRate int64) ([]Payment, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("付款列表为空")
+	}
+
+	var rowErrors []*RowError
+	fail := func(line int, format string, args ...interface{}) {
+		rowErrors = append(rowErrors, &RowError{Line: line, Err: fmt.Errorf(format, args...)})
+	}
+
+	seen := make(map[string]int)
+	payments := make([]Payment, 0, len(records))
+	for _, rec := range records {
+		if rec.Address == "" && rec.Amount == "" {
+			fail(rec.Line, "列数错误，需要 address,amount[,label]")
+			continue
+		}
+
+		addr, err := net.DecodeAddress(strings.TrimSpace(rec.Address))
+		if err != nil {
+			fail(rec.Line, "%w", err)
+			continue
+		}
+		amount, err := strconv.ParseInt(strings.TrimSpace(rec.Amount), 10, 64)
+		if err != nil {
+			fail(rec.Line, "金额 %q 不是整数 sat", rec.Amount)
+			continue
+		}
+		if amount <= 0 || amount > btcutil.MaxSatoshi {
+			fail(rec.Line, "金额 %d 超出范围", amount)
+			continue
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			fail(rec.Line, "生成锁定脚本失败: %w", err)
+			continue
+		}
+		// 低于粉尘阈值的输出会让整笔交易无法转发
+		if threshold := policy.DustThreshold(pkScript, dustRelayFeeRate); amount < threshold {
+			rowErrors = append(rowErrors, &RowError{
+				Line: rec.Line,
+				Err:  &policy.DustError{Index: -1, Value: amount, Threshold: threshold},
+			})
+			continue
+		}
+
+		// 不同写法（例如 bech32 大小写）的同一地址按锁定脚本判重
+		key := string(pkScript)
+		if first, ok := seen[key]; ok {
+			rowErrors = append(rowErrors, &RowError{
+				Line: rec.Line,
+				Err:  fmt.Errorf("%w %s，与第 %d 行相同", ErrDuplicateAddress, addr.EncodeAddress(), first),
+			})
+			continue
+		}
+		seen[key] = rec.Line
+
+		payments = append(payments, Payment{
+			Line:     rec.Line,
+			Address:  addr,
+			PkScript: pkScript,
+			Amount:   amount,
+			Label:    strings.TrimSpace(rec.Label),
+		})
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, &ValidationError{Rows: rowErrors}
+	}
+	if total := Total(payments); total > btcutil.MaxSatoshi {
+		return nil, fmt.Errorf("付款总额 %d sat 超过比特币总量", total)
+	}
+	return payments, nil
+}
+
+// Total 返回付款总额
+func Total(payments []Payment) int64 {
+	var total int64
+	for _, p := range payments {
+		total += p.Amount
+	}
+	return total
+}
+
+// Summary 按地址类型统计付款笔数和金额
+type Summary struct {
+	Count  int
+	Total  int64
+	ByType map[string]int // 地址类型 -> 笔数
+}
+
+// Summarize 汇总付款列表
+func Summarize(payments []Payment) Summary {
+	summary := Summary{
+		Count:  len(payments),
+		Total:  Total(payments),
+		ByType: make(map[string]int),
+	}
+	for _, p := range payments {
+		summary.ByType[txscript.GetScriptClass(p.PkScript).String()]++
+	}
+	return summary
+}
+
+// SubtractFee 把手续费平均分摊到每笔付款，除不尽的部分由第一笔付款承担，
+// 与 Bitcoin Core 的 subtractfeefromoutputs 一致。扣除后低于粉尘阈值的付款返回 *policy.DustError。
+func SubtractFee(payments []Payment, fee int64, dustRelayFeeRate int64) ([]Payment, error) {
+	if len(payments) == 0 {
+		return nil, fmt.Errorf("付款列表为空")
+	}
+	share := fee / int64(len(payments))
+	remainder := fee % int64(len(payments))
+
+	result := make([]Payment, len(payments))
+	for i, p := range payments {
+		p.Amount -= share
+		if i == 0 {
+			p.Amount -= remainder
+		}
+		if threshold := policy.DustThreshold(p.PkScript, dustRelayFeeRate); p.Amount < threshold {
+			return nil, &RowError{
+				Line: p.Line,
+				Err:  &policy.DustError{Index: i, Value: p.Amount, Threshold: threshold},
+			}
+		}
+		result[i] = p
+	}
+	return result, nil
+}
@@ -0,0 +1,255 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-btc/txsize"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultMinRelayFeeRate Bitcoin Core 默认的最低转发费率 (-minrelaytxfee=1000 sat/kvB)，单位 sat/vB
+	DefaultMinRelayFeeRate = 1
+
+	// DefaultMaxFeeRate sendrawtransaction 默认拒绝的费率上限 (maxfeerate=0.10 BTC/kvB)，单位 sat/vB
+	DefaultMaxFeeRate = 10000
+
+	// DefaultMaxFee 钱包默认的绝对手续费上限 (-maxtxfee=0.1 BTC)，单位 sat
+	DefaultMaxFee = 10000000
+
+	// MaxStandardTxWeight 标准交易的最大重量
+	MaxStandardTxWeight = 400000
+
+	// MaxStandardScriptSigSize 标准交易中 scriptSig 的最大字节数
+	MaxStandardScriptSigSize = 1650
+
+	// DefaultDataCarrierSize 一笔交易中所有 OP_RETURN 输出脚本的默认总字节数上限 (-datacarriersize=83)。
+	// Bitcoin Core 30 起允许多个 OP_RETURN 输出并按总大小限制，更早的版本只转发一个
+	DefaultDataCarrierSize = 83
+
+	// MinStandardTxVersion、MaxStandardTxVersion 标准交易的版本号范围，版本 3 为 TRUC 交易
+	MinStandardTxVersion = 1
+	MaxStandardTxVersion = 3
+)
+
+// StandardVerifyFlags 检查输入脚本时使用的标志，与节点转发交易时的 STANDARD_SCRIPT_VERIFY_FLAGS 一致
+const StandardVerifyFlags = txscript.StandardVerifyFlags
+
+// 检查规则的名称，与 Bitcoin Core 的拒绝原因对应
+const (
+	RuleMissingInputs = "missing-inputs"
+	RuleScript        = "mandatory-script-verify-flag-failed"
+	RuleScriptSigSize = "scriptsig-size"
+	RuleScriptSigPush = "scriptsig-not-pushonly"
+	RuleVersion       = "version"
+	RuleScriptPubKey  = "scriptpubkey"
+	RuleDataCarrier   = "datacarrier"
+	RuleDust          = "dust"
+	RuleTxSize        = "tx-size"
+	RuleNegativeFee   = "bad-txns-in-belowout"
+	RuleMinRelayFee   = "min relay fee not met"
+	RuleMaxFeeRate    = "max-fee-rate-exceeded"
+	RuleMaxFee        = "max-fee-exceeded"
+)
+
+// Options 检查使用的费率和上限，从 DefaultOptions 开始修改。字段为 0 时按 0 检查，不会换成默认值：
+// 粉尘费率和最低转发费率为 0 时不限制，MaxFeeRate 和 MaxFee 为 0 时不检查上限
+// （与 Bitcoin Core 的 maxfeerate=0 一致），DataCarrierSize 为 0 时不允许 OP_RETURN 数据。
+type Options struct {
+	DustRelayFeeRate int64 // sat/vB
+	MinRelayFeeRate  int64 // sat/vB
+	MaxFeeRate       int64 // sat/vB
+	MaxFee           int64 // sat
+	DataCarrierSize  int   // 字节
+}
+
+// DefaultOptions 返回与 Bitcoin Core 默认策略一致的选项
+func DefaultOptions() Options {
+	return Options{
+		DustRelayFeeRate: DefaultDustRelayFeeRate,
+		MinRelayFeeRate:  DefaultMinRelayFeeRate,
+		MaxFeeRate:       DefaultMaxFeeRate,
+		MaxFee:           DefaultMaxFee,
+		DataCarrierSize:  DefaultDataCarrierSize,
+	}
+}
+
+// Violation 一条未通过的规则，Input 和 Output 指出出问题的输入或输出，与之无关时为 -1
+type Violation struct {
+	Rule   string
+	Input  int
+	Output int
+	Reason string
+}
+
+func (v Violation) String() string {
+	switch {
+	case v.Input >= 0:
+		return fmt.Sprintf("输入 %d: %s: %s", v.Input, v.Rule, v.Reason)
+	case v.Output >= 0:
+		return fmt.Sprintf("输出 %d: %s: %s", v.Output, v.Rule, v.Reason)
+	default:
+		return fmt.Sprintf("%s: %s", v.Rule, v.Reason)
+	}
+}
+
+// Report 交易的检查结果
+type Report struct {
+	TxID       string
+	Weight     int64
+	VSize      int64
+	Fee        int64
+	Violations []Violation
+}
+
+// OK 是否通过全部检查
+func (r *Report) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// FeeRate 交易的费率 (sat/vB)
+func (r *Report) FeeRate() float64 {
+	return float64(r.Fee) / float64(r.VSize)
+}
+
+func (r *Report) String() string {
+	return fmt.Sprintf("%s: 重量 %d WU (%d vB), 手续费 %d sat (%.2f sat/vB)", r.TxID, r.Weight, r.VSize, r.Fee, r.FeeRate())
+}
+
+// Err 未通过检查时返回列出所有问题的 *CheckError
+func (r *Report) Err() error {
+	if r.OK() {
+		return nil
+	}
+	return &CheckError{Report: r}
+}
+
+// CheckError 交易未通过广播前检查
+type CheckError struct {
+	Report *Report
+}
+
+func (e *CheckError) Error() string {
+	lines := make([]string, len(e.Report.Violations))
+	for i, v := range e.Report.Violations {
+		lines[i] = v.String()
+	}
+	return fmt.Sprintf("交易 %s 未通过检查:\n  %s", e.Report.TxID, strings.Join(lines, "\n  "))
+}
+
+// Check 在广播前检查已签名的交易：用 txscript 引擎执行每个输入的脚本，
+// 再按节点的转发策略检查版本、输出脚本、粉尘、大小、最低转发费率以及手续费上限。
+// 所有问题都会记录在报告中，而不是遇到第一个就返回。
+func Check(tx *wire.MsgTx, prevOuts txscript.PrevOutputFetcher, opts Options) *Report {
+	report := &Report{
+		TxID:   tx.TxHash().String(),
+		Weight: txsize.TxWeight(tx),
+		VSize:  txsize.TxVSize(tx),
+	}
+	add := func(rule string, input, output int, format string, args ...interface{}) {
+		report.Violations = append(report.Violations, Violation{
+			Rule:   rule,
+			Input:  input,
+			Output: output,
+			Reason: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if tx.Version < MinStandardTxVersion || tx.Version > MaxStandardTxVersion {
+		add(RuleVersion, -1, -1, "版本 %d 不是标准版本", tx.Version)
+	}
+	if report.Weight > MaxStandardTxWeight {
+		add(RuleTxSize, -1, -1, "重量 %d WU 超过 %d WU", report.Weight, MaxStandardTxWeight)
+	}
+
+	var inValue int64
+	missing := false
+	sigHashes := txscript.NewTxSigHashes(tx, prevOuts)
+	for i, in := range tx.TxIn {
+		if len(in.SignatureScript) > MaxStandardScriptSigSize {
+			add(RuleScriptSigSize, i, -1, "scriptSig %d 字节超过 %d 字节", len(in.SignatureScript), MaxStandardScriptSigSize)
+		}
+		if !txscript.IsPushOnlyScript(in.SignatureScript) {
+			add(RuleScriptSigPush, i, -1, "scriptSig 只能包含数据推送")
+		}
+
+		prevOut := prevOuts.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			add(RuleMissingInputs, i, -1, "缺少 %s 的前一笔输出", in.PreviousOutPoint)
+			missing = true
+			continue
+		}
+		inValue += prevOut.Value
+
+		engine, err := txscript.NewEngine(prevOut.PkScript, tx, i, StandardVerifyFlags, nil, sigHashes, prevOut.Value, prevOuts)
+		if err == nil {
+			err = engine.Execute()
+		}
+		if err != nil {
+			add(RuleScript, i, -1, "%s 脚本验证失败: %s", txscript.GetScriptClass(prevOut.PkScript), scriptErrorReason(err))
+		}
+	}
+
+	var outValue int64
+	for i, out := range tx.TxOut {
+		outValue += out.Value
+		if !isNullData(out.PkScript) && txscript.GetScriptClass(out.PkScript) == txscript.NonStandardTy {
+			add(RuleScriptPubKey, -1, i, "非标准的输出脚本")
+		}
+		if threshold := DustThreshold(out.PkScript, opts.DustRelayFeeRate); out.Value < threshold {
+			add(RuleDust, -1, i, "金额 %d sat 低于粉尘阈值 %d sat", out.Value, threshold)
+		}
+	}
+	if size := DataCarrierSize(tx); size > opts.DataCarrierSize {
+		add(RuleDataCarrier, -1, -1, "OP_RETURN 输出脚本共 %d 字节，超过 %d 字节", size, opts.DataCarrierSize)
+	}
+
+	// 缺少前一笔输出时无法计算手续费
+	if missing {
+		return report
+	}
+	report.Fee = inValue - outValue
+	switch {
+	case report.Fee < 0:
+		add(RuleNegativeFee, -1, -1, "输出总额 %d sat 超过输入总额 %d sat", outValue, inValue)
+	case report.Fee < report.VSize*opts.MinRelayFeeRate:
+		add(RuleMinRelayFee, -1, -1, "手续费 %d sat 低于 %d vB × %d sat/vB", report.Fee, report.VSize, opts.MinRelayFeeRate)
+	}
+	if opts.MaxFeeRate > 0 && report.Fee > report.VSize*opts.MaxFeeRate {
+		add(RuleMaxFeeRate, -1, -1, "费率 %.2f sat/vB 超过上限 %d sat/vB", report.FeeRate(), opts.MaxFeeRate)
+	}
+	if opts.MaxFee > 0 && report.Fee > opts.MaxFee {
+		add(RuleMaxFee, -1, -1, "手续费 %d sat 超过上限 %d sat", report.Fee, opts.MaxFee)
+	}
+	return report
+}
+
+// DataCarrierSize 返回交易中所有 OP_RETURN 输出脚本的总字节数
+func DataCarrierSize(tx *wire.MsgTx) int {
+	var size int
+	for _, out := range tx.TxOut {
+		if isNullData(out.PkScript) {
+			size += len(out.PkScript)
+		}
+	}
+	return size
+}
+
+// isNullData OP_RETURN 后面只有数据推送的输出。btcd 的 NullDataTy 只接受一次推送，
+// 节点则允许多次推送，只限制脚本总大小。
+func isNullData(pkScript []byte) bool {
+	return len(pkScript) > 0 && pkScript[0] == txscript.OP_RETURN && txscript.IsPushOnlyScript(pkScript[1:])
+}
+
+// scriptErrorReason 返回脚本错误的描述，txscript 的部分错误没有描述时使用错误码名称
+func scriptErrorReason(err error) string {
+	var scriptErr txscript.Error
+	if errors.As(err, &scriptErr) && scriptErr.Description == "" {
+		return scriptErr.ErrorCode.String()
+	}
+	return err.Error()
+}
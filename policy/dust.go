@@ -0,0 +1,76 @@
+// Package policy 实现节点的交易转发策略（standardness）检查，
+// 不符合这些规则的交易虽然在共识上有效，但会被大多数节点拒绝转发。
+package policy
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultDustRelayFeeRate Bitcoin Core 默认的粉尘费率 (-dustrelayfee=3000 sat/kvB)，单位 sat/vB
+	DefaultDustRelayFeeRate = 3
+
+	// DefaultDiscardFeeRate Bitcoin Core 默认的找零丢弃费率 (-discardfee=10000 sat/kvB)，单位 sat/vB。
+	// 以该费率花费找零的成本超过找零金额时，找零不划算，直接并入手续费。
+	DefaultDiscardFeeRate = 10
+)
+
+// DustThreshold 返回输出的粉尘阈值：输出本身加上花费它的最小输入的大小，乘以粉尘费率。
+// 与 Bitcoin Core 的 GetDustThreshold 一致：见证程序输入按 32+4+1+107/4+4 = 67 字节，
+// 其它输入按 32+4+1+107+4 = 148 字节。默认费率下 P2PKH 为 546，P2WPKH 为 294，P2TR 为 330。
+// 不可花费的输出 (OP_RETURN) 没有粉尘阈值。
+func DustThreshold(pkScript []byte, dustRelayFeeRate int64) int64 {
+	if txscript.IsUnspendable(pkScript) {
+		return 0
+	}
+	size := wire.NewTxOut(0, pkScript).SerializeSize()
+	if txscript.IsWitnessProgram(pkScript) {
+		size += 32 + 4 + 1 + 107/4 + 4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return int64(size) * dustRelayFeeRate
+}
+
+// IsDust 输出金额是否低于粉尘阈值
+func IsDust(out *wire.TxOut, dustRelayFeeRate int64) bool {
+	return out.Value < DustThreshold(out.PkScript, dustRelayFeeRate)
+}
+
+// MinViableChange 返回值得创建的最小找零金额：不低于粉尘阈值，
+// 并且大于以丢弃费率花费它的手续费（spendWeight 为花费该找零的输入重量）
+func MinViableChange(pkScript []byte, spendWeight, dustRelayFeeRate, discardFeeRate int64) int64 {
+	dust := DustThreshold(pkScript, dustRelayFeeRate)
+	spendFee := (spendWeight*discardFeeRate+3)/4 + 1
+	if spendFee > dust {
+		return spendFee
+	}
+	return dust
+}
+
+// DustError 输出金额低于粉尘阈值，节点会拒绝转发包含它的交易
+type DustError struct {
+	Index     int // 输出序号，-1 表示尚未确定
+	Value     int64
+	Threshold int64
+}
+
+func (e *DustError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("金额 %d sat 低于粉尘阈值 %d sat", e.Value, e.Threshold)
+	}
+	return fmt.Sprintf("输出 %d 的金额 %d sat 低于粉尘阈值 %d sat", e.Index, e.Value, e.Threshold)
+}
+
+// CheckDust 检查交易中的所有输出，返回第一个粉尘输出的 *DustError
+func CheckDust(tx *wire.MsgTx, dustRelayFeeRate int64) error {
+	for i, out := range tx.TxOut {
+		if threshold := DustThreshold(out.PkScript, dustRelayFeeRate); out.Value < threshold {
+			return &DustError{Index: i, Value: out.Value, Threshold: threshold}
+		}
+	}
+	return nil
+}
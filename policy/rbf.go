@@ -0,0 +1,47 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// DefaultIncrementalRelayFeeRate Bitcoin Core 默认的增量转发费率 (-incrementalrelayfee=1000 sat/kvB)，单位 sat/vB。
+	// 替换交易多付的手续费至少要支付自身大小乘以该费率，否则节点不会转发。
+	DefaultIncrementalRelayFeeRate = 1
+
+	// MaxRBFSequence 声明可替换 (BIP-125) 的最大 nSequence，任一输入不超过该值即可被替换
+	MaxRBFSequence = wire.MaxTxInSequenceNum - 2
+)
+
+// SignalsRBF 交易是否声明可被替换 (BIP-125 opt-in)
+func SignalsRBF(tx *wire.MsgTx) bool {
+	for _, in := range tx.TxIn {
+		if in.Sequence <= MaxRBFSequence {
+			return true
+		}
+	}
+	return false
+}
+
+// MinReplacementFee 返回替换交易需要的最低手续费 (BIP-125 规则 3、4)：
+// 不低于原交易的手续费，并且多出的部分至少为替换交易虚拟大小乘以增量转发费率
+func MinReplacementFee(originalFee, replacementVSize, incrementalRelayFeeRate int64) int64 {
+	return originalFee + replacementVSize*incrementalRelayFeeRate
+}
+
+// CheckReplacement 检查替换交易的手续费是否满足 BIP-125：
+// 绝对手续费不低于 MinReplacementFee，费率高于原交易
+func CheckReplacement(originalFee, originalVSize, fee, vsize, incrementalRelayFeeRate int64) error {
+	if minFee := MinReplacementFee(originalFee, vsize, incrementalRelayFeeRate); fee < minFee {
+		return fmt.Errorf("替换交易手续费 %d sat 低于最低要求 %d sat（原手续费 %d sat + %d vB × %d sat/vB）",
+			fee, minFee, originalFee, vsize, incrementalRelayFeeRate)
+	}
+	// 按交叉相乘比较费率，避免整数除法误差
+	if fee*originalVSize <= originalFee*vsize {
+		return fmt.Errorf("替换交易费率 %.2f sat/vB 不高于原交易的 %.2f sat/vB",
+			float64(fee)/float64(vsize), float64(originalFee)/float64(originalVSize))
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package psbt
+
+import (
+	"fmt"
+)
+
+// Combine 合并多个签名方分别签过的同一笔 PSBT (BIP-174 Combiner)。
+// 每个输入、输出按键取并集，同一个键以先出现的值为准；结果的版本与第一个 PSBT 相同。
+func Combine(packets ...*Packet) (*Packet, error) {
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("没有需要合并的 PSBT")
+	}
+
+	first := packets[0]
+	tx, err := first.UnsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	txid := tx.TxHash()
+
+	inputs := make([][]kv, len(first.Inputs))
+	outputs := make([][]kv, len(first.Outputs))
+	globals, err := first.encodeGlobal()
+	if err != nil {
+		return nil, err
+	}
+	for i := range first.Inputs {
+		inputs[i] = encodeInput(&first.Inputs[i], Version2)
+	}
+	for i := range first.Outputs {
+		outputs[i] = encodeOutput(&first.Outputs[i], Version2)
+	}
+
+	for n, p := range packets[1:] {
+		other, err := p.UnsignedTx()
+		if err != nil {
+			return nil, err
+		}
+		if other.TxHash() != txid {
+			return nil, fmt.Errorf("第 %d 个 PSBT 的交易 %s 与第 1 个的 %s 不同", n+2, other.TxHash(), txid)
+		}
+		otherGlobals, err := p.encodeGlobal()
+		if err != nil {
+			return nil, err
+		}
+		globals = mergeMap(globals, otherGlobals)
+		for i := range p.Inputs {
+			inputs[i] = mergeMap(inputs[i], encodeInput(&p.Inputs[i], Version2))
+		}
+		for i := range p.Outputs {
+			outputs[i] = mergeMap(outputs[i], encodeOutput(&p.Outputs[i], Version2))
+		}
+	}
+
+	// 全局字段只合并扩展公钥和未知字段，交易本身已确认一致
+	result := &Packet{
+		Version:          first.Version,
+		TxVersion:        first.TxVersion,
+		FallbackLocktime: first.FallbackLocktime,
+		TxModifiable:     first.TxModifiable,
+		Inputs:           make([]Input, len(inputs)),
+		Outputs:          make([]Output, len(outputs)),
+	}
+	for _, pair := range globals {
+		t, keyData, err := pair.keyType()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t == globalXPub:
+			fp, path, err := decodeOrigin(pair.value)
+			if err != nil {
+				return nil, err
+			}
+			result.XPubs = append(result.XPubs, XPub{ExtendedKey: keyData, Fingerprint: fp, Path: path})
+		case t > globalTxModifiable && t != globalVersion:
+			result.Unknowns = append(result.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+		}
+	}
+	for i, pairs := range inputs {
+		if err := decodeInput(&result.Inputs[i], pairs, Version2); err != nil {
+			return nil, fmt.Errorf("合并输入 %d 失败: %w", i, err)
+		}
+	}
+	for i, pairs := range outputs {
+		if err := decodeOutput(&result.Outputs[i], pairs, Version2); err != nil {
+			return nil, fmt.Errorf("合并输出 %d 失败: %w", i, err)
+		}
+	}
+	return result, nil
+}
+
+// mergeMap 把 b 中 a 没有的键加入 a
+func mergeMap(a, b []kv) []kv {
+	seen := make(map[string]bool, len(a))
+	for _, pair := range a {
+		seen[string(pair.key)] = true
+	}
+	for _, pair := range b {
+		if !seen[string(pair.key)] {
+			a = append(a, pair)
+			seen[string(pair.key)] = true
+		}
+	}
+	return a
+}
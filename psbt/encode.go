@@ -0,0 +1,941 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// magic PSBT 数据的前 5 个字节
+var magic = []byte{0x70, 0x73, 0x62, 0x74, 0xff}
+
+// 全局键类型
+const (
+	globalUnsignedTx       = 0x00
+	globalXPub             = 0x01
+	globalTxVersion        = 0x02
+	globalFallbackLocktime = 0x03
+	globalInputCount       = 0x04
+	globalOutputCount      = 0x05
+	globalTxModifiable     = 0x06
+	globalVersion          = 0xfb
+)
+
+// 输入键类型
+const (
+	inNonWitnessUtxo         = 0x00
+	inWitnessUtxo            = 0x01
+	inPartialSig             = 0x02
+	inSighashType            = 0x03
+	inRedeemScript           = 0x04
+	inWitnessScript          = 0x05
+	inBip32Derivation        = 0x06
+	inFinalScriptSig         = 0x07
+	inFinalScriptWitness     = 0x08
+	inPreviousTxid           = 0x0e
+	inOutputIndex            = 0x0f
+	inSequence               = 0x10
+	inRequiredTimeLocktime   = 0x11
+	inRequiredHeightLocktime = 0x12
+	inTapKeySig              = 0x13
+	inTapScriptSig           = 0x14
+	inTapLeafScript          = 0x15
+	inTapBip32Derivation     = 0x16
+	inTapInternalKey         = 0x17
+	inTapMerkleRoot          = 0x18
+)
+
+// 输出键类型
+const (
+	outRedeemScript       = 0x00
+	outWitnessScript      = 0x01
+	outBip32Derivation    = 0x02
+	outAmount             = 0x03
+	outScript             = 0x04
+	outTapInternalKey     = 0x05
+	outTapTree            = 0x06
+	outTapBip32Derivation = 0x07
+)
+
+// kv 一个键值对，key 包含键类型
+type kv struct {
+	key   []byte
+	value []byte
+}
+
+// keyType 返回键类型和键数据
+func (p kv) keyType() (uint64, []byte, error) {
+	r := bytes.NewReader(p.key)
+	t, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return 0, nil, fmt.Errorf("无效的键: %w", err)
+	}
+	return t, p.key[len(p.key)-r.Len():], nil
+}
+
+// newKV 用键类型和键数据构造键值对
+func newKV(keyType uint64, keyData, value []byte) kv {
+	var key bytes.Buffer
+	_ = wire.WriteVarInt(&key, 0, keyType)
+	key.Write(keyData)
+	return kv{key: key.Bytes(), value: value}
+}
+
+// readMap 读取一个以 0x00 结束的键值表
+func readMap(r io.Reader) ([]kv, error) {
+	var pairs []kv
+	seen := make(map[string]bool)
+	for {
+		keyLen, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取键长度失败: %w", err)
+		}
+		if keyLen == 0 {
+			return pairs, nil
+		}
+		key, err := readBytes(r, keyLen)
+		if err != nil {
+			return nil, fmt.Errorf("读取键失败: %w", err)
+		}
+		valueLen, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return nil, fmt.Errorf("读取值长度失败: %w", err)
+		}
+		value, err := readBytes(r, valueLen)
+		if err != nil {
+			return nil, fmt.Errorf("读取值失败: %w", err)
+		}
+		if seen[string(key)] {
+			return nil, fmt.Errorf("重复的键 %x", key)
+		}
+		seen[string(key)] = true
+		pairs = append(pairs, kv{key: key, value: value})
+	}
+}
+
+// readBytes 读取指定长度的数据，长度超过 PSBT 可能的大小时直接报错
+func readBytes(r io.Reader, n uint64) ([]byte, error) {
+	const maxSize = 4_000_000
+	if n > maxSize {
+		return nil, fmt.Errorf("长度 %d 超出范围", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// writeMap 写入键值表和结束符，按键排序以保证输出稳定
+func writeMap(w io.Writer, pairs []kv) error {
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+	})
+	for _, p := range pairs {
+		if err := wire.WriteVarBytes(w, 0, p.key); err != nil {
+			return err
+		}
+		if err := wire.WriteVarBytes(w, 0, p.value); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte{0x00})
+	return err
+}
+
+// Parse 解析二进制 PSBT
+func Parse(data []byte) (*Packet, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return nil, ErrInvalidMagic
+	}
+	r := bytes.NewReader(data[len(magic):])
+
+	globals, err := readMap(r)
+	if err != nil {
+		return nil, fmt.Errorf("解析全局字段失败: %w", err)
+	}
+	p, tx, inputCount, outputCount, err := decodeGlobal(globals)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Inputs = make([]Input, inputCount)
+	for i := range p.Inputs {
+		pairs, err := readMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("解析输入 %d 失败: %w", i, err)
+		}
+		if err := decodeInput(&p.Inputs[i], pairs, p.Version); err != nil {
+			return nil, fmt.Errorf("解析输入 %d 失败: %w", i, err)
+		}
+		if tx != nil {
+			p.Inputs[i].PreviousOutPoint = tx.TxIn[i].PreviousOutPoint
+			p.Inputs[i].Sequence = tx.TxIn[i].Sequence
+		}
+		if err := p.Inputs[i].checkUtxo(); err != nil {
+			return nil, fmt.Errorf("输入 %d: %w", i, err)
+		}
+	}
+
+	p.Outputs = make([]Output, outputCount)
+	for i := range p.Outputs {
+		pairs, err := readMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("解析输出 %d 失败: %w", i, err)
+		}
+		if err := decodeOutput(&p.Outputs[i], pairs, p.Version); err != nil {
+			return nil, fmt.Errorf("解析输出 %d 失败: %w", i, err)
+		}
+		if tx != nil {
+			p.Outputs[i].Amount = tx.TxOut[i].Value
+			p.Outputs[i].Script = tx.TxOut[i].PkScript
+		}
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("PSBT 末尾有 %d 字节多余数据", r.Len())
+	}
+	return p, nil
+}
+
+// ParseBase64 解析 base64 编码的 PSBT
+func ParseBase64(s string) (*Packet, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("base64 解码失败: %w", err)
+	}
+	return Parse(data)
+}
+
+// Serialize 序列化为二进制 PSBT
+func (p *Packet) Serialize() ([]byte, error) {
+	globals, err := p.encodeGlobal()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.Write(magic)
+	if err := writeMap(&buf, globals); err != nil {
+		return nil, err
+	}
+	for i := range p.Inputs {
+		if err := writeMap(&buf, encodeInput(&p.Inputs[i], p.Version)); err != nil {
+			return nil, err
+		}
+	}
+	for i := range p.Outputs {
+		if err := writeMap(&buf, encodeOutput(&p.Outputs[i], p.Version)); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Base64 序列化为 base64 字符串
+func (p *Packet) Base64() (string, error) {
+	data, err := p.Serialize()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// decodeGlobal 解析全局字段。v0 返回其中的未签名交易，输入输出数量取自该交易。
+func decodeGlobal(pairs []kv) (*Packet, *wire.MsgTx, int, int, error) {
+	p := &Packet{}
+	var (
+		tx                      *wire.MsgTx
+		hasTxVersion            bool
+		inputCount, outputCount = -1, -1
+	)
+
+	for _, pair := range pairs {
+		t, keyData, err := pair.keyType()
+		if err != nil {
+			return nil, nil, 0, 0, err
+		}
+		noKeyData := func() error {
+			if len(keyData) != 0 {
+				return fmt.Errorf("全局字段 0x%02x 的键数据必须为空", t)
+			}
+			return nil
+		}
+
+		switch t {
+		case globalUnsignedTx:
+			if err := noKeyData(); err != nil {
+				return nil, nil, 0, 0, err
+			}
+			tx = wire.NewMsgTx(0)
+			if err := tx.DeserializeNoWitness(bytes.NewReader(pair.value)); err != nil {
+				return nil, nil, 0, 0, fmt.Errorf("解析未签名交易失败: %w", err)
+			}
+			for i, in := range tx.TxIn {
+				if len(in.SignatureScript) != 0 || len(in.Witness) != 0 {
+					return nil, nil, 0, 0, fmt.Errorf("未签名交易的输入 %d 带有签名", i)
+				}
+			}
+		case globalXPub:
+			if len(keyData) != 78 {
+				return nil, nil, 0, 0, fmt.Errorf("扩展公钥长度应为 78 字节")
+			}
+			fp, path, err := decodeOrigin(pair.value)
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			p.XPubs = append(p.XPubs, XPub{ExtendedKey: keyData, Fingerprint: fp, Path: path})
+		case globalTxVersion:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			p.TxVersion = int32(v)
+			hasTxVersion = true
+		case globalFallbackLocktime:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			p.FallbackLocktime = v
+		case globalInputCount, globalOutputCount:
+			if err := noKeyData(); err != nil {
+				return nil, nil, 0, 0, err
+			}
+			n, err := readCompact(pair.value)
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			if t == globalInputCount {
+				inputCount = n
+			} else {
+				outputCount = n
+			}
+		case globalTxModifiable:
+			if err := noKeyData(); err != nil {
+				return nil, nil, 0, 0, err
+			}
+			if len(pair.value) != 1 {
+				return nil, nil, 0, 0, fmt.Errorf("PSBT_GLOBAL_TX_MODIFIABLE 长度应为 1 字节")
+			}
+			p.TxModifiable = pair.value[0]
+		case globalVersion:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return nil, nil, 0, 0, err
+			}
+			p.Version = v
+		default:
+			p.Unknowns = append(p.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+		}
+	}
+
+	switch p.Version {
+	case Version0:
+		if tx == nil {
+			return nil, nil, 0, 0, fmt.Errorf("PSBT v0 缺少未签名交易")
+		}
+		if hasTxVersion || inputCount >= 0 || outputCount >= 0 || p.FallbackLocktime != 0 || p.TxModifiable != 0 {
+			return nil, nil, 0, 0, fmt.Errorf("PSBT v0 不能包含 v2 的全局字段")
+		}
+		p.TxVersion = tx.Version
+		p.FallbackLocktime = tx.LockTime
+		return p, tx, len(tx.TxIn), len(tx.TxOut), nil
+	case Version2:
+		if tx != nil {
+			return nil, nil, 0, 0, fmt.Errorf("PSBT v2 不能包含未签名交易")
+		}
+		if !hasTxVersion || inputCount < 0 || outputCount < 0 {
+			return nil, nil, 0, 0, fmt.Errorf("PSBT v2 缺少交易版本或输入输出数量")
+		}
+		return p, nil, inputCount, outputCount, nil
+	default:
+		return nil, nil, 0, 0, fmt.Errorf("%w: %d", ErrUnsupportedVersion, p.Version)
+	}
+}
+
+// encodeGlobal 编码全局字段
+func (p *Packet) encodeGlobal() ([]kv, error) {
+	var pairs []kv
+	switch p.Version {
+	case Version0:
+		tx, err := p.UnsignedTx()
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tx.SerializeNoWitness(&buf); err != nil {
+			return nil, err
+		}
+		pairs = append(pairs, newKV(globalUnsignedTx, nil, buf.Bytes()))
+	case Version2:
+		pairs = append(pairs,
+			newKV(globalTxVersion, nil, encodeUint32(uint32(p.TxVersion))),
+			newKV(globalInputCount, nil, encodeCompact(len(p.Inputs))),
+			newKV(globalOutputCount, nil, encodeCompact(len(p.Outputs))),
+			newKV(globalVersion, nil, encodeUint32(p.Version)),
+		)
+		if p.FallbackLocktime != 0 {
+			pairs = append(pairs, newKV(globalFallbackLocktime, nil, encodeUint32(p.FallbackLocktime)))
+		}
+		if p.TxModifiable != 0 {
+			pairs = append(pairs, newKV(globalTxModifiable, nil, []byte{p.TxModifiable}))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, p.Version)
+	}
+
+	for _, x := range p.XPubs {
+		pairs = append(pairs, newKV(globalXPub, x.ExtendedKey, encodeOrigin(x.Fingerprint, x.Path)))
+	}
+	for _, u := range p.Unknowns {
+		pairs = append(pairs, kv{key: u.Key, value: u.Value})
+	}
+	return pairs, nil
+}
+
+// decodeInput 解析一个输入的键值表
+func decodeInput(in *Input, pairs []kv, version uint32) error {
+	in.Sequence = wire.MaxTxInSequenceNum
+	var hasTxid, hasIndex bool
+
+	for _, pair := range pairs {
+		t, keyData, err := pair.keyType()
+		if err != nil {
+			return err
+		}
+		noKeyData := func() error {
+			if len(keyData) != 0 {
+				return fmt.Errorf("输入字段 0x%02x 的键数据必须为空", t)
+			}
+			return nil
+		}
+		if t >= inPreviousTxid && t <= inRequiredHeightLocktime && version == Version0 {
+			// 带键数据的键不是 v2 的字段，与其他未知键一样保留
+			if len(keyData) == 0 {
+				return fmt.Errorf("PSBT v0 不能包含输入字段 0x%02x", t)
+			}
+			in.Unknowns = append(in.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+			continue
+		}
+
+		switch t {
+		case inNonWitnessUtxo:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			tx := wire.NewMsgTx(0)
+			if err := tx.Deserialize(bytes.NewReader(pair.value)); err != nil {
+				return fmt.Errorf("解析 non-witness UTXO 失败: %w", err)
+			}
+			in.NonWitnessUtxo = tx
+		case inWitnessUtxo:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			out, err := decodeTxOut(pair.value)
+			if err != nil {
+				return err
+			}
+			in.WitnessUtxo = out
+		case inPartialSig:
+			if len(keyData) != 33 && len(keyData) != 65 {
+				return fmt.Errorf("部分签名的公钥长度无效")
+			}
+			in.PartialSigs = append(in.PartialSigs, PartialSig{PubKey: keyData, Signature: pair.value})
+		case inSighashType:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return err
+			}
+			in.SighashType = txscript.SigHashType(v)
+		case inRedeemScript:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			in.RedeemScript = pair.value
+		case inWitnessScript:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			in.WitnessScript = pair.value
+		case inBip32Derivation:
+			d, err := decodeBip32(keyData, pair.value)
+			if err != nil {
+				return err
+			}
+			in.Bip32Derivation = append(in.Bip32Derivation, d)
+		case inFinalScriptSig:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			in.FinalScriptSig = pair.value
+		case inFinalScriptWitness:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			witness, err := decodeWitness(pair.value)
+			if err != nil {
+				return err
+			}
+			in.FinalScriptWitness = witness
+		case inPreviousTxid:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			hash, err := chainhash.NewHash(pair.value)
+			if err != nil {
+				return fmt.Errorf("前一笔交易 ID 无效: %w", err)
+			}
+			in.PreviousOutPoint.Hash = *hash
+			hasTxid = true
+		case inOutputIndex:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return err
+			}
+			in.PreviousOutPoint.Index = v
+			hasIndex = true
+		case inSequence:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return err
+			}
+			in.Sequence = v
+		case inRequiredTimeLocktime:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return err
+			}
+			if v < txscript.LockTimeThreshold {
+				return fmt.Errorf("要求的时间锁 %d 小于 %d", v, uint32(txscript.LockTimeThreshold))
+			}
+			in.RequiredTimeLocktime = v
+		case inRequiredHeightLocktime:
+			v, err := decodeUint32(pair.value, noKeyData)
+			if err != nil {
+				return err
+			}
+			if v == 0 || v >= txscript.LockTimeThreshold {
+				return fmt.Errorf("要求的高度锁 %d 超出范围", v)
+			}
+			in.RequiredHeightLocktime = v
+		case inTapKeySig:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			if len(pair.value) != 64 && len(pair.value) != 65 {
+				return fmt.Errorf("taproot 密钥路径签名长度无效")
+			}
+			in.TapKeySig = pair.value
+		case inTapScriptSig:
+			if len(keyData) != 64 {
+				return fmt.Errorf("taproot 脚本签名的键长度无效")
+			}
+			if len(pair.value) != 64 && len(pair.value) != 65 {
+				return fmt.Errorf("taproot 脚本签名长度无效")
+			}
+			in.TapScriptSigs = append(in.TapScriptSigs, TapScriptSig{
+				XOnlyPubKey: keyData[:32],
+				LeafHash:    keyData[32:],
+				Signature:   pair.value,
+			})
+		case inTapLeafScript:
+			if len(keyData) < 33 || (len(keyData)-33)%32 != 0 {
+				return fmt.Errorf("控制块长度无效")
+			}
+			if len(pair.value) < 1 {
+				return fmt.Errorf("脚本叶子为空")
+			}
+			in.TapLeafScripts = append(in.TapLeafScripts, TapLeafScript{
+				ControlBlock: keyData,
+				Script:       pair.value[:len(pair.value)-1],
+				LeafVersion:  txscript.TapscriptLeafVersion(pair.value[len(pair.value)-1]),
+			})
+		case inTapBip32Derivation:
+			d, err := decodeTapBip32(keyData, pair.value)
+			if err != nil {
+				return err
+			}
+			in.TapBip32Derivation = append(in.TapBip32Derivation, d)
+		case inTapInternalKey:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			if len(pair.value) != 32 {
+				return fmt.Errorf("taproot 内部公钥长度应为 32 字节")
+			}
+			in.TapInternalKey = pair.value
+		case inTapMerkleRoot:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			if len(pair.value) != 32 {
+				return fmt.Errorf("taproot merkle 根长度应为 32 字节")
+			}
+			in.TapMerkleRoot = pair.value
+		default:
+			in.Unknowns = append(in.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+		}
+	}
+
+	if version == Version2 && (!hasTxid || !hasIndex) {
+		return fmt.Errorf("PSBT v2 的输入缺少前一笔交易 ID 或输出索引")
+	}
+	return nil
+}
+
+// encodeInput 编码一个输入
+func encodeInput(in *Input, version uint32) []kv {
+	var pairs []kv
+	add := func(t uint64, keyData, value []byte) {
+		pairs = append(pairs, newKV(t, keyData, value))
+	}
+
+	if in.NonWitnessUtxo != nil {
+		var buf bytes.Buffer
+		_ = in.NonWitnessUtxo.Serialize(&buf)
+		add(inNonWitnessUtxo, nil, buf.Bytes())
+	}
+	if in.WitnessUtxo != nil {
+		add(inWitnessUtxo, nil, encodeTxOut(in.WitnessUtxo))
+	}
+	for _, s := range in.PartialSigs {
+		add(inPartialSig, s.PubKey, s.Signature)
+	}
+	if in.SighashType != 0 {
+		add(inSighashType, nil, encodeUint32(uint32(in.SighashType)))
+	}
+	if in.RedeemScript != nil {
+		add(inRedeemScript, nil, in.RedeemScript)
+	}
+	if in.WitnessScript != nil {
+		add(inWitnessScript, nil, in.WitnessScript)
+	}
+	for _, d := range in.Bip32Derivation {
+		add(inBip32Derivation, d.PubKey, encodeOrigin(d.Fingerprint, d.Path))
+	}
+	if in.FinalScriptSig != nil {
+		add(inFinalScriptSig, nil, in.FinalScriptSig)
+	}
+	if in.FinalScriptWitness != nil {
+		add(inFinalScriptWitness, nil, encodeWitness(in.FinalScriptWitness))
+	}
+
+	if version == Version2 {
+		add(inPreviousTxid, nil, in.PreviousOutPoint.Hash[:])
+		add(inOutputIndex, nil, encodeUint32(in.PreviousOutPoint.Index))
+		if in.Sequence != wire.MaxTxInSequenceNum {
+			add(inSequence, nil, encodeUint32(in.Sequence))
+		}
+		if in.RequiredTimeLocktime != 0 {
+			add(inRequiredTimeLocktime, nil, encodeUint32(in.RequiredTimeLocktime))
+		}
+		if in.RequiredHeightLocktime != 0 {
+			add(inRequiredHeightLocktime, nil, encodeUint32(in.RequiredHeightLocktime))
+		}
+	}
+
+	if in.TapKeySig != nil {
+		add(inTapKeySig, nil, in.TapKeySig)
+	}
+	for _, s := range in.TapScriptSigs {
+		add(inTapScriptSig, append(append([]byte(nil), s.XOnlyPubKey...), s.LeafHash...), s.Signature)
+	}
+	for _, l := range in.TapLeafScripts {
+		add(inTapLeafScript, l.ControlBlock, append(append([]byte(nil), l.Script...), byte(l.LeafVersion)))
+	}
+	for _, d := range in.TapBip32Derivation {
+		add(inTapBip32Derivation, d.XOnlyPubKey, encodeTapBip32(d))
+	}
+	if in.TapInternalKey != nil {
+		add(inTapInternalKey, nil, in.TapInternalKey)
+	}
+	if in.TapMerkleRoot != nil {
+		add(inTapMerkleRoot, nil, in.TapMerkleRoot)
+	}
+	for _, u := range in.Unknowns {
+		pairs = append(pairs, kv{key: u.Key, value: u.Value})
+	}
+	return pairs
+}
+
+// decodeOutput 解析一个输出的键值表
+func decodeOutput(out *Output, pairs []kv, version uint32) error {
+	var hasAmount, hasScript bool
+	for _, pair := range pairs {
+		t, keyData, err := pair.keyType()
+		if err != nil {
+			return err
+		}
+		noKeyData := func() error {
+			if len(keyData) != 0 {
+				return fmt.Errorf("输出字段 0x%02x 的键数据必须为空", t)
+			}
+			return nil
+		}
+		if (t == outAmount || t == outScript) && version == Version0 {
+			if len(keyData) == 0 {
+				return fmt.Errorf("PSBT v0 不能包含输出字段 0x%02x", t)
+			}
+			out.Unknowns = append(out.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+			continue
+		}
+
+		switch t {
+		case outRedeemScript:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			out.RedeemScript = pair.value
+		case outWitnessScript:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			out.WitnessScript = pair.value
+		case outBip32Derivation:
+			d, err := decodeBip32(keyData, pair.value)
+			if err != nil {
+				return err
+			}
+			out.Bip32Derivation = append(out.Bip32Derivation, d)
+		case outAmount:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			if len(pair.value) != 8 {
+				return fmt.Errorf("输出金额长度应为 8 字节")
+			}
+			out.Amount = int64(binary.LittleEndian.Uint64(pair.value))
+			hasAmount = true
+		case outScript:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			out.Script = pair.value
+			hasScript = true
+		case outTapInternalKey:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			if len(pair.value) != 32 {
+				return fmt.Errorf("taproot 内部公钥长度应为 32 字节")
+			}
+			out.TapInternalKey = pair.value
+		case outTapTree:
+			if err := noKeyData(); err != nil {
+				return err
+			}
+			out.TapTree = pair.value
+		case outTapBip32Derivation:
+			d, err := decodeTapBip32(keyData, pair.value)
+			if err != nil {
+				return err
+			}
+			out.TapBip32Derivation = append(out.TapBip32Derivation, d)
+		default:
+			out.Unknowns = append(out.Unknowns, Unknown{Key: pair.key, Value: pair.value})
+		}
+	}
+
+	if version == Version2 && (!hasAmount || !hasScript) {
+		return fmt.Errorf("PSBT v2 的输出缺少金额或锁定脚本")
+	}
+	return nil
+}
+
+// encodeOutput 编码一个输出
+func encodeOutput(out *Output, version uint32) []kv {
+	var pairs []kv
+	add := func(t uint64, keyData, value []byte) {
+		pairs = append(pairs, newKV(t, keyData, value))
+	}
+
+	if out.RedeemScript != nil {
+		add(outRedeemScript, nil, out.RedeemScript)
+	}
+	if out.WitnessScript != nil {
+		add(outWitnessScript, nil, out.WitnessScript)
+	}
+	for _, d := range out.Bip32Derivation {
+		add(outBip32Derivation, d.PubKey, encodeOrigin(d.Fingerprint, d.Path))
+	}
+	if version == Version2 {
+		amount := make([]byte, 8)
+		binary.LittleEndian.PutUint64(amount, uint64(out.Amount))
+		add(outAmount, nil, amount)
+		add(outScript, nil, out.Script)
+	}
+	if out.TapInternalKey != nil {
+		add(outTapInternalKey, nil, out.TapInternalKey)
+	}
+	if out.TapTree != nil {
+		add(outTapTree, nil, out.TapTree)
+	}
+	for _, d := range out.TapBip32Derivation {
+		add(outTapBip32Derivation, d.XOnlyPubKey, encodeTapBip32(d))
+	}
+	for _, u := range out.Unknowns {
+		pairs = append(pairs, kv{key: u.Key, value: u.Value})
+	}
+	return pairs
+}
+
+// decodeUint32 解析 4 字节小端整数
+func decodeUint32(value []byte, check func() error) (uint32, error) {
+	if err := check(); err != nil {
+		return 0, err
+	}
+	if len(value) != 4 {
+		return 0, fmt.Errorf("字段长度应为 4 字节，实际为 %d", len(value))
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+func encodeUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// readCompact 解析整个值为一个 compact size 整数
+func readCompact(value []byte) (int, error) {
+	r := bytes.NewReader(value)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil || r.Len() != 0 {
+		return 0, fmt.Errorf("无效的数量字段")
+	}
+	if n > 100000 {
+		return 0, fmt.Errorf("数量 %d 超出范围", n)
+	}
+	return int(n), nil
+}
+
+func encodeCompact(n int) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarInt(&buf, 0, uint64(n))
+	return buf.Bytes()
+}
+
+// decodeOrigin 解析 <4 字节指纹><每级 4 字节小端索引>
+func decodeOrigin(value []byte) (uint32, []uint32, error) {
+	if len(value) < 4 || len(value)%4 != 0 {
+		return 0, nil, fmt.Errorf("派生路径长度无效")
+	}
+	fingerprint := binary.BigEndian.Uint32(value[:4])
+	path := make([]uint32, 0, len(value)/4-1)
+	for i := 4; i < len(value); i += 4 {
+		path = append(path, binary.LittleEndian.Uint32(value[i:]))
+	}
+	return fingerprint, path, nil
+}
+
+// encodeOrigin 编码指纹和派生路径，指纹按原始字节顺序写入
+func encodeOrigin(fingerprint uint32, path []uint32) []byte {
+	b := make([]byte, 4+4*len(path))
+	binary.BigEndian.PutUint32(b, fingerprint)
+	for i, index := range path {
+		binary.LittleEndian.PutUint32(b[4+4*i:], index)
+	}
+	return b
+}
+
+func decodeBip32(keyData, value []byte) (Bip32Derivation, error) {
+	if len(keyData) != 33 && len(keyData) != 65 {
+		return Bip32Derivation{}, fmt.Errorf("BIP-32 派生信息的公钥长度无效")
+	}
+	fp, path, err := decodeOrigin(value)
+	if err != nil {
+		return Bip32Derivation{}, err
+	}
+	return Bip32Derivation{PubKey: keyData, Fingerprint: fp, Path: path}, nil
+}
+
+// decodeTapBip32 解析 <叶子哈希数量><叶子哈希...><指纹><路径>
+func decodeTapBip32(keyData, value []byte) (TapBip32Derivation, error) {
+	if len(keyData) != 32 {
+		return TapBip32Derivation{}, fmt.Errorf("taproot 派生信息的公钥长度应为 32 字节")
+	}
+	r := bytes.NewReader(value)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil || n > uint64(r.Len()/32) {
+		return TapBip32Derivation{}, fmt.Errorf("taproot 派生信息的叶子哈希数量无效")
+	}
+	d := TapBip32Derivation{XOnlyPubKey: keyData}
+	for i := uint64(0); i < n; i++ {
+		hash := make([]byte, 32)
+		_, _ = io.ReadFull(r, hash)
+		d.LeafHashes = append(d.LeafHashes, hash)
+	}
+	d.Fingerprint, d.Path, err = decodeOrigin(value[len(value)-r.Len():])
+	if err != nil {
+		return TapBip32Derivation{}, err
+	}
+	return d, nil
+}
+
+func encodeTapBip32(d TapBip32Derivation) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(d.LeafHashes)))
+	for _, h := range d.LeafHashes {
+		buf.Write(h)
+	}
+	buf.Write(encodeOrigin(d.Fingerprint, d.Path))
+	return buf.Bytes()
+}
+
+// decodeTxOut 解析 <8 字节金额><脚本>
+func decodeTxOut(value []byte) (*wire.TxOut, error) {
+	r := bytes.NewReader(value)
+	var amount int64
+	if err := binary.Read(r, binary.LittleEndian, &amount); err != nil {
+		return nil, fmt.Errorf("解析 witness UTXO 失败: %w", err)
+	}
+	script, err := wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "pkScript")
+	if err != nil || r.Len() != 0 {
+		return nil, fmt.Errorf("解析 witness UTXO 失败")
+	}
+	return wire.NewTxOut(amount, script), nil
+}
+
+func encodeTxOut(out *wire.TxOut) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteTxOut(&buf, 0, 0, out)
+	return buf.Bytes()
+}
+
+// decodeWitness 解析序列化的见证栈
+func decodeWitness(value []byte) (wire.TxWitness, error) {
+	r := bytes.NewReader(value)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil || n > uint64(r.Len()) {
+		return nil, fmt.Errorf("解析见证失败")
+	}
+	witness := make(wire.TxWitness, n)
+	for i := range witness {
+		witness[i], err = wire.ReadVarBytes(r, 0, txscript.MaxScriptSize, "witness")
+		if err != nil {
+			return nil, fmt.Errorf("解析见证失败: %w", err)
+		}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("见证末尾有多余数据")
+	}
+	return witness, nil
+}
+
+func encodeWitness(witness wire.TxWitness) []byte {
+	var buf bytes.Buffer
+	_ = wire.WriteVarInt(&buf, 0, uint64(len(witness)))
+	for _, item := range witness {
+		_ = wire.WriteVarBytes(&buf, 0, item)
+	}
+	return buf.Bytes()
+}
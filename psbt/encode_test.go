@@ -0,0 +1,351 @@
+package psbt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BIP-174 的有效测试向量
+var bip174Valid = []struct {
+	name string
+	psbt string
+}{
+	{
+		name: "一个 P2PKH 输入，输出为空",
+		psbt: "cHNidP8BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAQD9pQEBAAAAAAECiaPHHqtNIOA3G7ukzGmPopXJRjr6Ljl/hTPMti+VZ+UBAAAAFxYAFL4Y0VKpsBIDna89p95PUzSe7LmF/////4b4qkOnHf8USIk6UwpyN+9rRgi7st0tAXHmOuxqSJC0AQAAABcWABT+Pp7xp0XpdNkCxDVZQ6vLNL1TU/////8CAMLrCwAAAAAZdqkUhc/xCX/Z4Ai7NK9wnGIZeziXikiIrHL++E4sAAAAF6kUM5cluiHv1irHU6m80GfWx6ajnQWHAkcwRAIgJxK+IuAnDzlPVoMR3HyppolwuAJf3TskAinwf4pfOiQCIAGLONfc0xTnNMkna9b7QPZzMlvEuqFEyADS8vAtsnZcASED0uFWdJQbrUqZY3LLh+GFbTZSYG2YVi/jnF6efkE/IQUCSDBFAiEA0SuFLYXc2WHS9fSrZgZU327tzHlMDDPOXMMJ/7X85Y0CIGczio4OFyXBl/saiK9Z9R5E5CVbIBZ8hoQDHAXR8lkqASECI7cr7vCWXRC+B3jv7NYfysb3mk6haTkzgHNEZPhPKrMAAAAAAAAA",
+	},
+	{
+		name: "P2PKH 和 P2SH-P2WPKH 输入，第一个已完成签名",
+		psbt: "cHNidP8BAKACAAAAAqsJSaCMWvfEm4IS9Bfi8Vqz9cM9zxU4IagTn4d6W3vkAAAAAAD+////qwlJoIxa98SbghL0F+LxWrP1wz3PFTghqBOfh3pbe+QBAAAAAP7///8CYDvqCwAAAAAZdqkUdopAu9dAy+gdmI5x3ipNXHE5ax2IrI4kAAAAAAAAGXapFG9GILVT+glechue4O/p+gOcykWXiKwAAAAAAAEHakcwRAIgR1lmF5fAGwNrJZKJSGhiGDR9iYZLcZ4ff89X0eURZYcCIFMJ6r9Wqk2Ikf/REf3xM286KdqGbX+EhtdVRs7tr5MZASEDXNxh/HupccC1AaZGoqg7ECy0OIEhfKaC3Ibi1z+ogpIAAQEgAOH1BQAAAAAXqRQ1RebjO4MsRwUPJNPuuTycA5SLx4cBBBYAFIXRNTfy4mVAWjTbr6nj3aAfuCMIAAAA",
+	},
+	{
+		name: "一个 P2PKH 输入，带有 sighash 类型",
+		psbt: "cHNidP8BAHUCAAAAASaBcTce3/KF6Tet7qSze3gADAVmy7OtZGQXE8pCFxv2AAAAAAD+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAQD9pQEBAAAAAAECiaPHHqtNIOA3G7ukzGmPopXJRjr6Ljl/hTPMti+VZ+UBAAAAFxYAFL4Y0VKpsBIDna89p95PUzSe7LmF/////4b4qkOnHf8USIk6UwpyN+9rRgi7st0tAXHmOuxqSJC0AQAAABcWABT+Pp7xp0XpdNkCxDVZQ6vLNL1TU/////8CAMLrCwAAAAAZdqkUhc/xCX/Z4Ai7NK9wnGIZeziXikiIrHL++E4sAAAAF6kUM5cluiHv1irHU6m80GfWx6ajnQWHAkcwRAIgJxK+IuAnDzlPVoMR3HyppolwuAJf3TskAinwf4pfOiQCIAGLONfc0xTnNMkna9b7QPZzMlvEuqFEyADS8vAtsnZcASED0uFWdJQbrUqZY3LLh+GFbTZSYG2YVi/jnF6efkE/IQUCSDBFAiEA0SuFLYXc2WHS9fSrZgZU327tzHlMDDPOXMMJ/7X85Y0CIGczio4OFyXBl/saiK9Z9R5E5CVbIBZ8hoQDHAXR8lkqASECI7cr7vCWXRC+B3jv7NYfysb3mk6haTkzgHNEZPhPKrMAAAAAAQMEAQAAAAAAAA==",
+	},
+	{
+		name: "P2PKH 和 P2SH-P2WPKH 输入均未完成签名，输出带有派生信息",
+		psbt: "cHNidP8BAKACAAAAAqsJSaCMWvfEm4IS9Bfi8Vqz9cM9zxU4IagTn4d6W3vkAAAAAAD+////qwlJoIxa98SbghL0F+LxWrP1wz3PFTghqBOfh3pbe+QBAAAAAP7///8CYDvqCwAAAAAZdqkUdopAu9dAy+gdmI5x3ipNXHE5ax2IrI4kAAAAAAAAGXapFG9GILVT+glechue4O/p+gOcykWXiKwAAAAAAAEA3wIAAAABJoFxNx7f8oXpN63upLN7eAAMBWbLs61kZBcTykIXG/YAAAAAakcwRAIgcLIkUSPmv0dNYMW1DAQ9TGkaXSQ18Jo0p2YqncJReQoCIAEynKnazygL3zB0DsA5BCJCLIHLRYOUV663b8Eu3ZWzASECZX0RjTNXuOD0ws1G23s59tnDjZpwq8ubLeXcjb/kzjH+////AtPf9QUAAAAAGXapFNDFmQPFusKGh2DpD9UhpGZap2UgiKwA4fUFAAAAABepFDVF5uM7gyxHBQ8k0+65PJwDlIvHh7MuEwAAAQEgAOH1BQAAAAAXqRQ1RebjO4MsRwUPJNPuuTycA5SLx4cBBBYAFIXRNTfy4mVAWjTbr6nj3aAfuCMIACICAurVlmh8qAYEPtw94RbN8p1eklfBls0FXPaYyNAr8k6ZELSmumcAAACAAAAAgAIAAIAAIgIDlPYr6d8ZlSxVh3aK63aYBhrSxKJciU9H2MFitNchPQUQtKa6ZwAAAIABAACAAgAAgAA=",
+	},
+	{
+		name: "P2SH-P2WSH 2-of-2 多签输入，带有一个签名",
+		psbt: "cHNidP8BAFUCAAAAASeaIyOl37UfxF8iD6WLD8E+HjNCeSqF1+Ns1jM7XLw5AAAAAAD/////AaBa6gsAAAAAGXapFP/pwAYQl8w7Y28ssEYPpPxCfStFiKwAAAAAAAEBIJVe6gsAAAAAF6kUY0UgD2jRieGtwN8cTRbqjxTA2+uHIgIDsTQcy6doO2r08SOM1ul+cWfVafrEfx5I1HVBhENVvUZGMEMCIAQktY7/qqaU4VWepck7v9SokGQiQFXN8HC2dxRpRC0HAh9cjrD+plFtYLisszrWTt5g6Hhb+zqpS5m9+GFR25qaAQEEIgAgdx/RitRZZm3Unz1WTj28QvTIR3TjYK2haBao7UiNVoEBBUdSIQOxNBzLp2g7avTxI4zW6X5xZ9Vp+sR/HkjUdUGEQ1W9RiED3lXR4drIBeP4pYwfv5uUwC89uq/hJ/78pJlfJvggg71SriIGA7E0HMunaDtq9PEjjNbpfnFn1Wn6xH8eSNR1QYRDVb1GELSmumcAAACAAAAAgAQAAIAiBgPeVdHh2sgF4/iljB+/m5TALz26r+En/vykmV8m+CCDvRC0prpnAAAAgAAAAIAFAACAAAA=",
+	},
+	{
+		name: "输入中带有未知类型的键",
+		psbt: "cHNidP8BAD8CAAAAAf//////////////////////////////////////////AAAAAAD/////AQAAAAAAAAAAA2oBAAAAAAAACg8BAgMEBQYHCAkPAQIDBAUGBwgJCgsMDQ4PAAA=",
+	},
+}
+
+// rawPSBT 由全局、输入和输出的键值表拼出二进制 PSBT，用于构造 BIP 中列出的各种情况
+func rawPSBT(t *testing.T, maps ...[]kv) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.Write(magic)
+	for _, pairs := range maps {
+		if err := writeMap(&buf, append([]kv(nil), pairs...)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// with 返回追加了键值对的副本
+func with(pairs []kv, extra ...kv) []kv {
+	return append(append([]kv(nil), pairs...), extra...)
+}
+
+// without 返回去掉某个键类型后的副本
+func without(pairs []kv, keyType uint64) []kv {
+	var out []kv
+	for _, p := range pairs {
+		if t, _, _ := p.keyType(); t != keyType {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+var (
+	testTxid   = chainhash.Hash{1, 2, 3, 4}
+	testScript = append([]byte{0x00, 0x14}, bytes.Repeat([]byte{0xaa}, 20)...)
+	testXOnly  = bytes.Repeat([]byte{0x79}, 32)
+)
+
+// testTx 一个输入一个输出的未签名交易
+func testTx() *wire.MsgTx {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&testTxid, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(10000, testScript))
+	return tx
+}
+
+func serializeTx(t *testing.T, tx *wire.MsgTx) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// v0 和 v2 的最小字段集合
+func v0Global(t *testing.T) []kv {
+	return []kv{newKV(globalUnsignedTx, nil, serializeTx(t, testTx()))}
+}
+
+func v2Global() []kv {
+	return []kv{
+		newKV(globalTxVersion, nil, encodeUint32(2)),
+		newKV(globalInputCount, nil, encodeCompact(1)),
+		newKV(globalOutputCount, nil, encodeCompact(1)),
+		newKV(globalVersion, nil, encodeUint32(Version2)),
+	}
+}
+
+func v2Input() []kv {
+	return []kv{
+		newKV(inPreviousTxid, nil, testTxid[:]),
+		newKV(inOutputIndex, nil, encodeUint32(0)),
+	}
+}
+
+func v2Output() []kv {
+	amount := make([]byte, 8)
+	binary.LittleEndian.PutUint64(amount, 10000)
+	return []kv{
+		newKV(outAmount, nil, amount),
+		newKV(outScript, nil, testScript),
+	}
+}
+
+// taproot 输入输出的字段 (BIP-371)
+func tapInput() []kv {
+	leafHash := bytes.Repeat([]byte{0x11}, 32)
+	controlBlock := append([]byte{0xc0}, testXOnly...)
+	return []kv{
+		newKV(inWitnessUtxo, nil, encodeTxOut(wire.NewTxOut(20000, append([]byte{0x51, 0x20}, testXOnly...)))),
+		newKV(inTapKeySig, nil, bytes.Repeat([]byte{0x01}, 64)),
+		newKV(inTapScriptSig, append(append([]byte(nil), testXOnly...), leafHash...), bytes.Repeat([]byte{0x02}, 65)),
+		newKV(inTapLeafScript, controlBlock, []byte{0x20, 0xac, 0xc0}),
+		newKV(inTapBip32Derivation, testXOnly, encodeTapBip32(TapBip32Derivation{
+			LeafHashes:  [][]byte{leafHash},
+			Fingerprint: 0x73c5da0a,
+			Path:        []uint32{86 + 0x80000000, 0x80000000, 0x80000000, 0, 0},
+		})),
+		newKV(inTapInternalKey, nil, testXOnly),
+		newKV(inTapMerkleRoot, nil, leafHash),
+	}
+}
+
+func tapOutput() []kv {
+	return []kv{
+		newKV(outTapInternalKey, nil, testXOnly),
+		newKV(outTapTree, nil, []byte{0x00, 0xc0, 0x01, 0x51}),
+		newKV(outTapBip32Derivation, testXOnly, encodeTapBip32(TapBip32Derivation{
+			Fingerprint: 0x73c5da0a,
+			Path:        []uint32{86 + 0x80000000, 0x80000000, 0x80000000, 1, 0},
+		})),
+	}
+}
+
+func TestParseValid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{}
+	for _, v := range bip174Valid {
+		data, err := base64.StdEncoding.DecodeString(v.psbt)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{"BIP-174 " + v.name, data})
+	}
+
+	witnessTx := testTx()
+	tests = append(tests, []struct {
+		name string
+		data []byte
+	}{
+		{"BIP-370 最小的 v2", rawPSBT(t, v2Global(), v2Input(), v2Output())},
+		{"BIP-370 v2 带有 fallback locktime 和 tx modifiable", rawPSBT(t,
+			with(v2Global(), newKV(globalFallbackLocktime, nil, encodeUint32(800000)), newKV(globalTxModifiable, nil, []byte{0x03})),
+			v2Input(), v2Output())},
+		{"BIP-370 v2 带有 sequence 和时间锁要求", rawPSBT(t, v2Global(),
+			with(v2Input(),
+				newKV(inSequence, nil, encodeUint32(0xfffffffd)),
+				newKV(inRequiredTimeLocktime, nil, encodeUint32(500000000)),
+				newKV(inRequiredHeightLocktime, nil, encodeUint32(499999999))),
+			v2Output())},
+		{"BIP-371 v0 的 taproot 输入和输出", rawPSBT(t, v0Global(t), tapInput(), tapOutput())},
+		{"BIP-371 v2 的 taproot 输入和输出", rawPSBT(t, v2Global(), with(v2Input(), tapInput()...), with(v2Output(), tapOutput()...))},
+		{"non-witness UTXO 与引用的交易一致", rawPSBT(t,
+			[]kv{newKV(globalUnsignedTx, nil, func() []byte {
+				tx := testTx()
+				tx.TxIn[0].PreviousOutPoint.Hash = witnessTx.TxHash()
+				return serializeTx(t, tx)
+			}())},
+			[]kv{newKV(inNonWitnessUtxo, nil, serializeTx(t, witnessTx))},
+			nil)},
+	}...)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Parse(tt.data)
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			got, err := p.Serialize()
+			if err != nil {
+				t.Fatalf("序列化失败: %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Fatalf("序列化结果与原数据不一致:\n%x\n%x", got, tt.data)
+			}
+
+			encoded, err := p.Base64()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := ParseBase64(encoded); err != nil {
+				t.Fatalf("解析 base64 失败: %v", err)
+			}
+		})
+	}
+}
+
+func TestConvertVersion(t *testing.T) {
+	for _, v := range bip174Valid[:5] {
+		t.Run(v.name, func(t *testing.T) {
+			data, err := base64.StdEncoding.DecodeString(v.psbt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := Parse(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.ConvertVersion(Version2); err != nil {
+				t.Fatalf("转换为 v2 失败: %v", err)
+			}
+			v2, err := p.Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err = Parse(v2)
+			if err != nil {
+				t.Fatalf("解析 v2 失败: %v", err)
+			}
+			if err := p.ConvertVersion(Version0); err != nil {
+				t.Fatalf("转换为 v0 失败: %v", err)
+			}
+			got, err := p.Serialize()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("v0 -> v2 -> v0 与原数据不一致")
+			}
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid, err := base64.StdEncoding.DecodeString(bip174Valid[0].psbt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx := testTx()
+	signedTx.TxIn[0].SignatureScript = []byte{0x51}
+	witnessTx := testTx()
+	witnessTx.TxIn[0].Witness = wire.TxWitness{{0x01}}
+	var noWitness bytes.Buffer
+	if err := testTx().SerializeNoWitness(&noWitness); err != nil {
+		t.Fatal(err)
+	}
+
+	pubKey33 := append([]byte{0x02}, testXOnly...)
+	origin := encodeOrigin(0x73c5da0a, []uint32{0x80000000})
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		// BIP-174
+		{"网络交易而不是 PSBT", noWitness.Bytes()},
+		{"缺少输出", valid[:len(valid)-1]},
+		{"未签名交易的输入带有 scriptSig", rawPSBT(t, []kv{newKV(globalUnsignedTx, nil, serializeTx(t, signedTx))}, nil, nil)},
+		{"未签名交易按见证格式序列化", rawPSBT(t, []kv{newKV(globalUnsignedTx, nil, serializeTx(t, witnessTx))}, nil, nil)},
+		{"缺少未签名交易", rawPSBT(t, nil, nil, nil)},
+		{"重复的键", rawPSBT(t, with(v0Global(t), v0Global(t)...), nil, nil)},
+		{"全局未签名交易的键带有键数据", rawPSBT(t, []kv{newKV(globalUnsignedTx, []byte{0x00}, noWitness.Bytes())}, nil, nil)},
+		{"non-witness UTXO 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inNonWitnessUtxo, []byte{0x00}, noWitness.Bytes())}, nil)},
+		{"witness UTXO 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inWitnessUtxo, []byte{0x00}, encodeTxOut(wire.NewTxOut(1, testScript)))}, nil)},
+		{"部分签名的公钥长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inPartialSig, append(pubKey33, 0x00), []byte{0x30})}, nil)},
+		{"sighash 类型的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inSighashType, []byte{0x00}, encodeUint32(1))}, nil)},
+		{"输入 redeemScript 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inRedeemScript, []byte{0x00}, []byte{0x51})}, nil)},
+		{"输入 witnessScript 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inWitnessScript, []byte{0x00}, []byte{0x51})}, nil)},
+		{"输入 BIP-32 派生信息的公钥长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inBip32Derivation, pubKey33[:32], origin)}, nil)},
+		{"finalScriptSig 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inFinalScriptSig, []byte{0x00}, []byte{0x51})}, nil)},
+		{"finalScriptWitness 的键带有键数据", rawPSBT(t, v0Global(t), []kv{newKV(inFinalScriptWitness, []byte{0x00}, encodeWitness(wire.TxWitness{{0x01}}))}, nil)},
+		{"输出 BIP-32 派生信息的公钥长度无效", rawPSBT(t, v0Global(t), nil, []kv{newKV(outBip32Derivation, pubKey33[:32], origin)})},
+		{"输出 redeemScript 的键带有键数据", rawPSBT(t, v0Global(t), nil, []kv{newKV(outRedeemScript, []byte{0x00}, []byte{0x51})})},
+		{"输出 witnessScript 的键带有键数据", rawPSBT(t, v0Global(t), nil, []kv{newKV(outWitnessScript, []byte{0x00}, []byte{0x51})})},
+		{"non-witness UTXO 与引用的交易不一致", rawPSBT(t, v0Global(t), []kv{newKV(inNonWitnessUtxo, nil, serializeTx(t, testTx()))}, nil)},
+		{"末尾有多余数据", append(append([]byte(nil), valid...), 0x00)},
+
+		// BIP-370
+		{"v0 带有 PSBT_GLOBAL_TX_VERSION", rawPSBT(t, with(v0Global(t), newKV(globalTxVersion, nil, encodeUint32(2))), nil, nil)},
+		{"v0 带有 PSBT_GLOBAL_INPUT_COUNT", rawPSBT(t, with(v0Global(t), newKV(globalInputCount, nil, encodeCompact(1))), nil, nil)},
+		{"v0 带有 PSBT_IN_PREVIOUS_TXID", rawPSBT(t, v0Global(t), []kv{newKV(inPreviousTxid, nil, testTxid[:])}, nil)},
+		{"v0 带有 PSBT_IN_OUTPUT_INDEX", rawPSBT(t, v0Global(t), []kv{newKV(inOutputIndex, nil, encodeUint32(0))}, nil)},
+		{"v0 带有 PSBT_IN_REQUIRED_TIME_LOCKTIME", rawPSBT(t, v0Global(t), []kv{newKV(inRequiredTimeLocktime, nil, encodeUint32(500000000))}, nil)},
+		{"v0 带有 PSBT_OUT_AMOUNT", rawPSBT(t, v0Global(t), nil, []kv{v2Output()[0]})},
+		{"v0 带有 PSBT_OUT_SCRIPT", rawPSBT(t, v0Global(t), nil, []kv{v2Output()[1]})},
+		{"v2 带有 PSBT_GLOBAL_UNSIGNED_TX", rawPSBT(t, with(v2Global(), v0Global(t)...), v2Input(), v2Output())},
+		{"v2 缺少 PSBT_GLOBAL_TX_VERSION", rawPSBT(t, without(v2Global(), globalTxVersion), v2Input(), v2Output())},
+		{"v2 缺少 PSBT_GLOBAL_INPUT_COUNT", rawPSBT(t, without(v2Global(), globalInputCount), v2Input(), v2Output())},
+		{"v2 缺少 PSBT_GLOBAL_OUTPUT_COUNT", rawPSBT(t, without(v2Global(), globalOutputCount), v2Input(), v2Output())},
+		{"v2 输入缺少 PSBT_IN_PREVIOUS_TXID", rawPSBT(t, v2Global(), without(v2Input(), inPreviousTxid), v2Output())},
+		{"v2 输入缺少 PSBT_IN_OUTPUT_INDEX", rawPSBT(t, v2Global(), without(v2Input(), inOutputIndex), v2Output())},
+		{"v2 输出缺少 PSBT_OUT_AMOUNT", rawPSBT(t, v2Global(), v2Input(), without(v2Output(), outAmount))},
+		{"v2 输出缺少 PSBT_OUT_SCRIPT", rawPSBT(t, v2Global(), v2Input(), without(v2Output(), outScript))},
+		{"v2 要求的时间锁小于 500000000", rawPSBT(t, v2Global(), with(v2Input(), newKV(inRequiredTimeLocktime, nil, encodeUint32(499999999))), v2Output())},
+		{"v2 要求的高度锁不小于 500000000", rawPSBT(t, v2Global(), with(v2Input(), newKV(inRequiredHeightLocktime, nil, encodeUint32(500000000))), v2Output())},
+		{"v2 的输入数量多于实际", rawPSBT(t, with(without(v2Global(), globalInputCount), newKV(globalInputCount, nil, encodeCompact(2))), v2Input(), v2Output())},
+		{"不支持的版本 1", rawPSBT(t, with(v0Global(t), newKV(globalVersion, nil, encodeUint32(1))), nil, nil)},
+
+		// BIP-371
+		{"taproot 密钥路径签名长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapKeySig, nil, bytes.Repeat([]byte{0x01}, 66))}, nil)},
+		{"taproot 脚本签名的键长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapScriptSig, bytes.Repeat([]byte{0x01}, 63), bytes.Repeat([]byte{0x02}, 64))}, nil)},
+		{"taproot 脚本签名长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapScriptSig, bytes.Repeat([]byte{0x01}, 64), bytes.Repeat([]byte{0x02}, 57))}, nil)},
+		{"taproot 控制块长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapLeafScript, bytes.Repeat([]byte{0xc0}, 34), []byte{0x51, 0xc0})}, nil)},
+		{"taproot 输入派生信息的公钥长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapBip32Derivation, pubKey33, append([]byte{0x00}, origin...))}, nil)},
+		{"taproot 输入内部公钥长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapInternalKey, nil, testXOnly[:31])}, nil)},
+		{"taproot merkle 根长度无效", rawPSBT(t, v0Global(t), []kv{newKV(inTapMerkleRoot, nil, testXOnly[:31])}, nil)},
+		{"taproot 输出内部公钥长度无效", rawPSBT(t, v0Global(t), nil, []kv{newKV(outTapInternalKey, nil, pubKey33)})},
+		{"taproot 输出派生信息的公钥长度无效", rawPSBT(t, v0Global(t), nil, []kv{newKV(outTapBip32Derivation, pubKey33, append([]byte{0x00}, origin...))})},
+		{"taproot 派生信息的叶子哈希数量超出数据", rawPSBT(t, v0Global(t), nil, []kv{newKV(outTapBip32Derivation, testXOnly, append([]byte{0x02}, origin...))})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.data); err == nil {
+				t.Fatal("无效的 PSBT 解析成功")
+			}
+		})
+	}
+
+	if _, err := Parse(noWitness.Bytes()); !errors.Is(err, ErrInvalidMagic) {
+		t.Fatalf("网络交易应返回 ErrInvalidMagic，实际为 %v", err)
+	}
+}
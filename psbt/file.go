@@ -0,0 +1,43 @@
+package psbt
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadFile 读取 PSBT 文件，自动识别二进制和 base64 格式
+func ReadFile(path string) (*Packet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 PSBT 文件失败: %w", err)
+	}
+	if bytes.HasPrefix(data, magic) {
+		return Parse(data)
+	}
+	return ParseBase64(strings.TrimSpace(string(data)))
+}
+
+// WriteFile 写入 PSBT 文件，binary 为 false 时写入 base64 文本。
+// Sparrow 和大多数硬件钱包两种格式都能读取。
+func WriteFile(path string, p *Packet, binary bool) error {
+	var data []byte
+	if binary {
+		raw, err := p.Serialize()
+		if err != nil {
+			return err
+		}
+		data = raw
+	} else {
+		text, err := p.Base64()
+		if err != nil {
+			return err
+		}
+		data = []byte(text + "\n")
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("写入 PSBT 文件失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package psbt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrMissingSignature 签名数量不足，暂时无法完成该输入
+var ErrMissingSignature = errors.New("缺少签名")
+
+// Finalize 为所有能完成的输入生成最终的 scriptSig 和见证 (BIP-174 Input Finalizer)，
+// 返回仍未完成的输入序号。签名不足的输入保持原样，便于继续收集签名。
+func (p *Packet) Finalize() ([]int, error) {
+	var pending []int
+	for i := range p.Inputs {
+		if p.Inputs[i].IsFinalized() {
+			continue
+		}
+		err := p.Inputs[i].finalize()
+		if errors.Is(err, ErrMissingSignature) {
+			pending = append(pending, i)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("输入 %d: %w", i, err)
+		}
+	}
+	return pending, nil
+}
+
+// finalize 按前一笔输出的脚本类型组装 scriptSig 和见证，成功后清除签名过程中的字段
+func (in *Input) finalize() error {
+	prevOut, err := in.PrevOutput()
+	if err != nil {
+		return err
+	}
+	pkScript := prevOut.PkScript
+
+	var (
+		scriptSig []byte
+		witness   wire.TxWitness
+	)
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.WitnessV1TaprootTy:
+		witness, err = in.finalizeTaproot()
+	case txscript.WitnessV0PubKeyHashTy:
+		witness, err = in.finalizeWitnessKey(pkScript[2:])
+	case txscript.WitnessV0ScriptHashTy:
+		witness, err = in.finalizeWitnessScript(pkScript[2:])
+	case txscript.PubKeyHashTy:
+		scriptSig, err = in.finalizeKeyHash(pkScript[3:23])
+	case txscript.ScriptHashTy:
+		scriptSig, witness, err = in.finalizeScriptHash(pkScript[2:22])
+	default:
+		return fmt.Errorf("不支持的脚本类型 %s", txscript.GetScriptClass(pkScript))
+	}
+	if err != nil {
+		return err
+	}
+
+	*in = Input{
+		PreviousOutPoint:       in.PreviousOutPoint,
+		Sequence:               in.Sequence,
+		RequiredTimeLocktime:   in.RequiredTimeLocktime,
+		RequiredHeightLocktime: in.RequiredHeightLocktime,
+		NonWitnessUtxo:         in.NonWitnessUtxo,
+		WitnessUtxo:            in.WitnessUtxo,
+		FinalScriptSig:         scriptSig,
+		FinalScriptWitness:     witness,
+		Unknowns:               in.Unknowns,
+	}
+	return nil
+}
+
+// finalizeTaproot 优先使用密钥路径签名，否则寻找签名齐全的单签名脚本叶子
+func (in *Input) finalizeTaproot() (wire.TxWitness, error) {
+	if len(in.TapKeySig) > 0 {
+		return wire.TxWitness{in.TapKeySig}, nil
+	}
+	for _, l := range in.TapLeafScripts {
+		leafHash := txscript.NewTapLeaf(l.LeafVersion, l.Script).TapHash()
+		stack, ok := satisfyTapscript(l.Script, func(xOnly []byte) []byte {
+			for _, s := range in.TapScriptSigs {
+				if bytes.Equal(s.XOnlyPubKey, xOnly) && bytes.Equal(s.LeafHash, leafHash[:]) {
+					return s.Signature
+				}
+			}
+			return nil
+		})
+		if ok {
+			return append(stack, l.Script, l.ControlBlock), nil
+		}
+	}
+	return nil, ErrMissingSignature
+}
+
+// satisfyTapscript 为 <公钥> OP_CHECKSIG 形式的叶子生成见证栈
+func satisfyTapscript(script []byte, sigFor func(xOnly []byte) []byte) (wire.TxWitness, bool) {
+	if len(script) != 34 || script[0] != txscript.OP_DATA_32 || script[33] != txscript.OP_CHECKSIG {
+		return nil, false
+	}
+	sig := sigFor(script[1:33])
+	if sig == nil {
+		return nil, false
+	}
+	return wire.TxWitness{sig}, true
+}
+
+// partialSig 返回指定公钥的 ECDSA 签名
+func (in *Input) partialSig(pubKey []byte) []byte {
+	for _, s := range in.PartialSigs {
+		if bytes.Equal(s.PubKey, pubKey) {
+			return s.Signature
+		}
+	}
+	return nil
+}
+
+// keyHashSig 返回 hash160 与 keyHash 相同的公钥及其签名
+func (in *Input) keyHashSig(keyHash []byte) ([]byte, []byte, error) {
+	for _, s := range in.PartialSigs {
+		if bytes.Equal(btcutil.Hash160(s.PubKey), keyHash) {
+			return s.PubKey, s.Signature, nil
+		}
+	}
+	return nil, nil, ErrMissingSignature
+}
+
+// finalizeWitnessKey P2WPKH: 见证为 <签名> <公钥>
+func (in *Input) finalizeWitnessKey(keyHash []byte) (wire.TxWitness, error) {
+	pubKey, sig, err := in.keyHashSig(keyHash)
+	if err != nil {
+		return nil, err
+	}
+	return wire.TxWitness{sig, pubKey}, nil
+}
+
+// finalizeKeyHash P2PKH: scriptSig 为 <签名> <公钥>
+func (in *Input) finalizeKeyHash(keyHash []byte) ([]byte, error) {
+	pubKey, sig, err := in.keyHashSig(keyHash)
+	if err != nil {
+		return nil, err
+	}
+	return txscript.NewScriptBuilder().AddData(sig).AddData(pubKey).Script()
+}
+
+// finalizeWitnessScript P2WSH: 见证为满足见证脚本的栈加上见证脚本本身
+func (in *Input) finalizeWitnessScript(scriptHash []byte) (wire.TxWitness, error) {
+	if in.WitnessScript == nil {
+		return nil, fmt.Errorf("P2WSH 输入缺少见证脚本")
+	}
+	if hash := sha256.Sum256(in.WitnessScript); !bytes.Equal(hash[:], scriptHash) {
+		return nil, fmt.Errorf("见证脚本与锁定脚本不匹配")
+	}
+	stack, err := in.satisfyScript(in.WitnessScript)
+	if err != nil {
+		return nil, err
+	}
+	return append(stack, in.WitnessScript), nil
+}
+
+// finalizeScriptHash P2SH，赎回脚本可以是 P2WPKH、P2WSH 或普通脚本
+func (in *Input) finalizeScriptHash(scriptHash []byte) ([]byte, wire.TxWitness, error) {
+	if in.RedeemScript == nil {
+		return nil, nil, fmt.Errorf("P2SH 输入缺少赎回脚本")
+	}
+	if !bytes.Equal(btcutil.Hash160(in.RedeemScript), scriptHash) {
+		return nil, nil, fmt.Errorf("赎回脚本与锁定脚本不匹配")
+	}
+	scriptSig, err := txscript.NewScriptBuilder().AddData(in.RedeemScript).Script()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch txscript.GetScriptClass(in.RedeemScript) {
+	case txscript.WitnessV0PubKeyHashTy:
+		witness, err := in.finalizeWitnessKey(in.RedeemScript[2:])
+		return scriptSig, witness, err
+	case txscript.WitnessV0ScriptHashTy:
+		witness, err := in.finalizeWitnessScript(in.RedeemScript[2:])
+		return scriptSig, witness, err
+	}
+
+	stack, err := in.satisfyScript(in.RedeemScript)
+	if err != nil {
+		return nil, nil, err
+	}
+	builder := txscript.NewScriptBuilder()
+	for _, item := range stack {
+		if len(item) == 0 {
+			builder.AddOp(txscript.OP_0)
+		} else {
+			builder.AddData(item)
+		}
+	}
+	builder.AddData(in.RedeemScript)
+	scriptSig, err = builder.Script()
+	return scriptSig, nil, err
+}
+
+// satisfyScript 为 m-of-n 多签或 <公钥> OP_CHECKSIG 脚本生成解锁栈
+func (in *Input) satisfyScript(script []byte) (wire.TxWitness, error) {
+	if txscript.GetScriptClass(script) == txscript.MultiSigTy {
+		pushes, err := txscript.PushedData(script)
+		if err != nil {
+			return nil, err
+		}
+		_, required, err := txscript.CalcMultiSigStats(script)
+		if err != nil {
+			return nil, err
+		}
+		// CHECKMULTISIG 多弹出一个元素，签名必须按公钥顺序排列
+		stack := wire.TxWitness{nil}
+		for _, pubKey := range pushes {
+			if len(stack)-1 == required {
+				break
+			}
+			if sig := in.partialSig(pubKey); sig != nil {
+				stack = append(stack, sig)
+			}
+		}
+		if len(stack)-1 < required {
+			return nil, fmt.Errorf("%w: 需要 %d 个，只有 %d 个", ErrMissingSignature, required, len(stack)-1)
+		}
+		return stack, nil
+	}
+
+	if txscript.GetScriptClass(script) == txscript.PubKeyTy {
+		pushes, err := txscript.PushedData(script)
+		if err != nil {
+			return nil, err
+		}
+		sig := in.partialSig(pushes[0])
+		if sig == nil {
+			return nil, ErrMissingSignature
+		}
+		return wire.TxWitness{sig}, nil
+	}
+	return nil, fmt.Errorf("无法自动完成该脚本的签名")
+}
@@ -0,0 +1,340 @@
+// Package psbt 实现部分签名比特币交易 (PSBT)：BIP-174 (v0)、BIP-370 (v2) 以及
+// BIP-371 定义的 taproot 字段，用于在本钱包、Sparrow 和硬件钱包之间交换待签名交易。
+//
+// 内存中统一使用 v2 的表示方式：每个输入记录自己的前一笔输出和 nSequence，
+// 每个输出记录金额和锁定脚本；按 v0 序列化时再由它们组装出未签名交易。
+// 不认识的键值对原样保留，序列化时写回。
+package psbt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// 支持的 PSBT 版本
+const (
+	Version0 = 0
+	Version2 = 2
+)
+
+// 交易可修改标志 (PSBT_GLOBAL_TX_MODIFIABLE)
+const (
+	InputsModifiable  = 1 << 0
+	OutputsModifiable = 1 << 1
+	HasSighashSingle  = 1 << 2
+)
+
+var (
+	// ErrInvalidMagic 数据不是以 "psbt\xff" 开头
+	ErrInvalidMagic = errors.New("不是有效的 PSBT 数据")
+
+	// ErrUnsupportedVersion PSBT 版本不是 0 或 2
+	ErrUnsupportedVersion = errors.New("不支持的 PSBT 版本")
+
+	// ErrNotFinalized 仍有输入没有最终签名，无法提取交易
+	ErrNotFinalized = errors.New("PSBT 尚未完成签名")
+)
+
+// Bip32Derivation 公钥的 BIP-32 派生信息 (PSBT_IN_BIP32_DERIVATION / PSBT_OUT_BIP32_DERIVATION)
+type Bip32Derivation struct {
+	PubKey      []byte // 33 字节压缩公钥
+	Fingerprint uint32 // 主密钥指纹
+	Path        []uint32
+}
+
+// TapBip32Derivation taproot 公钥的派生信息，LeafHashes 为使用该公钥的脚本叶子，
+// 密钥路径花费时为空 (PSBT_IN_TAP_BIP32_DERIVATION / PSBT_OUT_TAP_BIP32_DERIVATION)
+type TapBip32Derivation struct {
+	XOnlyPubKey []byte
+	LeafHashes  [][]byte
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// PartialSig ECDSA 部分签名，签名末尾带 sighash 类型 (PSBT_IN_PARTIAL_SIG)
+type PartialSig struct {
+	PubKey    []byte
+	Signature []byte
+}
+
+// TapScriptSig taproot 脚本路径的 schnorr 签名 (PSBT_IN_TAP_SCRIPT_SIG)
+type TapScriptSig struct {
+	XOnlyPubKey []byte
+	LeafHash    []byte
+	Signature   []byte
+}
+
+// TapLeafScript 可用于花费的脚本叶子及其控制块 (PSBT_IN_TAP_LEAF_SCRIPT)
+type TapLeafScript struct {
+	ControlBlock []byte
+	Script       []byte
+	LeafVersion  txscript.TapscriptLeafVersion
+}
+
+// XPub 全局扩展公钥 (PSBT_GLOBAL_XPUB)
+type XPub struct {
+	ExtendedKey []byte // 78 字节序列化扩展公钥
+	Fingerprint uint32
+	Path        []uint32
+}
+
+// Unknown 不认识的键值对，key 包含键类型
+type Unknown struct {
+	Key   []byte
+	Value []byte
+}
+
+// Input 一个输入的全部字段
+type Input struct {
+	PreviousOutPoint       wire.OutPoint
+	Sequence               uint32
+	RequiredTimeLocktime   uint32 // 0 表示没有要求
+	RequiredHeightLocktime uint32 // 0 表示没有要求
+
+	NonWitnessUtxo  *wire.MsgTx
+	WitnessUtxo     *wire.TxOut
+	PartialSigs     []PartialSig
+	SighashType     txscript.SigHashType // 0 表示未指定
+	RedeemScript    []byte
+	WitnessScript   []byte
+	Bip32Derivation []Bip32Derivation
+
+	FinalScriptSig     []byte
+	FinalScriptWitness wire.TxWitness
+
+	TapKeySig          []byte
+	TapScriptSigs      []TapScriptSig
+	TapLeafScripts     []TapLeafScript
+	TapBip32Derivation []TapBip32Derivation
+	TapInternalKey     []byte
+	TapMerkleRoot      []byte
+
+	Unknowns []Unknown
+}
+
+// Output 一个输出的全部字段
+type Output struct {
+	Amount int64
+	Script []byte
+
+	RedeemScript       []byte
+	WitnessScript      []byte
+	Bip32Derivation    []Bip32Derivation
+	TapInternalKey     []byte
+	TapTree            []byte // PSBT_OUT_TAP_TREE 原始数据
+	TapBip32Derivation []TapBip32Derivation
+
+	Unknowns []Unknown
+}
+
+// Packet 一个 PSBT
+type Packet struct {
+	Version          uint32
+	TxVersion        int32
+	FallbackLocktime uint32
+	TxModifiable     uint8 // 只用于 v2
+
+	XPubs    []XPub
+	Inputs   []Input
+	Outputs  []Output
+	Unknowns []Unknown
+}
+
+// New 由未签名交易创建 PSBT，交易中已有的 scriptSig 和见证会被忽略
+func New(tx *wire.MsgTx, version uint32) (*Packet, error) {
+	if version != Version0 && version != Version2 {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+
+	p := &Packet{
+		Version:          version,
+		TxVersion:        tx.Version,
+		FallbackLocktime: tx.LockTime,
+		Inputs:           make([]Input, len(tx.TxIn)),
+		Outputs:          make([]Output, len(tx.TxOut)),
+	}
+	for i, in := range tx.TxIn {
+		p.Inputs[i] = Input{
+			PreviousOutPoint: in.PreviousOutPoint,
+			Sequence:         in.Sequence,
+		}
+	}
+	for i, out := range tx.TxOut {
+		p.Outputs[i] = Output{
+			Amount: out.Value,
+			Script: append([]byte(nil), out.PkScript...),
+		}
+	}
+	return p, nil
+}
+
+// ConvertVersion 转换为指定版本。v0 无法表示输入的锁定时间要求，存在时返回错误。
+func (p *Packet) ConvertVersion(version uint32) error {
+	switch version {
+	case Version0:
+		for i, in := range p.Inputs {
+			if in.RequiredTimeLocktime != 0 || in.RequiredHeightLocktime != 0 {
+				return fmt.Errorf("输入 %d 带有锁定时间要求，无法转换为 PSBT v0", i)
+			}
+		}
+		if _, err := p.LockTime(); err != nil {
+			return err
+		}
+		p.TxModifiable = 0
+	case Version2:
+	default:
+		return fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	p.Version = version
+	return nil
+}
+
+// LockTime 按 BIP-370 计算交易的 nLockTime：没有输入要求锁定时间时使用 FallbackLocktime；
+// 否则所有有要求的输入都支持区块高度时取最大高度，都支持时间戳时取最大时间戳，优先使用高度。
+func (p *Packet) LockTime() (uint32, error) {
+	var (
+		any                bool
+		heightOK, timeOK   = true, true
+		maxHeight, maxTime uint32
+	)
+	for _, in := range p.Inputs {
+		if in.RequiredHeightLocktime == 0 && in.RequiredTimeLocktime == 0 {
+			continue
+		}
+		any = true
+		if in.RequiredHeightLocktime == 0 {
+			heightOK = false
+		} else if in.RequiredHeightLocktime > maxHeight {
+			maxHeight = in.RequiredHeightLocktime
+		}
+		if in.RequiredTimeLocktime == 0 {
+			timeOK = false
+		} else if in.RequiredTimeLocktime > maxTime {
+			maxTime = in.RequiredTimeLocktime
+		}
+	}
+
+	switch {
+	case !any:
+		return p.FallbackLocktime, nil
+	case heightOK:
+		return maxHeight, nil
+	case timeOK:
+		return maxTime, nil
+	default:
+		return 0, fmt.Errorf("输入的锁定时间要求互相冲突：有的要求区块高度，有的要求时间戳")
+	}
+}
+
+// UnsignedTx 返回不含签名的交易
+func (p *Packet) UnsignedTx() (*wire.MsgTx, error) {
+	lockTime, err := p.LockTime()
+	if err != nil {
+		return nil, err
+	}
+
+	tx := wire.NewMsgTx(p.TxVersion)
+	tx.LockTime = lockTime
+	for _, in := range p.Inputs {
+		txIn := wire.NewTxIn(&in.PreviousOutPoint, nil, nil)
+		txIn.Sequence = in.Sequence
+		tx.AddTxIn(txIn)
+	}
+	for _, out := range p.Outputs {
+		tx.AddTxOut(wire.NewTxOut(out.Amount, out.Script))
+	}
+	return tx, nil
+}
+
+// PrevOutput 返回输入花费的输出，优先使用 WitnessUtxo
+func (in *Input) PrevOutput() (*wire.TxOut, error) {
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, nil
+	}
+	if in.NonWitnessUtxo != nil {
+		index := in.PreviousOutPoint.Index
+		if int(index) >= len(in.NonWitnessUtxo.TxOut) {
+			return nil, fmt.Errorf("前一笔交易没有输出 %d", index)
+		}
+		return in.NonWitnessUtxo.TxOut[index], nil
+	}
+	return nil, fmt.Errorf("输入 %s 缺少前一笔输出信息", in.PreviousOutPoint)
+}
+
+// PrevOutFetcher 返回所有输入花费的输出，taproot 签名需要全部输入的金额和脚本
+func (p *Packet) PrevOutFetcher() (*txscript.MultiPrevOutFetcher, error) {
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i := range p.Inputs {
+		prevOut, err := p.Inputs[i].PrevOutput()
+		if err != nil {
+			return nil, fmt.Errorf("输入 %d: %w", i, err)
+		}
+		fetcher.AddPrevOut(p.Inputs[i].PreviousOutPoint, prevOut)
+	}
+	return fetcher, nil
+}
+
+// IsFinalized 输入是否已有最终的 scriptSig 或见证
+func (in *Input) IsFinalized() bool {
+	return len(in.FinalScriptSig) > 0 || len(in.FinalScriptWitness) > 0
+}
+
+// IsComplete 所有输入是否都已完成签名
+func (p *Packet) IsComplete() bool {
+	for i := range p.Inputs {
+		if !p.Inputs[i].IsFinalized() {
+			return false
+		}
+	}
+	return true
+}
+
+// Fee 返回手续费，需要所有输入的前一笔输出信息
+func (p *Packet) Fee() (int64, error) {
+	var totalIn, totalOut int64
+	for i := range p.Inputs {
+		prevOut, err := p.Inputs[i].PrevOutput()
+		if err != nil {
+			return 0, fmt.Errorf("输入 %d: %w", i, err)
+		}
+		totalIn += prevOut.Value
+	}
+	for _, out := range p.Outputs {
+		totalOut += out.Amount
+	}
+	return totalIn - totalOut, nil
+}
+
+// Extract 从已完成的 PSBT 中提取可广播的交易
+func (p *Packet) Extract() (*wire.MsgTx, error) {
+	tx, err := p.UnsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	for i, in := range p.Inputs {
+		if !in.IsFinalized() {
+			return nil, fmt.Errorf("%w: 输入 %d", ErrNotFinalized, i)
+		}
+		tx.TxIn[i].SignatureScript = in.FinalScriptSig
+		tx.TxIn[i].Witness = in.FinalScriptWitness
+	}
+	return tx, nil
+}
+
+// checkUtxo 确认 NonWitnessUtxo 正是输入引用的交易
+func (in *Input) checkUtxo() error {
+	if in.NonWitnessUtxo == nil {
+		return nil
+	}
+	if hash := in.NonWitnessUtxo.TxHash(); !bytes.Equal(hash[:], in.PreviousOutPoint.Hash[:]) {
+		return fmt.Errorf("non-witness UTXO 的交易 ID %s 与输入引用的 %s 不一致", hash, in.PreviousOutPoint.Hash)
+	}
+	if int(in.PreviousOutPoint.Index) >= len(in.NonWitnessUtxo.TxOut) {
+		return fmt.Errorf("non-witness UTXO 没有输出 %d", in.PreviousOutPoint.Index)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package psbt
+
+import (
+	"bytes"
+	"fmt"
+
+	"go-btc/hdwallet"
+	"go-btc/signer"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Signer 为 PSBT 中属于本钱包的输入签名。
+// 按输入的 BIP-32 派生信息找到主密钥指纹相同的公钥，派生私钥后签名，
+// 签名写入 PSBT，最终的 scriptSig 和见证由 Finalize 生成。
+// 输入指定的 sighash 类型先经过 signer.CheckSigHashType 检查。
+type Signer struct {
+	deriver *hdwallet.Deriver
+
+	// AllowSigHashNone 允许按 PSBT 的要求使用 SIGHASH_NONE 签名
+	AllowSigHashNone bool
+}
+
+// NewSigner 创建签名器
+func NewSigner(deriver *hdwallet.Deriver) *Signer {
+	return &Signer{deriver: deriver}
+}
+
+// signContext 签名一个输入所需的交易数据
+type signContext struct {
+	tx        *wire.MsgTx
+	fetcher   *txscript.MultiPrevOutFetcher
+	sigHashes *txscript.TxSigHashes
+}
+
+// Sign 为所有能签的输入签名，返回签过名的输入序号。已完成的输入会被跳过。
+func (s *Signer) Sign(p *Packet) ([]int, error) {
+	tx, err := p.UnsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	fetcher, err := p.PrevOutFetcher()
+	if err != nil {
+		return nil, fmt.Errorf("签名需要所有输入的前一笔输出: %w", err)
+	}
+	ctx := &signContext{
+		tx:        tx,
+		fetcher:   fetcher,
+		sigHashes: txscript.NewTxSigHashes(tx, fetcher),
+	}
+
+	var signed []int
+	for i := range p.Inputs {
+		in := &p.Inputs[i]
+		if in.IsFinalized() {
+			continue
+		}
+		var ok bool
+		if txscript.IsPayToTaproot(ctx.fetcher.FetchPrevOutput(in.PreviousOutPoint).PkScript) {
+			ok, err = s.signTaproot(ctx, i, in)
+		} else {
+			ok, err = s.signECDSA(ctx, i, in)
+		}
+		if err != nil {
+			return signed, fmt.Errorf("输入 %d 签名失败: %w", i, err)
+		}
+		if ok {
+			signed = append(signed, i)
+		}
+	}
+	return signed, nil
+}
+
+// deriveKey 派生 PSBT 派生信息指向的私钥，指纹不是本钱包的返回 nil
+func (s *Signer) deriveKey(fingerprint uint32, indexes []uint32) (*hdwallet.Key, error) {
+	if fingerprint != s.deriver.MasterFingerprint() {
+		return nil, nil
+	}
+	path, err := hdwallet.PathFromIndexes(indexes)
+	if err != nil {
+		return nil, err
+	}
+	return s.deriver.Derive(path)
+}
+
+// signTaproot 按 TapBip32Derivation 为 taproot 输入签名：
+// 没有叶子哈希的公钥是内部公钥，生成密钥路径签名；其余公钥为所列的每个脚本叶子生成签名。
+func (s *Signer) signTaproot(ctx *signContext, index int, in *Input) (bool, error) {
+	prevOut := ctx.fetcher.FetchPrevOutput(in.PreviousOutPoint)
+	hashType := in.SighashType
+
+	var signed bool
+	for _, d := range in.TapBip32Derivation {
+		key, err := s.deriveKey(d.Fingerprint, d.Path)
+		if err != nil {
+			return signed, err
+		}
+		if key == nil {
+			continue
+		}
+		// 只检查本钱包要签名的输入，其他签名方的输入不受影响
+		if err := signer.CheckSigHashType(ctx.tx, index, hashType, true, s.AllowSigHashNone); err != nil {
+			return signed, err
+		}
+		if !bytes.Equal(schnorr.SerializePubKey(key.PubKey), d.XOnlyPubKey) {
+			return signed, fmt.Errorf("路径 %s 派生出的公钥与 PSBT 中的不一致", key.Path)
+		}
+
+		if len(d.LeafHashes) == 0 {
+			if err := checkTaprootOutputKey(in, key.PubKey, prevOut.PkScript); err != nil {
+				return signed, err
+			}
+			sig, err := txscript.RawTxInTaprootSignature(ctx.tx, ctx.sigHashes, index,
+				prevOut.Value, prevOut.PkScript, in.TapMerkleRoot, hashType, key.PrivKey)
+			if err != nil {
+				return signed, err
+			}
+			in.TapKeySig = sig
+			signed = true
+			continue
+		}
+
+		for _, leafHash := range d.LeafHashes {
+			leaf, ok := in.findLeaf(leafHash)
+			if !ok {
+				continue
+			}
+			sig, err := txscript.RawTxInTapscriptSignature(ctx.tx, ctx.sigHashes, index,
+				prevOut.Value, prevOut.PkScript, leaf, hashType, key.PrivKey)
+			if err != nil {
+				return signed, err
+			}
+			in.setTapScriptSig(TapScriptSig{
+				XOnlyPubKey: d.XOnlyPubKey,
+				LeafHash:    leafHash,
+				Signature:   sig,
+			})
+			signed = true
+		}
+	}
+	return signed, nil
+}
+
+// signECDSA 按 Bip32Derivation 为 SegWit v0 和旧式输入生成 ECDSA 部分签名。
+// 签名脚本取自见证脚本、赎回脚本或锁定脚本本身；旧式输入的签名不承诺金额，
+// 因此要求提供完整的前一笔交易 (NonWitnessUtxo)。
+func (s *Signer) signECDSA(ctx *signContext, index int, in *Input) (bool, error) {
+	prevOut := ctx.fetcher.FetchPrevOutput(in.PreviousOutPoint)
+
+	hashType := in.SighashType
+	if hashType == 0 {
+		hashType = txscript.SigHashAll
+	}
+
+	pkScript := prevOut.PkScript
+	if txscript.IsPayToScriptHash(pkScript) {
+		if in.RedeemScript == nil {
+			return false, fmt.Errorf("P2SH 输入缺少赎回脚本")
+		}
+		pkScript = in.RedeemScript
+	}
+	segwit := txscript.IsPayToWitnessPubKeyHash(pkScript) || txscript.IsPayToWitnessScriptHash(pkScript)
+	subScript := pkScript
+	if txscript.IsPayToWitnessScriptHash(pkScript) {
+		if in.WitnessScript == nil {
+			return false, fmt.Errorf("P2WSH 输入缺少见证脚本")
+		}
+		subScript = in.WitnessScript
+	}
+
+	var signed bool
+	for _, d := range in.Bip32Derivation {
+		key, err := s.deriveKey(d.Fingerprint, d.Path)
+		if err != nil {
+			return signed, err
+		}
+		if key == nil {
+			continue
+		}
+		if err := signer.CheckSigHashType(ctx.tx, index, hashType, false, s.AllowSigHashNone); err != nil {
+			return signed, err
+		}
+		if !bytes.Equal(key.PubKey.SerializeCompressed(), d.PubKey) {
+			return signed, fmt.Errorf("路径 %s 派生出的公钥与 PSBT 中的不一致", key.Path)
+		}
+
+		// 同时带有完整的前一笔交易时，核对 WitnessUtxo 的金额和脚本，防止被虚报金额骗取手续费
+		if in.NonWitnessUtxo != nil {
+			if err := in.checkUtxo(); err != nil {
+				return signed, err
+			}
+			utxo := in.NonWitnessUtxo.TxOut[in.PreviousOutPoint.Index]
+			if utxo.Value != prevOut.Value || !bytes.Equal(utxo.PkScript, prevOut.PkScript) {
+				return signed, fmt.Errorf("witness UTXO 与前一笔交易中的输出 %s 不一致", in.PreviousOutPoint)
+			}
+		}
+
+		var sig []byte
+		if segwit {
+			sig, err = txscript.RawTxInWitnessSignature(ctx.tx, ctx.sigHashes, index,
+				prevOut.Value, subScript, hashType, key.PrivKey)
+		} else {
+			if in.NonWitnessUtxo == nil {
+				return signed, fmt.Errorf("非 SegWit 输入缺少前一笔完整交易")
+			}
+			sig, err = txscript.RawTxInSignature(ctx.tx, index, subScript, hashType, key.PrivKey)
+		}
+		if err != nil {
+			return signed, err
+		}
+		in.setPartialSig(PartialSig{PubKey: d.PubKey, Signature: sig})
+		signed = true
+	}
+	return signed, nil
+}
+
+// setPartialSig 添加或替换同一公钥的部分签名
+func (in *Input) setPartialSig(sig PartialSig) {
+	for i, s := range in.PartialSigs {
+		if bytes.Equal(s.PubKey, sig.PubKey) {
+			in.PartialSigs[i] = sig
+			return
+		}
+	}
+	in.PartialSigs = append(in.PartialSigs, sig)
+}
+
+// checkTaprootOutputKey 确认内部公钥加上 merkle 根调整后正是前一笔输出的公钥，
+// 防止被诱导用错误的密钥签名
+func checkTaprootOutputKey(in *Input, internalKey *btcec.PublicKey, pkScript []byte) error {
+	if in.TapInternalKey != nil && !bytes.Equal(in.TapInternalKey, schnorr.SerializePubKey(internalKey)) {
+		return fmt.Errorf("taproot 内部公钥与派生信息不一致")
+	}
+	outputKey := txscript.ComputeTaprootOutputKey(internalKey, in.TapMerkleRoot)
+	if !bytes.Equal(pkScript[2:], schnorr.SerializePubKey(outputKey)) {
+		return fmt.Errorf("taproot 输出公钥与内部公钥不匹配")
+	}
+	return nil
+}
+
+// findLeaf 按叶子哈希查找输入中的脚本叶子
+func (in *Input) findLeaf(leafHash []byte) (txscript.TapLeaf, bool) {
+	for _, l := range in.TapLeafScripts {
+		leaf := txscript.NewTapLeaf(l.LeafVersion, l.Script)
+		if hash := leaf.TapHash(); bytes.Equal(hash[:], leafHash) {
+			return leaf, true
+		}
+	}
+	return txscript.TapLeaf{}, false
+}
+
+// setTapScriptSig 添加或替换同一公钥在同一叶子上的签名
+func (in *Input) setTapScriptSig(sig TapScriptSig) {
+	for i, s := range in.TapScriptSigs {
+		if bytes.Equal(s.XOnlyPubKey, sig.XOnlyPubKey) && bytes.Equal(s.LeafHash, sig.LeafHash) {
+			in.TapScriptSigs[i] = sig
+			return
+		}
+	}
+	in.TapScriptSigs = append(in.TapScriptSigs, sig)
+}
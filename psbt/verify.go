@@ -0,0 +1,82 @@
+package psbt
+
+import (
+	"bytes"
+	"fmt"
+
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// OwnedOutput 经签名方验证属于本钱包的输出
+type OwnedOutput struct {
+	Index int
+	Path  hdwallet.Path
+}
+
+// CheckOutputs 验证带有本钱包派生信息的输出（通常是找零）：按派生路径重新派生公钥，
+// 确认公钥与 PSBT 中的一致、锁定脚本正是该公钥在这个路径上的地址。
+// 创建 PSBT 的联网机器可能被攻破，它可以把收款地址伪装成找零，让签名方误以为钱还在自己手里，
+// 所以派生信息指向本钱包但对不上的输出直接返回错误。没有本钱包派生信息的输出视为付给别人。
+// 脚本叶子中的公钥 (LeafHashes 非空) 不代表输出属于本钱包，不做验证。
+func (s *Signer) CheckOutputs(p *Packet) ([]OwnedOutput, error) {
+	var owned []OwnedOutput
+	for i := range p.Outputs {
+		out := &p.Outputs[i]
+
+		var path *hdwallet.Path
+		for _, d := range out.TapBip32Derivation {
+			if len(d.LeafHashes) > 0 {
+				continue
+			}
+			key, err := s.deriveKey(d.Fingerprint, d.Path)
+			if err != nil {
+				return nil, fmt.Errorf("输出 %d: %w", i, err)
+			}
+			if key == nil {
+				continue
+			}
+			if !bytes.Equal(schnorr.SerializePubKey(key.PubKey), d.XOnlyPubKey) {
+				return nil, fmt.Errorf("输出 %d: 路径 %s 派生出的公钥与 PSBT 中的不一致", i, key.Path)
+			}
+			if out.TapInternalKey != nil && !bytes.Equal(out.TapInternalKey, d.XOnlyPubKey) {
+				return nil, fmt.Errorf("输出 %d: taproot 内部公钥与派生信息不一致", i)
+			}
+			if err := checkOutputScript(i, out, key); err != nil {
+				return nil, err
+			}
+			path = &key.Path
+		}
+
+		for _, d := range out.Bip32Derivation {
+			key, err := s.deriveKey(d.Fingerprint, d.Path)
+			if err != nil {
+				return nil, fmt.Errorf("输出 %d: %w", i, err)
+			}
+			if key == nil {
+				continue
+			}
+			if !bytes.Equal(key.PubKey.SerializeCompressed(), d.PubKey) {
+				return nil, fmt.Errorf("输出 %d: 路径 %s 派生出的公钥与 PSBT 中的不一致", i, key.Path)
+			}
+			if err := checkOutputScript(i, out, key); err != nil {
+				return nil, err
+			}
+			path = &key.Path
+		}
+
+		if path != nil {
+			owned = append(owned, OwnedOutput{Index: i, Path: *path})
+		}
+	}
+	return owned, nil
+}
+
+// checkOutputScript 确认输出的锁定脚本与派生出的地址一致
+func checkOutputScript(index int, out *Output, key *hdwallet.Key) error {
+	if !bytes.Equal(out.Script, key.PkScript) {
+		return fmt.Errorf("输出 %d 声称是本钱包 %s 的地址 %s，但锁定脚本不一致", index, key.Path, key.Address)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// ErrSigHashNone 使用 SIGHASH_NONE 签名但没有显式允许。这种签名不承诺任何输出，
+// 拿到交易的人可以把输入的钱全部转到自己的地址。
+var ErrSigHashNone = errors.New("SIGHASH_NONE 签名不承诺任何输出，任何人都可以改写收款地址")
+
+// sigHashNames 支持的 sighash 类型
+var sigHashNames = map[txscript.SigHashType]string{
+	txscript.SigHashDefault:                               "DEFAULT",
+	txscript.SigHashAll:                                   "ALL",
+	txscript.SigHashNone:                                  "NONE",
+	txscript.SigHashSingle:                                "SINGLE",
+	txscript.SigHashAll | txscript.SigHashAnyOneCanPay:    "ALL|ANYONECANPAY",
+	txscript.SigHashNone | txscript.SigHashAnyOneCanPay:   "NONE|ANYONECANPAY",
+	txscript.SigHashSingle | txscript.SigHashAnyOneCanPay: "SINGLE|ANYONECANPAY",
+}
+
+// ParseSigHashType 解析 sighash 类型名称，不区分大小写，例如 all、single|anyonecanpay。
+// 空字符串和 default 返回 SIGHASH_DEFAULT，签名时 taproot 输入使用 DEFAULT，其它输入使用 ALL。
+func ParseSigHashType(s string) (txscript.SigHashType, error) {
+	name := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(s), " ", ""))
+	if name == "" {
+		return txscript.SigHashDefault, nil
+	}
+	for hashType, n := range sigHashNames {
+		if n == name {
+			return hashType, nil
+		}
+	}
+	return 0, fmt.Errorf("未知的 sighash 类型 %q，可选 default、all、none、single 以及 all|anyonecanpay、none|anyonecanpay、single|anyonecanpay", s)
+}
+
+// SigHashName 返回 sighash 类型的名称
+func SigHashName(hashType txscript.SigHashType) string {
+	if name, ok := sigHashNames[hashType]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", uint32(hashType))
+}
+
+// CheckSigHashType 检查输入 index 能否使用该 sighash 类型签名：
+// DEFAULT 只能用于 taproot；SINGLE 必须有同序号的输出，否则 SegWit v0 和旧式签名不承诺任何输出
+// （旧式输入甚至会对常数 1 签名），taproot 则无法签名；NONE 需要 allowNone。
+func CheckSigHashType(tx *wire.MsgTx, index int, hashType txscript.SigHashType, taproot, allowNone bool) error {
+	if _, ok := sigHashNames[hashType]; !ok {
+		return fmt.Errorf("无效的 sighash 类型 %s", SigHashName(hashType))
+	}
+	if hashType == txscript.SigHashDefault && !taproot {
+		return fmt.Errorf("SIGHASH_DEFAULT 只能用于 taproot 输入")
+	}
+	switch hashType &^ txscript.SigHashAnyOneCanPay {
+	case txscript.SigHashSingle:
+		if index >= len(tx.TxOut) {
+			return fmt.Errorf("输入 %d 使用 SIGHASH_SINGLE，但交易只有 %d 个输出，没有对应的输出", index, len(tx.TxOut))
+		}
+	case txscript.SigHashNone:
+		if !allowNone {
+			return ErrSigHashNone
+		}
+	}
+	return nil
+}
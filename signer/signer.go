@@ -0,0 +1,139 @@
+// Package signer 为本钱包派生的各类地址上的输入签名。
+//
+// 每个输入按前一笔输出的锁定脚本类型生成对应的解锁数据：
+// P2PKH (BIP-44) 写入 scriptSig，P2SH-P2WPKH (BIP-49) 写入赎回脚本和见证，
+// P2WPKH (BIP-84) 和 P2TR 密钥路径 (BIP-86) 只写入见证。
+// 因此一笔交易可以同时花费旧地址和 SegWit 地址上的币，把它们归集到 taproot 地址。
+//
+// 默认 taproot 使用 SIGHASH_DEFAULT，其余使用 SIGHASH_ALL；SignWithOptions 可以选择
+// NONE、SINGLE 和 ANYONECANPAY 组合，用于众筹（ALL|ANYONECANPAY，任何人都可以追加输入）
+// 或部分签名的报价（SINGLE|ANYONECANPAY，只承诺与输入同序号的输出）。
+package signer
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Keys 按锁定脚本查找持有该输出的私钥
+type Keys interface {
+	KeyByScript(pkScript []byte) (*hdwallet.Key, bool)
+}
+
+// KeyMap 以十六进制锁定脚本为键的私钥表
+type KeyMap map[string]*hdwallet.Key
+
+// Add 添加私钥
+func (m KeyMap) Add(key *hdwallet.Key) {
+	m[hex.EncodeToString(key.PkScript)] = key
+}
+
+// KeyByScript 实现 Keys
+func (m KeyMap) KeyByScript(pkScript []byte) (*hdwallet.Key, bool) {
+	key, ok := m[hex.EncodeToString(pkScript)]
+	return key, ok
+}
+
+// Options 签名选项
+type Options struct {
+	// HashType 所有输入使用的 sighash 类型，为 SIGHASH_DEFAULT 时 taproot 使用 DEFAULT，其余使用 ALL
+	HashType txscript.SigHashType
+	// AllowNone 允许使用 SIGHASH_NONE
+	AllowNone bool
+}
+
+// Sign 用默认的 sighash 类型为交易的所有输入签名，fetcher 需要包含所有输入花费的输出
+func Sign(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher, keys Keys) error {
+	return SignWithOptions(tx, fetcher, keys, Options{})
+}
+
+// SignWithOptions 按 opts 为交易的所有输入签名，签名前用 CheckSigHashType 检查每个输入
+func SignWithOptions(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher, keys Keys, opts Options) error {
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		if prevOut == nil {
+			return fmt.Errorf("无法获取输入 %d 的前一笔输出 %s", i, txIn.PreviousOutPoint)
+		}
+		key, ok := keys.KeyByScript(prevOut.PkScript)
+		if !ok {
+			return fmt.Errorf("找不到输入 %d (%s) 的私钥", i, txIn.PreviousOutPoint)
+		}
+		hashType := opts.HashType
+		taproot := txscript.IsPayToTaproot(prevOut.PkScript)
+		if hashType == txscript.SigHashDefault && !taproot {
+			hashType = txscript.SigHashAll
+		}
+		if err := CheckSigHashType(tx, i, hashType, taproot, opts.AllowNone); err != nil {
+			return fmt.Errorf("输入 %d: %w", i, err)
+		}
+		if err := SignInput(tx, i, sigHashes, prevOut, key, hashType); err != nil {
+			return fmt.Errorf("输入 %d 签名失败: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SignInput 按前一笔输出的脚本类型用 hashType 为单个输入签名，写入 scriptSig 和见证。
+// 不检查 hashType 是否安全，调用方应先使用 CheckSigHashType。
+func SignInput(tx *wire.MsgTx, index int, sigHashes *txscript.TxSigHashes, prevOut *wire.TxOut,
+	key *hdwallet.Key, hashType txscript.SigHashType) error {
+	if key.PrivKey == nil {
+		return fmt.Errorf("%s 没有私钥", key.Path)
+	}
+	txIn := tx.TxIn[index]
+
+	switch class := txscript.GetScriptClass(prevOut.PkScript); class {
+	case txscript.WitnessV1TaprootTy:
+		witness, err := txscript.TaprootWitnessSignature(tx, sigHashes, index,
+			prevOut.Value, prevOut.PkScript, hashType, key.PrivKey)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = nil
+		txIn.Witness = witness
+
+	case txscript.WitnessV0PubKeyHashTy:
+		witness, err := txscript.WitnessSignature(tx, sigHashes, index,
+			prevOut.Value, prevOut.PkScript, hashType, key.PrivKey, true)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = nil
+		txIn.Witness = witness
+
+	case txscript.ScriptHashTy:
+		// 本钱包的 P2SH 地址只有 BIP-49 的 P2SH-P2WPKH
+		if key.RedeemScript == nil {
+			return fmt.Errorf("%s 不是 P2SH-P2WPKH 地址", key.Path)
+		}
+		witness, err := txscript.WitnessSignature(tx, sigHashes, index,
+			prevOut.Value, key.RedeemScript, hashType, key.PrivKey, true)
+		if err != nil {
+			return err
+		}
+		scriptSig, err := txscript.NewScriptBuilder().AddData(key.RedeemScript).Script()
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = scriptSig
+		txIn.Witness = witness
+
+	case txscript.PubKeyHashTy:
+		scriptSig, err := txscript.SignatureScript(tx, index, prevOut.PkScript, hashType, key.PrivKey, true)
+		if err != nil {
+			return err
+		}
+		txIn.SignatureScript = scriptSig
+		txIn.Witness = nil
+
+	default:
+		return fmt.Errorf("不支持的锁定脚本类型 %s", class)
+	}
+	return nil
+}
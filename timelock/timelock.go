@@ -0,0 +1,276 @@
+// Package timelock 构造和花费带时间锁的输出，并为普通交易设置防手续费狙击的 nLockTime。
+//
+// 绝对时间锁 (after) 使用 OP_CHECKLOCKTIMEVERIFY (BIP-65)，到达指定区块高度或时间后才能花费；
+// 相对时间锁 (older) 使用 OP_CHECKSEQUENCEVERIFY (BIP-112)，输出确认若干区块后才能花费。
+// 两种锁都可以放在 P2WSH 见证脚本或 taproot 脚本叶子中：
+//
+//	<n> OP_CHECKLOCKTIMEVERIFY OP_DROP <pubkey> OP_CHECKSIG
+//	<n> OP_CHECKSEQUENCEVERIFY OP_DROP <pubkey> OP_CHECKSIG
+//
+// taproot 输出的内部公钥是 BIP-341 建议的无人知道私钥的点 (NUMS)，只能通过脚本路径花费，
+// 否则密钥路径可以绕过时间锁。
+package timelock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+
+	"go-btc/txsize"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Kind 时间锁类型，名称与 miniscript 一致
+type Kind string
+
+const (
+	After Kind = "after" // 绝对时间锁 (CLTV)
+	Older Kind = "older" // 相对时间锁 (CSV)
+)
+
+// ScriptType 时间锁输出的脚本类型
+type ScriptType string
+
+const (
+	P2WSH ScriptType = "p2wsh"
+	P2TR  ScriptType = "p2tr"
+)
+
+// LockTimeThreshold nLockTime 小于该值时表示区块高度，否则表示 unix 时间
+const LockTimeThreshold uint32 = txscript.LockTimeThreshold
+
+// numsKeyHex BIP-341 中的 H 点，由 secp256k1 生成元的哈希得到，没有人知道它的私钥
+const numsKeyHex = "50929b74c1a04954b78b4b6035e97a5e078a5a0f28ec96d547bfee9ace803ac0"
+
+// NUMSKey 返回不可花费的 taproot 内部公钥
+func NUMSKey() *btcec.PublicKey {
+	raw, _ := hex.DecodeString(numsKeyHex)
+	key, err := schnorr.ParsePubKey(raw)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// Lock 一个时间锁。After 的 Value 为区块高度或 unix 时间，Older 的 Value 为 BIP-68 编码的 nSequence。
+type Lock struct {
+	Kind  Kind
+	Value uint32
+}
+
+// AfterHeight 到达区块高度 height 后可以花费
+func AfterHeight(height uint32) (Lock, error) {
+	if height == 0 || height >= LockTimeThreshold {
+		return Lock{}, fmt.Errorf("区块高度 %d 超出范围 1-%d", height, LockTimeThreshold-1)
+	}
+	return Lock{Kind: After, Value: height}, nil
+}
+
+// AfterTime 到达 unix 时间 t 后可以花费（按过去 11 个区块的中位时间判断）
+func AfterTime(t uint32) (Lock, error) {
+	if t < LockTimeThreshold {
+		return Lock{}, fmt.Errorf("时间 %d 早于 %d，会被当作区块高度", t, LockTimeThreshold)
+	}
+	return Lock{Kind: After, Value: t}, nil
+}
+
+// OlderBlocks 输出确认 blocks 个区块后可以花费
+func OlderBlocks(blocks uint32) (Lock, error) {
+	if blocks == 0 || blocks > wire.SequenceLockTimeMask {
+		return Lock{}, fmt.Errorf("相对区块数 %d 超出范围 1-%d", blocks, wire.SequenceLockTimeMask)
+	}
+	return Lock{Kind: Older, Value: blocks}, nil
+}
+
+// OlderSeconds 输出确认 seconds 秒后可以花费，按 512 秒的粒度向上取整
+func OlderSeconds(seconds uint32) (Lock, error) {
+	units := (seconds + 1<<wire.SequenceLockTimeGranularity - 1) >> wire.SequenceLockTimeGranularity
+	if units == 0 || units > wire.SequenceLockTimeMask {
+		return Lock{}, fmt.Errorf("相对时间 %d 秒超出范围", seconds)
+	}
+	return Lock{Kind: Older, Value: wire.SequenceLockTimeIsSeconds | units}, nil
+}
+
+// IsHeight 时间锁是否按区块计算
+func (l Lock) IsHeight() bool {
+	if l.Kind == After {
+		return l.Value < LockTimeThreshold
+	}
+	return l.Value&wire.SequenceLockTimeIsSeconds == 0
+}
+
+func (l Lock) String() string {
+	switch {
+	case l.Kind == After && l.IsHeight():
+		return fmt.Sprintf("区块高度 %d 之后", l.Value)
+	case l.Kind == After:
+		return fmt.Sprintf("unix 时间 %d 之后", l.Value)
+	case l.IsHeight():
+		return fmt.Sprintf("确认 %d 个区块之后", l.Value&wire.SequenceLockTimeMask)
+	default:
+		return fmt.Sprintf("确认 %d 秒之后", (l.Value&wire.SequenceLockTimeMask)<<wire.SequenceLockTimeGranularity)
+	}
+}
+
+// Script 返回带时间锁的脚本，P2WSH 使用压缩公钥，taproot 使用 x-only 公钥
+func (l Lock) Script(scriptType ScriptType, pubKey *btcec.PublicKey) ([]byte, error) {
+	var op byte
+	switch l.Kind {
+	case After:
+		op = txscript.OP_CHECKLOCKTIMEVERIFY
+	case Older:
+		op = txscript.OP_CHECKSEQUENCEVERIFY
+	default:
+		return nil, fmt.Errorf("未知的时间锁类型 %q", l.Kind)
+	}
+
+	var key []byte
+	switch scriptType {
+	case P2WSH:
+		key = pubKey.SerializeCompressed()
+	case P2TR:
+		key = schnorr.SerializePubKey(pubKey)
+	default:
+		return nil, fmt.Errorf("未知的脚本类型 %q，可选 p2wsh、p2tr", scriptType)
+	}
+	return txscript.NewScriptBuilder().
+		AddInt64(int64(l.Value)).AddOp(op).AddOp(txscript.OP_DROP).
+		AddData(key).AddOp(txscript.OP_CHECKSIG).
+		Script()
+}
+
+// Output 一个时间锁输出
+type Output struct {
+	Lock         Lock
+	Type         ScriptType
+	Script       []byte // P2WSH 见证脚本或 taproot 叶子脚本
+	PkScript     []byte
+	Address      btcutil.Address
+	ControlBlock []byte // 仅 taproot
+}
+
+// NewOutput 用公钥 pubKey 构造时间锁输出
+func NewOutput(lock Lock, scriptType ScriptType, pubKey *btcec.PublicKey, params *chaincfg.Params) (*Output, error) {
+	script, err := lock.Script(scriptType, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	out := &Output{Lock: lock, Type: scriptType, Script: script}
+
+	switch scriptType {
+	case P2WSH:
+		hash := sha256.Sum256(script)
+		out.Address, err = btcutil.NewAddressWitnessScriptHash(hash[:], params)
+	case P2TR:
+		leaf := txscript.NewBaseTapLeaf(script)
+		tree := txscript.AssembleTaprootScriptTree(leaf)
+		rootHash := tree.RootNode.TapHash()
+		internalKey := NUMSKey()
+		outputKey := txscript.ComputeTaprootOutputKey(internalKey, rootHash[:])
+		controlBlock := tree.LeafMerkleProofs[0].ToControlBlock(internalKey)
+		out.ControlBlock, err = controlBlock.ToBytes()
+		if err != nil {
+			return nil, err
+		}
+		out.Address, err = btcutil.NewAddressTaproot(schnorr.SerializePubKey(outputKey), params)
+	}
+	if err != nil {
+		return nil, err
+	}
+	out.PkScript, err = txscript.PayToAddrScript(out.Address)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Input 返回花费该输出的输入大小，见证为一个签名加上脚本（taproot 另有控制块）
+func (o *Output) Input() txsize.Input {
+	if o.Type == P2TR {
+		return txsize.TaprootScriptPathInput([]int{txsize.SchnorrSigSize}, len(o.Script), 0)
+	}
+	return txsize.P2WSHInput([]int{txsize.ECDSASigSize}, len(o.Script))
+}
+
+// PrepareSpend 设置花费该输出所需的 nLockTime、nSequence 和交易版本：
+// after 要求 nLockTime 不小于锁定值且同为高度或时间，输入的 nSequence 不能是 0xffffffff；
+// older 要求交易版本至少为 2，输入的 nSequence 等于锁定值。
+func (o *Output) PrepareSpend(tx *wire.MsgTx, index int) error {
+	in := tx.TxIn[index]
+	switch o.Lock.Kind {
+	case After:
+		if tx.LockTime != 0 && (tx.LockTime < LockTimeThreshold) != o.Lock.IsHeight() {
+			return fmt.Errorf("nLockTime %d 与时间锁 (%s) 一个是高度一个是时间，不能在同一笔交易中花费", tx.LockTime, o.Lock)
+		}
+		if tx.LockTime < o.Lock.Value {
+			tx.LockTime = o.Lock.Value
+		}
+		if in.Sequence == wire.MaxTxInSequenceNum {
+			in.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+	case Older:
+		if tx.Version < 2 {
+			tx.Version = 2
+		}
+		in.Sequence = o.Lock.Value
+	}
+	return nil
+}
+
+// Mature 按当前区块高度判断下一个区块能否包含花费交易。
+// fundingHeight 为输出所在区块的高度，未确认时为 0。按时间计算的锁需要区块中位时间，返回 true 交由节点判断。
+func (o *Output) Mature(tipHeight, fundingHeight int64) bool {
+	if !o.Lock.IsHeight() {
+		return true
+	}
+	if o.Lock.Kind == After {
+		// nLockTime 为 N 的交易可以进入高度大于 N 的区块
+		return tipHeight >= int64(o.Lock.Value)
+	}
+	if fundingHeight <= 0 {
+		return false
+	}
+	return tipHeight+1-fundingHeight >= int64(o.Lock.Value&wire.SequenceLockTimeMask)
+}
+
+// Witness 签名并返回花费该输出的见证
+func (o *Output) Witness(tx *wire.MsgTx, index int, sigHashes *txscript.TxSigHashes, prevOut *wire.TxOut,
+	privKey *btcec.PrivateKey) (wire.TxWitness, error) {
+	switch o.Type {
+	case P2WSH:
+		sig, err := txscript.RawTxInWitnessSignature(tx, sigHashes, index, prevOut.Value, o.Script, txscript.SigHashAll, privKey)
+		if err != nil {
+			return nil, err
+		}
+		return wire.TxWitness{sig, o.Script}, nil
+	case P2TR:
+		sig, err := txscript.RawTxInTapscriptSignature(tx, sigHashes, index, prevOut.Value, prevOut.PkScript,
+			txscript.NewBaseTapLeaf(o.Script), txscript.SigHashDefault, privKey)
+		if err != nil {
+			return nil, err
+		}
+		return wire.TxWitness{sig, o.Script, o.ControlBlock}, nil
+	}
+	return nil, fmt.Errorf("未知的脚本类型 %q", o.Type)
+}
+
+// AntiFeeSniping 返回防手续费狙击的 nLockTime，与 Bitcoin Core 钱包一致：
+// 通常为当前区块高度，使交易只能进入下一个区块，矿工重组上一个区块抢手续费没有好处；
+// 有 10% 的概率再随机提前最多 99 个区块，让延迟广播的交易不那么显眼。
+func AntiFeeSniping(tipHeight int64) uint32 {
+	height := tipHeight
+	if rand.Intn(10) == 0 {
+		height -= int64(rand.Intn(100))
+	}
+	if height < 0 {
+		height = 0
+	}
+	return uint32(height)
+}
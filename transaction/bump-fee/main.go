@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/esplora"
+	"go-btc/feebump"
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/keyring"
+	"go-btc/policy"
+	"go-btc/signer"
+	"go-btc/txsize"
+	"go-btc/walletdb"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	secrets        = helper.RegisterSecretFlags()
+	netOpts        = helper.RegisterNetworkFlags()
+	txid           = flag.String("txid", "", "要提高手续费的未确认交易")
+	feeRate        = flag.Int64("fee-rate", 0, "新的费率 (sat/vB)，为 0 时使用 mempool.space 推荐的最快费率")
+	incrementalFee = flag.Int64("incremental-relay-fee", policy.DefaultIncrementalRelayFeeRate, "节点的增量转发费率 (sat/vB)，替换交易多付的手续费至少为自身大小乘以该费率")
+	dustRelayFee   = flag.Int64("dust-relay-fee", policy.DefaultDustRelayFeeRate, "计算粉尘阈值使用的费率 (sat/vB)")
+	dbPath         = flag.String("db", "wallet.json", "钱包数据库文件")
+	assumeYes      = flag.Bool("yes", false, "跳过广播前的确认")
+)
+
+func main() {
+	flag.Parse()
+	if *txid == "" {
+		log.Fatal("请用 -txid 指定要提高手续费的交易")
+	}
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+	db, err := walletdb.Open(*dbPath, net.Name, deriver.MasterFingerprint())
+	if err != nil {
+		log.Fatalf("打开钱包数据库失败: %v", err)
+	}
+	keys := keyring.New(deriver)
+	if err := keys.DeriveIssued(db, net.CoinType(), 0); err != nil {
+		log.Fatalf("派生地址失败: %v", err)
+	}
+
+	// 从钱包数据库或后端取回原交易，后端同时提供确认状态、手续费和每个输入花费的输出
+	original, prevOuts, originalFee, err := loadOriginal(db, client, *txid)
+	if err != nil {
+		log.Fatalf("获取原交易失败: %v", err)
+	}
+	if !policy.SignalsRBF(original) {
+		fmt.Println("警告: 原交易没有声明可替换 (BIP-125)，只有开启 full-RBF 的节点会接受替换交易")
+	}
+	for _, in := range original.TxIn {
+		if _, ok := keys.KeyByScript(prevOuts.FetchPrevOutput(in.PreviousOutPoint).PkScript); !ok {
+			log.Fatalf("输入 %s 不属于本钱包，无法重新签名", in.PreviousOutPoint)
+		}
+	}
+
+	rate := *feeRate
+	if rate == 0 {
+		rates, err := client.FeeRates()
+		if err != nil {
+			log.Fatalf("获取推荐费率失败: %v", err)
+		}
+		rate = rates.FastestFee
+	}
+
+	// 本钱包找零链上的输出视为找零，其余输出都是收款，金额保持不变
+	changeIndex := -1
+	for i, out := range original.TxOut {
+		if key, ok := keys.KeyByScript(out.PkScript); ok && key.Path.Change == hdwallet.ChainInternal {
+			changeIndex = i
+			break
+		}
+	}
+	// 原交易没有找零时，追加输入后使用下一个未分配的找零地址
+	changePath := hdwallet.Path{
+		Purpose:  hdwallet.PurposeBIP86,
+		CoinType: net.CoinType(),
+		Change:   hdwallet.ChainInternal,
+		Index:    db.NextIndex(hdwallet.PurposeBIP86, net.CoinType(), 0, hdwallet.ChainInternal),
+	}
+	newChangeKey, err := keys.Add(changePath)
+	if err != nil {
+		log.Fatalf("派生找零地址失败: %v", err)
+	}
+	changeScript := newChangeKey.PkScript
+	if changeIndex >= 0 {
+		changeScript = original.TxOut[changeIndex].PkScript
+	}
+	changeInput, err := txsize.InputForPkScript(changeScript)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	candidates, walletUTXOs, err := confirmedUTXOs(client, keys, original)
+	if err != nil {
+		log.Fatalf("获取UTXOs失败: %v", err)
+	}
+	if err := db.AddUTXOs(walletUTXOs...); err != nil {
+		log.Fatalf("记录UTXOs失败: %v", err)
+	}
+
+	result, err := feebump.RBF(feebump.RBFParams{
+		Original:                original,
+		PrevOuts:                prevOuts,
+		OriginalFee:             originalFee,
+		ChangeIndex:             changeIndex,
+		ChangeScript:            newChangeKey.PkScript,
+		FeeRate:                 rate,
+		IncrementalRelayFeeRate: *incrementalFee,
+		MinChange:               policy.MinViableChange(changeScript, changeInput.Weight(), *dustRelayFee, policy.DefaultDiscardFeeRate),
+		Candidates:              candidates,
+	})
+	if err != nil {
+		log.Fatalf("构造替换交易失败: %v", err)
+	}
+
+	originalVSize := txsize.TxVSize(original)
+	fmt.Printf("原交易: %s, 手续费 %d sat, %d vB (%.2f sat/vB)\n",
+		*txid, originalFee, originalVSize, float64(originalFee)/float64(originalVSize))
+	fmt.Printf("替换交易: 手续费 %d sat, 约 %d vB (%.2f sat/vB), 追加输入 %d 个\n",
+		result.Fee, txsize.VSize(result.Weight), float64(result.Fee)/float64(txsize.VSize(result.Weight)), result.AddedInputs)
+	if result.ChangeIndex >= 0 {
+		fmt.Printf("找零: %d sat\n", result.Tx.TxOut[result.ChangeIndex].Value)
+	} else if result.ChangeDropped > 0 {
+		fmt.Printf("找零 %d sat 过小，已并入手续费\n", result.ChangeDropped)
+	}
+
+	if err := policy.CheckDust(result.Tx, *dustRelayFee); err != nil {
+		log.Fatalf("替换交易包含粉尘输出: %v", err)
+	}
+	if !*assumeYes {
+		ok, err := helper.Confirm("确认签名并广播替换交易")
+		if err != nil {
+			log.Fatalf("读取确认失败: %v", err)
+		}
+		if !ok {
+			log.Fatal("已取消")
+		}
+	}
+
+	tx := result.Tx
+	if err := signer.Sign(tx, result.PrevOuts, keys); err != nil {
+		log.Fatalf("签名失败: %v", err)
+	}
+	// 用签名后的实际大小再检查一次 BIP-125 规则
+	if err := policy.CheckReplacement(originalFee, originalVSize, result.Fee, txsize.TxVSize(tx), *incrementalFee); err != nil {
+		log.Fatal(err)
+	}
+	// 原交易的 OP_RETURN 输出原样保留，按原交易的数据大小放宽限制
+	opts := policy.DefaultOptions()
+	opts.DustRelayFeeRate = *dustRelayFee
+	opts.DataCarrierSize = max(policy.DefaultDataCarrierSize, policy.DataCarrierSize(original))
+	report := policy.Check(tx, result.PrevOuts, opts)
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	// 使用了新的找零地址时在广播前分配，其他进程已分配了这个索引时不广播
+	if result.ChangeIndex >= 0 && changeIndex < 0 {
+		if err := db.Reserve(changePath, "找零"); err != nil {
+			log.Fatalf("分配找零地址失败: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		log.Fatalf("序列化交易失败: %v", err)
+	}
+	rawTx := hex.EncodeToString(buf.Bytes())
+	fmt.Println("Signed Transaction: ", rawTx)
+
+	newTxID, err := client.Broadcast(rawTx)
+	if err != nil {
+		log.Fatalf("广播交易失败: %v", err)
+	}
+	fmt.Println("Transaction Hash: ", newTxID)
+
+	var changeKey *hdwallet.Key
+	if result.ChangeIndex >= 0 {
+		changeKey, _ = keys.KeyByScript(tx.TxOut[result.ChangeIndex].PkScript)
+	}
+	if err := recordReplacement(db, *txid, tx, result, rawTx, changeKey); err != nil {
+		log.Fatalf("记录交易失败: %v", err)
+	}
+}
+
+// loadOriginal 取回原交易及其输入花费的输出和手续费，已确认的交易无法替换
+func loadOriginal(db *walletdb.DB, client *esplora.Client, txid string) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, int64, error) {
+	info, err := client.Tx(txid)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if info.Status.Confirmed {
+		return nil, nil, 0, fmt.Errorf("交易已在区块 %d 确认，无法替换", info.Status.BlockHeight)
+	}
+
+	rawTx := ""
+	if record, ok := db.Tx(txid); ok && record.Raw != "" {
+		rawTx = record.Raw
+	} else if rawTx, err = client.RawTx(txid); err != nil {
+		return nil, nil, 0, err
+	}
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, nil, 0, fmt.Errorf("解析交易失败: %w", err)
+	}
+
+	if len(info.Vin) != len(tx.TxIn) {
+		return nil, nil, 0, fmt.Errorf("后端返回的交易与本地记录不一致")
+	}
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, vin := range info.Vin {
+		if vin.Prevout == nil {
+			return nil, nil, 0, fmt.Errorf("后端没有返回输入 %d 的前一笔输出", i)
+		}
+		pkScript, err := hex.DecodeString(vin.Prevout.ScriptPubKey)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		fetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(vin.Prevout.Value, pkScript))
+	}
+	return tx, fetcher, info.Fee, nil
+}
+
+// confirmedUTXOs 返回可以追加到替换交易中的 UTXO。BIP-125 不允许替换交易引入新的未确认输入，
+// 原交易自身的输出也不能花费。
+func confirmedUTXOs(client *esplora.Client, keys *keyring.Keyring, original *wire.MsgTx) ([]feebump.Candidate, []walletdb.UTXO, error) {
+	originalHash := original.TxHash()
+	spent := make(map[wire.OutPoint]bool)
+	for _, in := range original.TxIn {
+		spent[in.PreviousOutPoint] = true
+	}
+
+	var candidates []feebump.Candidate
+	var records []walletdb.UTXO
+	for _, key := range keys.Keys() {
+		utxos, err := client.UTXOs(key.Address.EncodeAddress())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, u := range utxos {
+			hash, err := chainhash.NewHashFromStr(u.TxID)
+			if err != nil {
+				return nil, nil, err
+			}
+			point := wire.OutPoint{Hash: *hash, Index: u.Vout}
+			if !u.Status.Confirmed || spent[point] || *hash == originalHash {
+				continue
+			}
+			candidates = append(candidates, feebump.Candidate{
+				OutPoint: point,
+				PrevOut:  wire.NewTxOut(u.Value, key.PkScript),
+			})
+			records = append(records, walletdb.UTXO{
+				TxID:     u.TxID,
+				Vout:     u.Vout,
+				Value:    u.Value,
+				PkScript: hex.EncodeToString(key.PkScript),
+				Path:     key.Path,
+				Height:   u.Status.BlockHeight,
+			})
+		}
+	}
+	return candidates, records, nil
+}
+
+// recordReplacement 在钱包数据库中用替换交易取代原交易
+func recordReplacement(db *walletdb.DB, originalTxID string, tx *wire.MsgTx, result *feebump.RBFResult, rawTx string, changeKey *hdwallet.Key) error {
+	txid := tx.TxHash().String()
+	if err := db.ReplaceTx(originalTxID, txid); err != nil {
+		return err
+	}
+	if err := db.AddTx(walletdb.Tx{TxID: txid, Raw: rawTx, Fee: result.Fee}); err != nil {
+		return err
+	}
+
+	outpoints := make([]string, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		outpoints[i] = in.PreviousOutPoint.String()
+	}
+	if err := db.SpendUTXOs(txid, outpoints...); err != nil {
+		return err
+	}
+	if changeKey == nil {
+		return nil
+	}
+	change := tx.TxOut[result.ChangeIndex]
+	return db.AddUTXOs(walletdb.UTXO{
+		TxID:     txid,
+		Vout:     uint32(result.ChangeIndex),
+		Value:    change.Value,
+		PkScript: hex.EncodeToString(change.PkScript),
+		Path:     changeKey.Path,
+	})
+}
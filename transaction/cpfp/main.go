@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+
+	"go-btc/esplora"
+	"go-btc/feebump"
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/keyring"
+	"go-btc/policy"
+	"go-btc/signer"
+	"go-btc/timelock"
+	"go-btc/txsize"
+	"go-btc/walletdb"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	secrets       = helper.RegisterSecretFlags()
+	netOpts       = helper.RegisterNetworkFlags()
+	rpcOpts       = helper.RegisterRPCFlags()
+	txid          = flag.String("txid", "", "要加速的未确认父交易")
+	vout          = flag.Int("vout", -1, "子交易花费的父交易输出，为 -1 时选择属于本钱包的金额最大的输出")
+	feeRate       = flag.Int64("fee-rate", 0, "父子交易整体的目标费率 (sat/vB)，为 0 时使用 mempool.space 推荐的最快费率")
+	dustRelayFee  = flag.Int64("dust-relay-fee", policy.DefaultDustRelayFeeRate, "计算粉尘阈值使用的费率 (sat/vB)")
+	submitPackage = flag.Bool("submit-package", false, "通过 RPC 节点的 submitpackage 把父子交易作为交易包提交，父交易不在内存池中时使用")
+	dbPath        = flag.String("db", "wallet.json", "钱包数据库文件")
+	assumeYes     = flag.Bool("yes", false, "跳过广播前的确认")
+)
+
+func main() {
+	flag.Parse()
+	if *txid == "" {
+		log.Fatal("请用 -txid 指定要加速的交易")
+	}
+
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+	db, err := walletdb.Open(*dbPath, net.Name, deriver.MasterFingerprint())
+	if err != nil {
+		log.Fatalf("打开钱包数据库失败: %v", err)
+	}
+	keys := keyring.New(deriver)
+	if err := keys.DeriveIssued(db, net.CoinType(), 0); err != nil {
+		log.Fatalf("派生地址失败: %v", err)
+	}
+
+	parent, parentRaw, parentFee, err := loadParent(db, client, *txid)
+	if err != nil {
+		log.Fatalf("获取父交易失败: %v", err)
+	}
+	index, parentKey, err := selectOutput(parent, keys, *vout)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	rate := *feeRate
+	if rate == 0 {
+		rates, err := client.FeeRates()
+		if err != nil {
+			log.Fatalf("获取推荐费率失败: %v", err)
+		}
+		rate = rates.FastestFee
+	}
+
+	// 子交易的输出使用下一个未分配的找零地址，确定广播时再分配，取消或失败时不浪费索引
+	outputKey, err := keys.Add(hdwallet.Path{
+		Purpose:  hdwallet.PurposeBIP86,
+		CoinType: net.CoinType(),
+		Change:   hdwallet.ChainInternal,
+		Index:    db.NextIndex(hdwallet.PurposeBIP86, net.CoinType(), 0, hdwallet.ChainInternal),
+	})
+	if err != nil {
+		log.Fatalf("派生找零地址失败: %v", err)
+	}
+	outputInput, err := txsize.InputForPkScript(outputKey.PkScript)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	candidates, walletUTXOs, err := confirmedUTXOs(client, keys, parent)
+	if err != nil {
+		log.Fatalf("获取UTXOs失败: %v", err)
+	}
+	if err := db.AddUTXOs(walletUTXOs...); err != nil {
+		log.Fatalf("记录UTXOs失败: %v", err)
+	}
+
+	tip, err := client.TipHeight()
+	if err != nil {
+		log.Fatalf("获取区块高度失败: %v", err)
+	}
+
+	result, err := feebump.CPFP(feebump.CPFPParams{
+		Parent:       parent,
+		ParentFee:    parentFee,
+		Vout:         index,
+		OutputScript: outputKey.PkScript,
+		FeeRate:      rate,
+		MinOutput:    policy.MinViableChange(outputKey.PkScript, outputInput.Weight(), *dustRelayFee, policy.DefaultDiscardFeeRate),
+		Candidates:   candidates,
+		LockTime:     timelock.AntiFeeSniping(tip),
+	})
+	if err != nil {
+		log.Fatalf("构造子交易失败: %v", err)
+	}
+
+	parentVSize := txsize.TxVSize(parent)
+	fmt.Printf("父交易: %s, 手续费 %d sat, %d vB (%.2f sat/vB)\n",
+		*txid, parentFee, parentVSize, float64(parentFee)/float64(parentVSize))
+	fmt.Printf("花费输出 %d: %d sat (%s)\n", index, parent.TxOut[index].Value, parentKey.Path)
+	fmt.Printf("子交易: 手续费 %d sat, 约 %d vB, 追加输入 %d 个, 输出 %d sat\n",
+		result.Fee, txsize.VSize(result.Weight), result.AddedInputs, result.Tx.TxOut[0].Value)
+	fmt.Printf("交易包: 手续费 %d sat, %d vB (%.2f sat/vB)\n",
+		result.PackageFee, result.PackageVSize, result.PackageFeeRate())
+
+	if err := policy.CheckDust(result.Tx, *dustRelayFee); err != nil {
+		log.Fatalf("子交易包含粉尘输出: %v", err)
+	}
+	if !*assumeYes {
+		ok, err := helper.Confirm("确认签名并广播子交易")
+		if err != nil {
+			log.Fatalf("读取确认失败: %v", err)
+		}
+		if !ok {
+			log.Fatal("已取消")
+		}
+	}
+
+	tx := result.Tx
+	if err := signer.Sign(tx, result.PrevOuts, keys); err != nil {
+		log.Fatalf("签名失败: %v", err)
+	}
+	if vsize := txsize.TxVSize(tx); parentFee+result.Fee < rate*(parentVSize+vsize) {
+		fmt.Printf("警告: 签名后子交易为 %d vB，交易包费率略低于目标\n", vsize)
+	}
+	opts := policy.DefaultOptions()
+	opts.DustRelayFeeRate = *dustRelayFee
+	report := policy.Check(tx, result.PrevOuts, opts)
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	// 广播前分配子交易的输出地址，其他进程已分配了这个索引时不广播
+	if err := db.Reserve(outputKey.Path, "CPFP"); err != nil {
+		log.Fatalf("分配找零地址失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		log.Fatalf("序列化交易失败: %v", err)
+	}
+	rawTx := hex.EncodeToString(buf.Bytes())
+	fmt.Println("Signed Transaction: ", rawTx)
+
+	if *submitPackage {
+		rpc, err := rpcOpts.Client()
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer rpc.Shutdown()
+		pkg, err := helper.SubmitPackage(rpc, parentRaw, rawTx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for wtxid, r := range pkg.TxResults {
+			fmt.Printf("已接受 %s (wtxid %s), %d vB\n", r.TxID, wtxid, r.VSize)
+		}
+	} else {
+		childTxID, err := client.Broadcast(rawTx)
+		if err != nil {
+			log.Fatalf("广播交易失败: %v", err)
+		}
+		fmt.Println("Transaction Hash: ", childTxID)
+	}
+
+	if err := recordChild(db, parent, parentRaw, parentFee, parentKey, index, tx, rawTx, result.Fee, outputKey); err != nil {
+		log.Fatalf("记录交易失败: %v", err)
+	}
+}
+
+// loadParent 取回父交易及其手续费。优先使用钱包数据库中的记录，
+// 父交易因费率过低没能进入内存池时后端查不到它，只能通过交易包提交。
+func loadParent(db *walletdb.DB, client *esplora.Client, txid string) (*wire.MsgTx, string, int64, error) {
+	record, known := db.Tx(txid)
+	info, err := client.Tx(txid)
+	switch {
+	case err == nil && info.Status.Confirmed:
+		return nil, "", 0, fmt.Errorf("交易已在区块 %d 确认，无需加速", info.Status.BlockHeight)
+	case err != nil && !(known && record.Raw != "" && record.Fee > 0):
+		return nil, "", 0, err
+	}
+
+	rawTx, fee := "", int64(0)
+	if known && record.Raw != "" {
+		rawTx, fee = record.Raw, record.Fee
+	} else if rawTx, err = client.RawTx(txid); err != nil {
+		return nil, "", 0, err
+	}
+	if info != nil {
+		fee = info.Fee
+	}
+
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, "", 0, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, "", 0, fmt.Errorf("解析交易失败: %w", err)
+	}
+	return tx, rawTx, fee, nil
+}
+
+// selectOutput 选择子交易花费的父交易输出，可以是收款也可以是找零，但必须属于本钱包
+func selectOutput(parent *wire.MsgTx, keys *keyring.Keyring, vout int) (uint32, *hdwallet.Key, error) {
+	if vout >= 0 {
+		if vout >= len(parent.TxOut) {
+			return 0, nil, fmt.Errorf("父交易没有输出 %d", vout)
+		}
+		key, ok := keys.KeyByScript(parent.TxOut[vout].PkScript)
+		if !ok {
+			return 0, nil, fmt.Errorf("父交易的输出 %d 不属于本钱包", vout)
+		}
+		return uint32(vout), key, nil
+	}
+
+	best := -1
+	var bestKey *hdwallet.Key
+	for i, out := range parent.TxOut {
+		key, ok := keys.KeyByScript(out.PkScript)
+		if ok && (best < 0 || out.Value > parent.TxOut[best].Value) {
+			best, bestKey = i, key
+		}
+	}
+	if best < 0 {
+		return 0, nil, fmt.Errorf("父交易没有属于本钱包的输出，无法使用 CPFP")
+	}
+	return uint32(best), bestKey, nil
+}
+
+// confirmedUTXOs 返回子交易输出不足时可追加的 UTXO。只使用已确认的输出，
+// 避免把其他未确认交易也拉进交易包。
+func confirmedUTXOs(client *esplora.Client, keys *keyring.Keyring, parent *wire.MsgTx) ([]feebump.Candidate, []walletdb.UTXO, error) {
+	parentHash := parent.TxHash()
+
+	var candidates []feebump.Candidate
+	var records []walletdb.UTXO
+	for _, key := range keys.Keys() {
+		utxos, err := client.UTXOs(key.Address.EncodeAddress())
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, u := range utxos {
+			hash, err := chainhash.NewHashFromStr(u.TxID)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !u.Status.Confirmed || *hash == parentHash {
+				continue
+			}
+			candidates = append(candidates, feebump.Candidate{
+				OutPoint: wire.OutPoint{Hash: *hash, Index: u.Vout},
+				PrevOut:  wire.NewTxOut(u.Value, key.PkScript),
+			})
+			records = append(records, walletdb.UTXO{
+				TxID:     u.TxID,
+				Vout:     u.Vout,
+				Value:    u.Value,
+				PkScript: hex.EncodeToString(key.PkScript),
+				Path:     key.Path,
+				Height:   u.Status.BlockHeight,
+			})
+		}
+	}
+	return candidates, records, nil
+}
+
+// recordChild 在钱包数据库中记录父交易、子交易以及子交易花费和创建的输出
+func recordChild(db *walletdb.DB, parent *wire.MsgTx, parentRaw string, parentFee int64, parentKey *hdwallet.Key, index uint32,
+	tx *wire.MsgTx, rawTx string, fee int64, outputKey *hdwallet.Key) error {
+	parentTxID := parent.TxHash().String()
+	if _, ok := db.Tx(parentTxID); !ok {
+		if err := db.AddTx(walletdb.Tx{TxID: parentTxID, Raw: parentRaw, Fee: parentFee}); err != nil {
+			return err
+		}
+	}
+	out := parent.TxOut[index]
+	if err := db.AddUTXOs(walletdb.UTXO{
+		TxID:     parentTxID,
+		Vout:     index,
+		Value:    out.Value,
+		PkScript: hex.EncodeToString(out.PkScript),
+		Path:     parentKey.Path,
+	}); err != nil {
+		return err
+	}
+
+	txid := tx.TxHash().String()
+	if err := db.AddTx(walletdb.Tx{TxID: txid, Raw: rawTx, Fee: fee, Label: "CPFP " + parentTxID}); err != nil {
+		return err
+	}
+	outpoints := make([]string, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		outpoints[i] = in.PreviousOutPoint.String()
+	}
+	if err := db.SpendUTXOs(txid, outpoints...); err != nil {
+		return err
+	}
+	return db.AddUTXOs(walletdb.UTXO{
+		TxID:     txid,
+		Vout:     0,
+		Value:    tx.TxOut[0].Value,
+		PkScript: hex.EncodeToString(tx.TxOut[0].PkScript),
+		Path:     outputKey.Path,
+	})
+}
@@ -3,28 +3,83 @@ package main
 import (
 	"bytes"
 	"encoding/hex"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
-	"net/http"
-	"os"
-
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-btc/coinselect"
+	"go-btc/esplora"
+	"go-btc/hdwallet"
 	"go-btc/helper"
-
-	"github.com/btcsuite/btcd/btcutil"
-	"github.com/btcsuite/btcd/chaincfg"
+	"go-btc/keyring"
+	"go-btc/network"
+	"go-btc/payout"
+	"go-btc/policy"
+	"go-btc/psbt"
+	"go-btc/signer"
+	"go-btc/timelock"
+	"go-btc/txsize"
+	"go-btc/walletdb"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 )
 
 var (
-	cfg                      = &chaincfg.TestNet3Params
+	net                *network.Network
 	receiveTaprootAddr       = "tb1pcvwe95ec64urxykp2nfdnvxftfk0rvvw0w77u4mauv2355gxrf4qg0r5xj"
 	outputAmount       int64 = 1000
 	feeRate                  = FastestFee
+
+	secrets       = helper.RegisterSecretFlags()
+	netOpts       = helper.RegisterNetworkFlags()
+	coinSelection = flag.String("coin-selection", string(coinselect.Auto), "选币算法: auto, bnb, knapsack, largest-first, oldest-first")
+	dbPath        = flag.String("db", "wallet.json", "钱包数据库文件，记录已分配的地址、UTXO 和交易")
+	paymentsFile  = flag.String("payments", "", "批量付款文件 (.csv 或 .json)，每行 address,amount[,label]，金额单位 sat；指定后不再使用默认收款地址")
+	dustRelayFee  = flag.Int64("dust-relay-fee", policy.DefaultDustRelayFeeRate, "计算粉尘阈值使用的费率 (sat/vB)")
+	maxFeeRate    = flag.Int64("max-fee-rate", policy.DefaultMaxFeeRate, "费率超过该值 (sat/vB) 时拒绝广播，0 为不检查")
+	maxFee        = flag.Int64("max-fee", policy.DefaultMaxFee, "手续费超过该值 (sat) 时拒绝广播，0 为不检查")
+	subtractFee   = flag.Bool("subtract-fee", false, "手续费从收款金额中扣除，多个收款人时平均分摊")
+	sweepTo       = flag.String("sweep-to", "", "清空钱包：把全部（或 -utxos 指定的）UTXO 发送到该地址，手续费从中扣除，忽略 -payments")
+	spendUTXOs    = flag.String("utxos", "", "只花费这些输出，逗号分隔的 txid:vout")
+	assumeYes     = flag.Bool("yes", false, "跳过签名前的确认")
+	lookahead     = flag.Uint("lookahead", 0, "除已分配的地址外，每条链再向后扫描的地址数量，用于找回其他钱包软件收到的币")
+	rbf           = flag.Bool("rbf", true, "声明交易可被替换 (BIP-125)，之后可以用 transaction/bump-fee 提高手续费")
+	lockTime      = flag.Int64("locktime", -1, "交易的 nLockTime：-1 为防手续费狙击（当前区块高度），0 为不设置，其它值按区块高度（小于 500000000）或 unix 时间使用")
+	sequence      = flag.Int64("sequence", -1, "所有输入的 nSequence，-1 时由 -rbf 决定")
+	sigHash       = flag.String("sighash", "default", "签名使用的 sighash 类型: default, all, none, single, all|anyonecanpay, none|anyonecanpay, single|anyonecanpay；default 时 taproot 使用 DEFAULT，其余使用 ALL")
+	allowNone     = flag.Bool("allow-sighash-none", false, "允许 SIGHASH_NONE（签名不承诺任何输出，任何人都可以改写收款地址）")
+	sigHashType   txscript.SigHashType
+	psbtOut       = flag.String("psbt", "", "不签名，把未签名交易写入该 PSBT 文件，交给其他钱包或硬件钱包签名")
+	watchOnly     = flag.String("watch-only", "", "观察钱包的描述符文件（account/descriptor 的输出），只用公钥创建交易，不读取助记词；必须同时指定 -psbt")
+	psbtVersion   = flag.Uint("psbt-version", psbt.Version0, "PSBT 版本: 0 或 2")
+	psbtBinary    = flag.Bool("psbt-binary", false, "以二进制而不是 base64 格式写入 PSBT")
+	dataCarrier   = flag.Int("datacarrier-size", policy.DefaultDataCarrierSize, "所有 OP_RETURN 输出脚本的总字节数上限，与节点的 -datacarriersize 一致")
+	dataSpecs     dataFlag
 )
 
+func init() {
+	flag.Var(&dataSpecs, "data", "添加 OP_RETURN 数据输出，可重复指定: hex:<十六进制>、text:<文本> 或 file:<路径>（写入文件的 SHA-256）")
+}
+
+// dataFlag 可重复指定的 -data 参数
+type dataFlag []string
+
+func (f *dataFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *dataFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
 // FeeRateType 定义费率类型
 type FeeRateType string
 
@@ -36,49 +91,229 @@ const (
 	MinimumFee  FeeRateType = "minimum"
 )
 
-// UTXO represents a single UTXO retrieved from Mempool API
+// UTXO 钱包地址上的一个未花费输出
 type UTXO struct {
-	TxID     string `json:"txid"`
-	Vout     uint32 `json:"vout"`
-	Amount   int64  `json:"value"`
-	PkScript string `json:"scriptPubKey"`
+	TxID     string
+	Vout     uint32
+	Amount   int64
+	PkScript string        // 十六进制锁定脚本
+	Height   int64         // 确认高度，0 表示未确认
+	Path     hdwallet.Path // 持有该 UTXO 的地址路径
 }
 
 func main() {
-	mnemonic, err := helper.GetMnemonicFromENV()
+	flag.Parse()
+
+	var err error
+	net, err = netOpts.Network()
 	if err != nil {
-		log.Fatalf("获取助记词失败: %v", err)
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	// 先确认所有收款地址都属于当前网络，避免把币发到另一个网络的地址上
+	var payments []payout.Payment
+	if *sweepTo != "" {
+		if _, err := net.DecodeAddress(*sweepTo); err != nil {
+			log.Fatalf("收款地址无效: %v", err)
+		}
+	} else {
+		payments, err = loadPayments()
+		if err != nil {
+			log.Fatalf("收款列表无效: %v", err)
+		}
+	}
+
+	data, err := loadData()
+	if err != nil {
+		log.Fatalf("数据输出无效: %v", err)
+	}
+	sigHashType, err = signer.ParseSigHashType(*sigHash)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// 观察钱包只持有公钥，交易写入 PSBT 后拿到离线设备上签名
+	var (
+		source      keyring.Source
+		fingerprint uint32
+	)
+	if *watchOnly != "" {
+		if *psbtOut == "" {
+			log.Fatal("观察钱包无法签名，请同时指定 -psbt")
+		}
+		wallet, err := keyring.LoadWatchOnly(*watchOnly, net.Params)
+		if err != nil {
+			log.Fatalf("加载观察钱包失败: %v", err)
+		}
+		source, fingerprint = wallet, wallet.MasterFingerprint()
+	} else {
+		mnemonic, passphrase, err := secrets.Load()
+		if err != nil {
+			log.Fatalf("获取助记词失败: %v", err)
+		}
+		deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+		if err != nil {
+			log.Fatalf("创建派生器失败: %v", err)
+		}
+		source, fingerprint = deriver, deriver.MasterFingerprint()
 	}
 
-	wif, _, taprootAddr, err := helper.GenerateTaprootAddress(mnemonic, cfg, 0)
+	db, err := walletdb.Open(*dbPath, net.Name, fingerprint)
 	if err != nil {
-		log.Fatalf("生成 BIP-84 地址失败: %v", err)
+		log.Fatalf("打开钱包数据库失败: %v", err)
 	}
 
-	// 获取所有UTXOs
-	utxos, err := getUTXOsFromAPI(taprootAddr.String())
+	// 收款地址 m/86'/coin'/0'/0/0 一直是默认的充值地址，确保它被记录为已使用
+	if err := db.MarkUsed(hdwallet.Path{Purpose: hdwallet.PurposeBIP86, CoinType: net.CoinType()}); err != nil {
+		log.Fatalf("更新钱包数据库失败: %v", err)
+	}
+
+	// 获取所有地址类型下已分配地址（收款和找零）以及之后 -lookahead 个地址上的 UTXOs，
+	// 旧地址和 SegWit 地址上的币可以在同一笔交易中归集到 taproot 地址
+	keys := keyring.New(source)
+	if err := keys.DeriveIssued(db, net.CoinType(), uint32(*lookahead)); err != nil {
+		log.Fatalf("派生地址失败: %v", err)
+	}
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+	var utxos []UTXO
+	for _, key := range keys.Keys() {
+		addrUTXOs, err := getUTXOs(client, key)
+		if err != nil {
+			log.Fatalf("获取UTXOs失败: %v", err)
+		}
+		utxos = append(utxos, addrUTXOs...)
+		// 预读范围内收到币的地址记为已使用，之后不会再分配
+		if len(addrUTXOs) > 0 {
+			if err := db.MarkUsed(key.Path); err != nil {
+				log.Fatalf("更新钱包数据库失败: %v", err)
+			}
+		}
+	}
+	fmt.Printf("已扫描 %d 个地址，共 %d 个 UTXO\n", keys.Len(), len(utxos))
+	if err := db.AddUTXOs(toWalletUTXOs(utxos)...); err != nil {
+		log.Fatalf("记录UTXOs失败: %v", err)
+	}
+	utxos, err = filterUTXOs(utxos, *spendUTXOs)
 	if err != nil {
-		log.Fatalf("获取UTXOs失败: %v", err)
+		log.Fatalf("选择UTXOs失败: %v", err)
 	}
 
 	// 获取动态费率
-	feeRate, err := getFeeRate(feeRate)
+	feeRate, err := getFeeRate(client, feeRate)
 	if err != nil {
 		log.Fatalf("获取动态费率失败: %v", err)
 	}
 
 	// 创建交易
-	tx, fetcher := createTransaction(utxos, receiveTaprootAddr, taprootAddr.String(), feeRate)
+	var (
+		tx        *wire.MsgTx
+		fetcher   *txscript.MultiPrevOutFetcher
+		changeKey *hdwallet.Key
+	)
+	if *sweepTo != "" {
+		tx, fetcher, payments, err = createSweepTransaction(utxos, data, *sweepTo, feeRate)
+	} else {
+		// 每笔交易使用新的找零地址，避免地址复用。这里只按下一个未分配的索引派生，
+		// 余额不足、粉尘或取消时不分配，免得反复尝试留下超过间隔限制的空洞
+		changePath := hdwallet.Path{
+			Purpose:  hdwallet.PurposeBIP86,
+			CoinType: net.CoinType(),
+			Change:   hdwallet.ChainInternal,
+			Index:    db.NextIndex(hdwallet.PurposeBIP86, net.CoinType(), 0, hdwallet.ChainInternal),
+		}
+		changeKey, err = keys.Add(changePath)
+		if err != nil {
+			log.Fatalf("派生找零地址失败: %v", err)
+		}
+		fmt.Printf("找零地址: %s (%s)\n", changeKey.Address.EncodeAddress(), changePath)
 
-	// 添加见证
-	err = addWitnesses(tx, wif, fetcher)
+		tx, fetcher, payments, err = createTransaction(utxos, payments, data, changeKey.Address.EncodeAddress(), feeRate)
+	}
 	if err != nil {
-		log.Fatalf("添加见证失败: %v", err)
+		log.Fatalf("创建交易失败: %v", err)
+	}
+	// 找零过小并入手续费时交易没有找零输出，不需要分配找零地址
+	if changeKey != nil && !paysTo(tx, changeKey.PkScript) {
+		changeKey = nil
+	}
+
+	if err := setLockTime(tx); err != nil {
+		log.Fatalf("设置 nLockTime 失败: %v", err)
+	}
+
+	// 粉尘输出会让整笔交易被节点拒绝
+	if err := policy.CheckDust(tx, *dustRelayFee); err != nil {
+		log.Fatalf("交易包含粉尘输出: %v", err)
+	}
+
+	// 签名前汇总付款，确认后再继续
+	printPaymentSummary(payments, data, tx, fetcher)
+	if *psbtOut != "" {
+		packet, err := newPSBT(tx, fetcher, keys, changeKey, fingerprint, uint32(*psbtVersion))
+		if err != nil {
+			log.Fatalf("创建 PSBT 失败: %v", err)
+		}
+		// 写出 PSBT 时分配找零地址，之后的交易不会再用到它
+		if err := reserveChange(db, changeKey); err != nil {
+			log.Fatalf("分配找零地址失败: %v", err)
+		}
+		if err := psbt.WriteFile(*psbtOut, packet, *psbtBinary); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("未签名的 PSBT 已写入 %s\n", *psbtOut)
+		return
+	}
+	if !*assumeYes {
+		ok, err := helper.Confirm("确认签名并广播")
+		if err != nil {
+			log.Fatalf("读取确认失败: %v", err)
+		}
+		if !ok {
+			log.Fatal("已取消")
+		}
+	}
+
+	// 按每个输入的地址类型签名
+	if err := signer.SignWithOptions(tx, fetcher, keys, signer.Options{HashType: sigHashType, AllowNone: *allowNone}); err != nil {
+		log.Fatalf("签名失败: %v", err)
 	}
 
 	// 打印交易详情
 	printTransactionDetails(tx, fetcher)
 
+	// 用签名后的实际大小检查估算值，实际更大说明手续费率低于预期
+	estimated, err := estimateWeight(tx, fetcher)
+	if err != nil {
+		log.Fatalf("估算交易大小失败: %v", err)
+	}
+	actual := txsize.TxWeight(tx)
+	fmt.Printf("估算重量: %d WU (%d vB), 实际重量: %d WU (%d vB)\n",
+		estimated, txsize.VSize(estimated), actual, txsize.VSize(actual))
+	if actual > estimated {
+		log.Fatalf("实际交易大小超过估算值，手续费率将低于 %d sat/vB", feeRate)
+	}
+
+	// 广播前在本地执行每个输入的脚本并检查转发策略，失败时指出是哪个输入或哪条规则
+	report := policy.Check(tx, fetcher, policy.Options{
+		DustRelayFeeRate: *dustRelayFee,
+		MinRelayFeeRate:  policy.DefaultMinRelayFeeRate,
+		MaxFeeRate:       *maxFeeRate,
+		MaxFee:           *maxFee,
+		DataCarrierSize:  *dataCarrier,
+	})
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	// 广播前分配找零地址，其他进程已分配了这个索引时不广播
+	if err := reserveChange(db, changeKey); err != nil {
+		log.Fatalf("分配找零地址失败: %v", err)
+	}
+
 	// 序列化交易
 	finalRawTx, err := serializeTransaction(tx)
 	if err != nil {
@@ -86,194 +321,504 @@ func main() {
 	}
 	fmt.Println("Signed Transaction: ", finalRawTx)
 
-	// 广播交易
-	txHash, err := broadcastTransaction(tx)
+	// 通过当前网络的 API 广播，-network 指定的网络与交易的地址一致
+	txHash, err := client.Broadcast(finalRawTx)
 	if err != nil {
 		log.Fatalf("广播交易失败: %v", err)
 	}
-	fmt.Println("Transaction Hash: ", txHash.String())
+	fmt.Println("Transaction Hash: ", txHash)
+
+	if err := recordTransaction(db, tx, fetcher, finalRawTx, changeKey); err != nil {
+		log.Fatalf("记录交易失败: %v", err)
+	}
 }
 
-// getFeeRate 获取指定类型的费率
-func getFeeRate(feeType FeeRateType) (int64, error) {
-	resp, err := http.Get("https://mempool.space/testnet/api/v1/fees/recommended")
-	if err != nil {
-		return 0, err
+// paysTo 判断交易是否有输出付给该锁定脚本
+func paysTo(tx *wire.MsgTx, pkScript []byte) bool {
+	for _, out := range tx.TxOut {
+		if bytes.Equal(out.PkScript, pkScript) {
+			return true
+		}
 	}
-	defer resp.Body.Close()
+	return false
+}
 
-	var feeData struct {
-		FastestFee  int64 `json:"fastestFee"`
-		HalfHourFee int64 `json:"halfHourFee"`
-		HourFee     int64 `json:"hourFee"`
-		EconomyFee  int64 `json:"economyFee"`
-		MinimumFee  int64 `json:"minimumFee"`
+// reserveChange 在钱包数据库中分配找零地址的索引。找零地址按 NextIndex 派生，
+// 其他进程在此期间分配了同一个索引时返回错误，交易不能再使用这个地址
+func reserveChange(db *walletdb.DB, changeKey *hdwallet.Key) error {
+	if changeKey == nil {
+		return nil
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&feeData); err != nil {
+	return db.Reserve(changeKey.Path, "找零")
+}
+
+// getFeeRate 获取指定类型的费率
+func getFeeRate(client *esplora.Client, feeType FeeRateType) (int64, error) {
+	rates, err := client.FeeRates()
+	if err != nil {
 		return 0, err
 	}
 
 	switch feeType {
 	case FastestFee:
-		return feeData.FastestFee, nil
+		return rates.FastestFee, nil
 	case HalfHourFee:
-		return feeData.HalfHourFee, nil
+		return rates.HalfHourFee, nil
 	case HourFee:
-		return feeData.HourFee, nil
+		return rates.HourFee, nil
 	case EconomyFee:
-		return feeData.EconomyFee, nil
+		return rates.EconomyFee, nil
 	case MinimumFee:
-		return feeData.MinimumFee, nil
+		return rates.MinimumFee, nil
 	default:
-		return feeData.HourFee, nil
+		return rates.HourFee, nil
 	}
 }
 
-// getUTXOsFromAPI 从 Mempool API 获取 UTXOs
-func getUTXOsFromAPI(address string) ([]UTXO, error) {
-	resp, err := http.Get(fmt.Sprintf("https://mempool.space/testnet/api/address/%s/utxo", address))
+// getUTXOs 获取密钥所在地址上的 UTXOs，锁定脚本即该地址的脚本
+func getUTXOs(client *esplora.Client, key *hdwallet.Key) ([]UTXO, error) {
+	addrUTXOs, err := client.UTXOs(key.Address.EncodeAddress())
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	var utxos []UTXO
-	if err := json.NewDecoder(resp.Body).Decode(&utxos); err != nil {
-		return nil, err
+	utxos := make([]UTXO, len(addrUTXOs))
+	for i, u := range addrUTXOs {
+		utxos[i] = UTXO{
+			TxID:     u.TxID,
+			Vout:     u.Vout,
+			Amount:   u.Value,
+			PkScript: hex.EncodeToString(key.PkScript),
+			Height:   u.Status.BlockHeight,
+			Path:     key.Path,
+		}
 	}
+	return utxos, nil
+}
 
-	// 获取 pkScript 并填充到 utxos 中
-	pkScript, _ := decodeTaprootAddress(address, cfg)
+// createTransaction 选币并创建交易，找零低于粉尘阈值时并入手续费。
+// 指定 -subtract-fee 时手续费从收款金额中扣除，返回扣除后的付款列表。
+func createTransaction(utxos []UTXO, payments []payout.Payment, data []payout.Data, changeAddr string, feeRate int64) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, []payout.Payment, error) {
+	changeByteAddr, err := decodeAddressScript(changeAddr, net)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("解码找零地址失败: %w", err)
+	}
 
-	// 打印获取的 UTXO，以检查 pkScript
-	for i := range utxos {
-		utxos[i].PkScript = hex.EncodeToString(pkScript)
-		// utxos[i].PkScript, _ = getPKScriptFromAPI(utxo.TxID, utxo.Vout)
+	coins, err := toCoins(utxos)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	changeInput, err := txsize.InputForPkScript(changeByteAddr)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	return utxos, nil
+	algorithm, err := coinselect.ParseAlgorithm(*coinSelection)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	baseWeight := txsize.OverheadWeight(len(utxos), len(payments)+len(data)+1, true)
+	for _, p := range payments {
+		baseWeight += txsize.OutputWeight(p.PkScript)
+	}
+	for _, d := range data {
+		baseWeight += txsize.OutputWeight(d.PkScript)
+	}
+	// 找零低于粉尘阈值时节点不会转发，低于将来花费它的手续费时也不划算，这两种情况都并入手续费
+	minChange := policy.MinViableChange(changeByteAddr, changeInput.Weight(), *dustRelayFee, policy.DefaultDiscardFeeRate)
+	result, err := coinselect.Select(algorithm, coins, coinselect.Params{
+		Target:            payout.Total(payments),
+		FeeRate:           feeRate,
+		BaseWeight:        baseWeight,
+		ChangeWeight:      txsize.OutputWeight(changeByteAddr),
+		ChangeSpendWeight: changeInput.Weight(),
+		MinChange:         minChange,
+		SubtractFee:       *subtractFee,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fmt.Printf("选币算法: %s, 输入数: %d, 手续费: %d, waste: %d\n",
+		result.Algorithm, len(result.Coins), result.Fee, result.Waste)
+	if result.ChangeDropped > 0 {
+		fmt.Printf("找零 %d sat 低于最小找零 %d sat（粉尘阈值 %d sat，按 %d sat/vB 花费它的成本），已并入手续费\n",
+			result.ChangeDropped, minChange, policy.DustThreshold(changeByteAddr, *dustRelayFee), policy.DefaultDiscardFeeRate)
+	}
+	if *subtractFee {
+		payments, err = payout.SubtractFee(payments, result.RecipientFee, *dustRelayFee)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("从收款金额中扣除手续费失败: %w", err)
+		}
+		fmt.Printf("从 %d 笔收款中共扣除手续费 %d sat\n", len(payments), result.RecipientFee)
+	}
+
+	var change *wire.TxOut
+	if result.Change > 0 {
+		change = wire.NewTxOut(result.Change, changeByteAddr)
+	}
+	tx, fetcher, err := buildTransaction(utxos, result.Coins, payments, data, change)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tx, fetcher, payments, nil
 }
 
-// getPKScriptFromAPI 从 Mempool API 获取 pkScript
-func getPKScriptFromAPI(txId string, vout uint32) (string, error) {
-	url := fmt.Sprintf("https://mempool.space/testnet/api/tx/%s", txId)
-	txResp, err := http.Get(url)
+// createSweepTransaction 把全部 UTXO 发送到一个地址，手续费从该输出中扣除，不找零
+func createSweepTransaction(utxos []UTXO, data []payout.Data, sweepAddr string, feeRate int64) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, []payout.Payment, error) {
+	sweepByteAddr, err := decodeAddressScript(sweepAddr, net)
 	if err != nil {
-		return "", err
+		return nil, nil, nil, fmt.Errorf("解码收款地址失败: %w", err)
+	}
+	coins, err := toCoins(utxos)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	defer txResp.Body.Close()
 
-	var txDetails struct {
-		Vout []struct {
-			Value        float64 `json:"value"`
-			N            int     `json:"n"`
-			ScriptPubKey string  `json:"scriptPubKey"`
-		} `json:"vout"`
+	baseWeight := txsize.OverheadWeight(len(utxos), len(data)+1, true) + txsize.OutputWeight(sweepByteAddr)
+	for _, d := range data {
+		baseWeight += txsize.OutputWeight(d.PkScript)
 	}
-	if err := json.NewDecoder(txResp.Body).Decode(&txDetails); err != nil {
-		return "", err
+	result, err := coinselect.SelectAll(coins, coinselect.Params{
+		FeeRate:    feeRate,
+		BaseWeight: baseWeight,
+	})
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	return txDetails.Vout[vout].ScriptPubKey, nil
+	payment, err := payout.New(sweepAddr, result.InputValue-result.RecipientFee, "sweep", net, *dustRelayFee)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("扣除手续费 %d sat 后的金额无效: %w", result.RecipientFee, err)
+	}
+	fmt.Printf("清空 %d 个 UTXO 共 %d sat，手续费 %d sat\n", len(result.Coins), result.InputValue, result.Fee)
+
+	payments := []payout.Payment{payment}
+	tx, fetcher, err := buildTransaction(utxos, result.Coins, payments, data, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tx, fetcher, payments, nil
 }
 
-// createTransaction 创建交易
-func createTransaction(utxos []UTXO, receiveAddr, changeAddr string, feeRate int64) (*wire.MsgTx, *txscript.MultiPrevOutFetcher) {
-	tx := wire.NewMsgTx(wire.TxVersion)
-	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+// toCoins 把 UTXO 转换为选币输入，Coin.ID 为 UTXO 在列表中的下标
+func toCoins(utxos []UTXO) ([]coinselect.Coin, error) {
+	coins := make([]coinselect.Coin, len(utxos))
+	for i, utxo := range utxos {
+		pkScript, _ := hex.DecodeString(utxo.PkScript)
+		input, err := inputForPkScript(pkScript)
+		if err != nil {
+			return nil, err
+		}
+		coins[i] = coinselect.Coin{
+			ID:          strconv.Itoa(i),
+			Value:       utxo.Amount,
+			InputWeight: input.Weight(),
+			Height:      utxo.Height,
+		}
+	}
+	return coins, nil
+}
 
-	var totalInputAmount int64
-	for _, utxo := range utxos {
-		txHash, _ := chainhash.NewHashFromStr(utxo.TxID)
+// buildTransaction 用选中的输入、付款和可选的找零输出组装未签名交易。
+// 版本为 2，与 Bitcoin Core 钱包一致；版本 1 的交易不执行 BIP-68，-sequence 设置的相对时间锁不会生效
+func buildTransaction(utxos []UTXO, coins []coinselect.Coin, payments []payout.Payment, data []payout.Data, change *wire.TxOut) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, error) {
+	tx := wire.NewMsgTx(2)
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for _, coin := range coins {
+		i, _ := strconv.Atoi(coin.ID)
+		utxo := utxos[i]
+		txHash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("无效的交易 ID %s: %w", utxo.TxID, err)
+		}
 		point := wire.NewOutPoint(txHash, utxo.Vout)
 		tx.AddTxIn(wire.NewTxIn(point, nil, nil))
-		totalInputAmount += utxo.Amount
 		pkScript, _ := hex.DecodeString(utxo.PkScript)
 		fetcher.AddPrevOut(*point, wire.NewTxOut(utxo.Amount, pkScript))
+	}
+
+	for _, p := range payments {
+		tx.AddTxOut(wire.NewTxOut(p.Amount, p.PkScript))
+	}
+	for _, d := range data {
+		tx.AddTxOut(wire.NewTxOut(0, d.PkScript))
+	}
+	if change != nil {
+		tx.AddTxOut(change)
+	}
+
+	return tx, fetcher, nil
+}
 
-		if totalInputAmount >= outputAmount {
-			break
+// setLockTime 按 -locktime、-sequence 和 -rbf 设置 nLockTime 和每个输入的 nSequence。
+// 所有输入的 nSequence 都是 0xffffffff 时 nLockTime 不生效，因此不声明可替换时使用 0xfffffffe。
+func setLockTime(tx *wire.MsgTx) error {
+	switch {
+	case *lockTime < 0:
+		client, err := net.Client()
+		if err != nil {
+			return err
 		}
+		tip, err := client.TipHeight()
+		if err != nil {
+			return fmt.Errorf("获取区块高度失败: %w", err)
+		}
+		tx.LockTime = timelock.AntiFeeSniping(tip)
+	case *lockTime > math.MaxUint32:
+		return fmt.Errorf("nLockTime %d 超出范围", *lockTime)
+	default:
+		tx.LockTime = uint32(*lockTime)
+	}
+	if *sequence > math.MaxUint32 {
+		return fmt.Errorf("nSequence %d 超出范围", *sequence)
 	}
 
-	receiveByteAddr, _ := decodeTaprootAddress(receiveAddr, cfg)
-	tx.AddTxOut(wire.NewTxOut(outputAmount, receiveByteAddr))
+	final := true
+	for _, in := range tx.TxIn {
+		switch {
+		case *sequence >= 0:
+			in.Sequence = uint32(*sequence)
+		case *rbf:
+			in.Sequence = policy.MaxRBFSequence
+		case tx.LockTime != 0:
+			in.Sequence = wire.MaxTxInSequenceNum - 1
+		}
+		if in.Sequence != wire.MaxTxInSequenceNum {
+			final = false
+		}
+	}
+	if final && tx.LockTime != 0 {
+		return fmt.Errorf("所有输入的 nSequence 都是 0xffffffff，nLockTime %d 不会生效", tx.LockTime)
+	}
+	return nil
+}
 
-	estimatedTxSize := estimateTxSize(len(tx.TxIn), 2)
-	fee := int64(estimatedTxSize) * feeRate
+// filterUTXOs 只保留 -utxos 指定的输出，格式为逗号分隔的 txid:vout
+func filterUTXOs(utxos []UTXO, outpoints string) ([]UTXO, error) {
+	if outpoints == "" {
+		return utxos, nil
+	}
+	byOutpoint := make(map[string]UTXO, len(utxos))
+	for _, utxo := range utxos {
+		byOutpoint[fmt.Sprintf("%s:%d", utxo.TxID, utxo.Vout)] = utxo
+	}
+
+	var selected []UTXO
+	for _, op := range strings.Split(outpoints, ",") {
+		op = strings.TrimSpace(op)
+		utxo, ok := byOutpoint[op]
+		if !ok {
+			return nil, fmt.Errorf("钱包中没有未花费的输出 %s", op)
+		}
+		selected = append(selected, utxo)
+		delete(byOutpoint, op)
+	}
+	return selected, nil
+}
 
-	changeAmount := totalInputAmount - outputAmount - fee
-	if changeAmount > 0 {
-		changeByteAddr, _ := decodeTaprootAddress(changeAddr, cfg)
-		tx.AddTxOut(wire.NewTxOut(changeAmount, changeByteAddr))
+// loadPayments 读取批量付款文件，未指定时向默认收款地址付款
+func loadPayments() ([]payout.Payment, error) {
+	if *paymentsFile != "" {
+		return payout.Load(*paymentsFile, net, *dustRelayFee)
+	}
+	payment, err := payout.New(receiveTaprootAddr, outputAmount, "", net, *dustRelayFee)
+	if err != nil {
+		return nil, err
 	}
+	return []payout.Payment{payment}, nil
+}
 
-	return tx, fetcher
+// loadData 解析 -data 指定的 OP_RETURN 数据输出，并检查总大小是否超过 -datacarrier-size
+func loadData() ([]payout.Data, error) {
+	data := make([]payout.Data, 0, len(dataSpecs))
+	for _, spec := range dataSpecs {
+		d, err := payout.ParseData(spec)
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, d)
+	}
+	if size := payout.DataSize(data); size > *dataCarrier {
+		return nil, fmt.Errorf("OP_RETURN 输出脚本共 %d 字节，超过 -datacarrier-size %d 字节，节点不会转发", size, *dataCarrier)
+	}
+	if len(data) > 1 {
+		fmt.Printf("注意: 交易包含 %d 个 OP_RETURN 输出，Bitcoin Core 30 之前的节点只转发一个\n", len(data))
+	}
+	return data, nil
 }
 
-// estimateTxSize 估算交易大小
-func estimateTxSize(numInputs, numOutputs int) int {
-	return numInputs*180 + numOutputs*34 + 10 + 2 // 额外加上版本和锁定时间的字节
+// printPaymentSummary 签名前打印付款汇总：笔数、总额、地址类型、数据输出、手续费和找零
+func printPaymentSummary(payments []payout.Payment, data []payout.Data, tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher) {
+	summary := payout.Summarize(payments)
+
+	fmt.Println("付款汇总:")
+	for _, p := range payments {
+		fmt.Printf("  %-64s %12d  %s\n", p.Address.EncodeAddress(), p.Amount, p.Label)
+	}
+	fmt.Printf("收款人数: %d, 付款总额: %d sat\n", summary.Count, summary.Total)
+	classes := make([]string, 0, len(summary.ByType))
+	for class := range summary.ByType {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		fmt.Printf("  %s: %d 笔\n", class, summary.ByType[class])
+	}
+	for _, d := range data {
+		fmt.Printf("  OP_RETURN %x  %s\n", d.Payload, d.Source)
+	}
+
+	var totalIn, totalOut int64
+	for _, in := range tx.TxIn {
+		totalIn += fetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	for _, out := range tx.TxOut {
+		totalOut += out.Value
+	}
+	fmt.Printf("输入: %d 个共 %d sat, 找零: %d sat, 手续费: %d sat\n",
+		len(tx.TxIn), totalIn, totalOut-summary.Total, totalIn-totalOut)
 }
 
-// addWitnesses 添加见证
-func addWitnesses(tx *wire.MsgTx, wif *btcutil.WIF, fetcher *txscript.MultiPrevOutFetcher) error {
-	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
-
-	for i, txIn := range tx.TxIn {
-		prevOutput := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
-		if prevOutput == nil {
-			log.Fatalf("无法获取前一笔输出: %v", txIn.PreviousOutPoint)
-		}
-		// 生成见证脚本
-		witness, err := txscript.TaprootWitnessSignature(
-			tx,
-			sigHashes,
-			i,
-			prevOutput.Value,
-			prevOutput.PkScript,
-			txscript.SigHashDefault,
-			wif.PrivKey,
-		)
+// estimateWeight 按输入的锁定脚本类型和输出估算交易重量
+func estimateWeight(tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher) (int64, error) {
+	estimator := &txsize.Estimator{}
+	for _, in := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			return 0, fmt.Errorf("无法获取前一笔输出: %v", in.PreviousOutPoint)
+		}
+		input, err := inputForPkScript(prevOut.PkScript)
 		if err != nil {
-			return err
+			return 0, err
 		}
-		tx.TxIn[i].Witness = witness
+		estimator.AddInput(input)
 	}
-	return nil
+	for _, out := range tx.TxOut {
+		estimator.AddOutput(out.PkScript)
+	}
+	return estimator.Weight(), nil
 }
 
-// serializeTransaction 序列化交易
-func serializeTransaction(tx *wire.MsgTx) (string, error) {
-	var signedTx bytes.Buffer
-	if err := tx.Serialize(&signedTx); err != nil {
-		return "", err
+// inputForPkScript 返回花费该输出的输入大小，taproot 使用非 DEFAULT 的 sighash 类型时签名多 1 字节
+func inputForPkScript(pkScript []byte) (txsize.Input, error) {
+	if txscript.IsPayToTaproot(pkScript) {
+		return txsize.TaprootKeyPathInput(sigHashType == txscript.SigHashDefault), nil
 	}
-	return hex.EncodeToString(signedTx.Bytes()), nil
+	return txsize.InputForPkScript(pkScript)
 }
 
-// broadcastTransaction 广播交易
-func broadcastTransaction(tx *wire.MsgTx) (*chainhash.Hash, error) {
-	url := os.Getenv("RPC_URL")
-	user := "user"
-	pass := "pass"
+// newPSBT 由未签名交易创建 PSBT，为本钱包的输入和找零输出填写派生信息，
+// 签名方据此找到私钥并确认找零属于同一钱包。
+// 除 taproot 外的输入都附上完整的前一笔交易：P2PKH 的签名不承诺金额，
+// SegWit v0 的签名只承诺本输入的金额，离线签名方都需要它来核对手续费。
+func newPSBT(tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher, keys signer.Keys,
+	changeKey *hdwallet.Key, fingerprint uint32, version uint32) (*psbt.Packet, error) {
+	packet, err := psbt.New(tx, version)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range packet.Inputs {
+		in := &packet.Inputs[i]
+		prevOut := fetcher.FetchPrevOutput(in.PreviousOutPoint)
+		if prevOut == nil {
+			return nil, fmt.Errorf("找不到输入 %s 的前一笔输出", in.PreviousOutPoint)
+		}
+		key, ok := keys.KeyByScript(prevOut.PkScript)
+		if !ok {
+			return nil, fmt.Errorf("输入 %s 不属于本钱包", in.PreviousOutPoint)
+		}
+		// 指定了 sighash 类型时写入 PSBT，签名方按该类型签名
+		if sigHashType != txscript.SigHashDefault {
+			if err := signer.CheckSigHashType(tx, i, sigHashType, txscript.IsPayToTaproot(prevOut.PkScript), *allowNone); err != nil {
+				return nil, fmt.Errorf("输入 %d: %w", i, err)
+			}
+			in.SighashType = sigHashType
+		}
+
+		if key.Path.Purpose == hdwallet.PurposeBIP86 {
+			in.WitnessUtxo = prevOut
+			xOnly := schnorr.SerializePubKey(key.PubKey)
+			in.TapInternalKey = xOnly
+			in.TapBip32Derivation = []psbt.TapBip32Derivation{{
+				XOnlyPubKey: xOnly,
+				Fingerprint: fingerprint,
+				Path:        key.Path.Indexes(),
+			}}
+			continue
+		}
 
-	client, err := helper.NewClient(url, user, pass)
+		// SegWit v0 签名只承诺本输入的金额，离线签名方要靠完整的前一笔交易核对金额
+		in.NonWitnessUtxo, err = getTxFromAPI(in.PreviousOutPoint.Hash.String())
+		if err != nil {
+			return nil, fmt.Errorf("获取输入 %s 的前一笔交易失败: %w", in.PreviousOutPoint, err)
+		}
+		if key.Path.Purpose != hdwallet.PurposeBIP44 {
+			in.WitnessUtxo = prevOut
+		}
+		in.RedeemScript = key.RedeemScript
+		in.Bip32Derivation = []psbt.Bip32Derivation{{
+			PubKey:      key.PubKey.SerializeCompressed(),
+			Fingerprint: fingerprint,
+			Path:        key.Path.Indexes(),
+		}}
+	}
+
+	if changeKey != nil {
+		for i := range packet.Outputs {
+			out := &packet.Outputs[i]
+			if !bytes.Equal(out.Script, changeKey.PkScript) {
+				continue
+			}
+			xOnly := schnorr.SerializePubKey(changeKey.PubKey)
+			out.TapInternalKey = xOnly
+			out.TapBip32Derivation = []psbt.TapBip32Derivation{{
+				XOnlyPubKey: xOnly,
+				Fingerprint: fingerprint,
+				Path:        changeKey.Path.Indexes(),
+			}}
+		}
+	}
+	return packet, nil
+}
+
+// getTxFromAPI 从 Mempool API 获取完整的交易
+func getTxFromAPI(txid string) (*wire.MsgTx, error) {
+	client, err := net.Client()
+	if err != nil {
+		return nil, err
+	}
+	rawTx, err := client.RawTx(txid)
 	if err != nil {
 		return nil, err
 	}
-	defer client.Shutdown()
+	raw, err := hex.DecodeString(rawTx)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
 
-	return client.SendRawTransaction(tx, false)
+// serializeTransaction 序列化交易
+func serializeTransaction(tx *wire.MsgTx) (string, error) {
+	var signedTx bytes.Buffer
+	if err := tx.Serialize(&signedTx); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(signedTx.Bytes()), nil
 }
 
-// decodeTaprootAddress 解码 Taproot 地址
-func decodeTaprootAddress(strAddr string, cfg *chaincfg.Params) ([]byte, error) {
-	taprootAddr, err := btcutil.DecodeAddress(strAddr, cfg)
+// decodeAddressScript 解码地址并返回其锁定脚本
+func decodeAddressScript(strAddr string, net *network.Network) ([]byte, error) {
+	addr, err := net.DecodeAddress(strAddr)
 	if err != nil {
 		return nil, err
 	}
-	return txscript.PayToAddrScript(taprootAddr)
+	return txscript.PayToAddrScript(addr)
 }
 
 // printTransactionDetails 打印交易详细信息
@@ -288,15 +833,81 @@ func printTransactionDetails(tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetch
 			log.Fatal("无法获取输入UTXO信息")
 		}
 		totalIn += prevOut.Value
-		fmt.Printf("  输入 %d: %s:%d, 金额: %d\n", i, in.PreviousOutPoint.Hash, in.PreviousOutPoint.Index, prevOut.Value)
+		fmt.Printf("  输入 %d: %s:%d, 金额: %d, 类型: %s\n", i, in.PreviousOutPoint.Hash, in.PreviousOutPoint.Index, prevOut.Value, txscript.GetScriptClass(prevOut.PkScript))
 	}
 
 	fmt.Println("输出:")
 	for i, out := range tx.TxOut {
 		totalOut += out.Value
-		fmt.Printf("  输出 %d: 金额: %d\n", i, out.Value)
+		if payload, ok := nullData(out.PkScript); ok {
+			fmt.Printf("  输出 %d: 金额: %d, OP_RETURN: %x\n", i, out.Value, payload)
+			continue
+		}
+		fmt.Printf("  输出 %d: 金额: %d, 类型: %s\n", i, out.Value, txscript.GetScriptClass(out.PkScript))
 	}
 
 	actualFee := totalIn - totalOut
 	fmt.Printf("总输入: %d, 总输出: %d, 手续费: %d\n", totalIn, totalOut, actualFee)
 }
+
+// nullData 返回 OP_RETURN 输出中推送的数据
+func nullData(pkScript []byte) ([]byte, bool) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+		return nil, false
+	}
+	pushes, err := txscript.PushedData(pkScript[1:])
+	if err != nil {
+		return nil, false
+	}
+	return bytes.Join(pushes, nil), true
+}
+
+// toWalletUTXOs 转换为钱包数据库中的 UTXO 记录
+func toWalletUTXOs(utxos []UTXO) []walletdb.UTXO {
+	records := make([]walletdb.UTXO, len(utxos))
+	for i, utxo := range utxos {
+		records[i] = walletdb.UTXO{
+			TxID:     utxo.TxID,
+			Vout:     utxo.Vout,
+			Value:    utxo.Amount,
+			PkScript: utxo.PkScript,
+			Path:     utxo.Path,
+			Height:   utxo.Height,
+		}
+	}
+	return records
+}
+
+// recordTransaction 把已广播的交易写入钱包数据库：标记花费的输入，记录找零输出。
+// 清空钱包时没有找零，changeKey 为 nil。
+func recordTransaction(db *walletdb.DB, tx *wire.MsgTx, fetcher *txscript.MultiPrevOutFetcher, rawTx string, changeKey *hdwallet.Key) error {
+	txid := tx.TxHash().String()
+
+	var totalIn, totalOut int64
+	outpoints := make([]string, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		outpoints[i] = in.PreviousOutPoint.String()
+		totalIn += fetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	var change []walletdb.UTXO
+	for i, out := range tx.TxOut {
+		totalOut += out.Value
+		if changeKey != nil && bytes.Equal(out.PkScript, changeKey.PkScript) {
+			change = append(change, walletdb.UTXO{
+				TxID:     txid,
+				Vout:     uint32(i),
+				Value:    out.Value,
+				PkScript: hex.EncodeToString(out.PkScript),
+				Path:     changeKey.Path,
+			})
+		}
+	}
+
+	if err := db.AddTx(walletdb.Tx{TxID: txid, Raw: rawTx, Fee: totalIn - totalOut}); err != nil {
+		return err
+	}
+	if err := db.SpendUTXOs(txid, outpoints...); err != nil {
+		return err
+	}
+	return db.AddUTXOs(change...)
+}
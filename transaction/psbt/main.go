@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/keyring"
+	"go-btc/network"
+	"go-btc/policy"
+	"go-btc/psbt"
+	"go-btc/signer"
+	"go-btc/walletdb"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	secrets   = helper.RegisterSecretFlags()
+	netOpts   = helper.RegisterNetworkFlags()
+	out       = flag.String("out", "", "输出文件，不指定时覆盖输入文件（combine 默认为 combined.psbt）")
+	binary    = flag.Bool("binary", false, "以二进制而不是 base64 格式写入 PSBT")
+	version   = flag.Uint("version", psbt.Version2, "convert 的目标版本: 0 或 2")
+	broadcast = flag.Bool("broadcast", false, "extract 后立即广播交易")
+	allowNone = flag.Bool("allow-sighash-none", false, "sign 时允许按 PSBT 的要求使用 SIGHASH_NONE（签名不承诺任何输出）")
+	assumeYes = flag.Bool("yes", false, "sign 时跳过签名前的确认")
+	dbPath    = flag.String("db", "wallet.json", "broadcast 后记录交易的钱包数据库，文件不存在时不记录")
+	watchOnly = flag.String("watch-only", "", "broadcast 时核对找零输出的观察钱包描述符文件，不指定时不记录找零")
+)
+
+const usage = `用法: psbt [参数] <命令> <文件...>
+
+命令:
+  decode    <file>           显示 PSBT 的输入、输出、手续费和签名进度
+  sign      <file>           用助记词为属于本钱包的输入签名
+  combine   <file> <file>... 合并多个签名方的 PSBT
+  finalize  <file>           为签名齐全的输入生成最终的 scriptSig 和见证
+  extract   <file>           提取可广播的交易（十六进制），-broadcast 时直接广播
+  broadcast <file>           完成签名、检查并广播交易，记录到钱包数据库
+  convert   <file>           在 PSBT v0 和 v2 之间转换
+
+参数:
+`
+
+var net *network.Network
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var err error
+	net, err = netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+
+	files := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "decode":
+		decode(read(files[0]))
+	case "sign":
+		sign(files[0])
+	case "combine":
+		combine(files)
+	case "finalize":
+		finalize(files[0])
+	case "extract":
+		extract(files[0])
+	case "broadcast":
+		broadcastPSBT(files[0])
+	case "convert":
+		packet := read(files[0])
+		if err := packet.ConvertVersion(uint32(*version)); err != nil {
+			log.Fatalf("转换失败: %v", err)
+		}
+		write(files[0], packet)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// read 读取 PSBT 文件
+func read(path string) *psbt.Packet {
+	packet, err := psbt.ReadFile(path)
+	if err != nil {
+		log.Fatalf("读取 %s 失败: %v", path, err)
+	}
+	return packet
+}
+
+// write 写入 -out 指定的文件，未指定时写回 defaultPath
+func write(defaultPath string, packet *psbt.Packet) {
+	path := *out
+	if path == "" {
+		path = defaultPath
+	}
+	if err := psbt.WriteFile(path, packet, *binary); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("PSBT 已写入", path)
+}
+
+// decode 打印 PSBT 内容
+func decode(packet *psbt.Packet) {
+	tx, err := packet.UnsignedTx()
+	if err != nil {
+		log.Fatalf("解析交易失败: %v", err)
+	}
+	fmt.Printf("PSBT v%d, 交易 %s, 版本 %d, nLockTime %d\n", packet.Version, tx.TxHash(), tx.Version, tx.LockTime)
+
+	fmt.Println("输入:")
+	for i, in := range packet.Inputs {
+		value := "未知金额"
+		if prevOut, err := in.PrevOutput(); err == nil {
+			value = fmt.Sprintf("%d sat, %s", prevOut.Value, scriptAddress(prevOut.PkScript))
+		}
+		state := "未签名"
+		switch {
+		case in.IsFinalized():
+			state = "已完成"
+		case len(in.TapKeySig) > 0 || len(in.TapScriptSigs) > 0 || len(in.PartialSigs) > 0:
+			state = fmt.Sprintf("已有 %d 个签名", len(in.PartialSigs)+len(in.TapScriptSigs)+min(len(in.TapKeySig), 1))
+		}
+		fmt.Printf("  #%d %s  %s  [%s]\n", i, in.PreviousOutPoint, value, state)
+		if in.SighashType != 0 {
+			fmt.Printf("      sighash: %s\n", signer.SigHashName(in.SighashType))
+		}
+		for _, d := range in.TapBip32Derivation {
+			fmt.Printf("      %s %s\n", hdwallet.FormatFingerprint(d.Fingerprint), formatPath(d.Path))
+		}
+		for _, d := range in.Bip32Derivation {
+			fmt.Printf("      %s %s\n", hdwallet.FormatFingerprint(d.Fingerprint), formatPath(d.Path))
+		}
+	}
+
+	fmt.Println("输出:")
+	for i, o := range packet.Outputs {
+		fmt.Printf("  #%d %d sat  %s\n", i, o.Amount, scriptAddress(o.Script))
+		for _, d := range o.TapBip32Derivation {
+			fmt.Printf("      %s %s\n", hdwallet.FormatFingerprint(d.Fingerprint), formatPath(d.Path))
+		}
+		for _, d := range o.Bip32Derivation {
+			fmt.Printf("      %s %s\n", hdwallet.FormatFingerprint(d.Fingerprint), formatPath(d.Path))
+		}
+	}
+
+	if fee, err := packet.Fee(); err == nil {
+		fmt.Printf("手续费: %d sat\n", fee)
+	}
+	fmt.Println("全部完成:", packet.IsComplete())
+}
+
+// sign 用助记词为本钱包的输入签名
+func sign(path string) {
+	packet := read(path)
+
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+
+	decode(packet)
+	s := psbt.NewSigner(deriver)
+	s.AllowSigHashNone = *allowNone
+
+	// PSBT 可能来自被攻破的联网机器，签名前用自己的密钥核对找零，再让用户确认实际付给别人的金额
+	if err := review(s, packet); err != nil {
+		log.Fatalf("PSBT 未通过检查，拒绝签名: %v", err)
+	}
+	if !*assumeYes {
+		ok, err := helper.Confirm("确认签名")
+		if err != nil {
+			log.Fatalf("读取确认失败: %v", err)
+		}
+		if !ok {
+			log.Fatal("已取消")
+		}
+	}
+
+	signed, err := s.Sign(packet)
+	if err != nil {
+		log.Fatalf("签名失败: %v", err)
+	}
+	if len(signed) == 0 {
+		log.Fatalf("没有属于指纹 %s 的输入", hdwallet.FormatFingerprint(deriver.MasterFingerprint()))
+	}
+	fmt.Printf("已签名输入: %v\n", signed)
+	write(path, packet)
+}
+
+// review 打印签名方视角的付款汇总：经过验证的找零、付给别人的金额和手续费。
+// 非 taproot 输入只有 witness UTXO 时金额无法核对，给出提示。
+func review(s *psbt.Signer, packet *psbt.Packet) error {
+	owned, err := s.CheckOutputs(packet)
+	if err != nil {
+		return err
+	}
+	change := make(map[int]hdwallet.Path, len(owned))
+	for _, o := range owned {
+		change[o.Index] = o.Path
+	}
+
+	fmt.Println("签名前核对:")
+	var external, internal int64
+	for i, o := range packet.Outputs {
+		if path, ok := change[i]; ok {
+			internal += o.Amount
+			fmt.Printf("  #%d %d sat  %s  找零，已验证 %s\n", i, o.Amount, scriptAddress(o.Script), path)
+			continue
+		}
+		external += o.Amount
+		fmt.Printf("  #%d %d sat  %s  付给他人\n", i, o.Amount, scriptAddress(o.Script))
+	}
+	fee, err := packet.Fee()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("付给他人: %d sat, 找零: %d sat, 手续费: %d sat\n", external, internal, fee)
+	if fee > policy.DefaultMaxFee {
+		fmt.Printf("警告: 手续费超过 %d sat\n", policy.DefaultMaxFee)
+	}
+
+	for i, in := range packet.Inputs {
+		prevOut, err := in.PrevOutput()
+		if err != nil {
+			return err
+		}
+		if !txscript.IsPayToTaproot(prevOut.PkScript) && in.NonWitnessUtxo == nil {
+			fmt.Printf("警告: 输入 %d 没有附带前一笔完整交易，无法核对金额 %d sat\n", i, prevOut.Value)
+		}
+	}
+	return nil
+}
+
+// combine 合并多个 PSBT
+func combine(paths []string) {
+	if len(paths) < 2 {
+		log.Fatal("combine 至少需要两个文件")
+	}
+	packets := make([]*psbt.Packet, len(paths))
+	for i, path := range paths {
+		packets[i] = read(path)
+	}
+	combined, err := psbt.Combine(packets...)
+	if err != nil {
+		log.Fatalf("合并失败: %v", err)
+	}
+	write("combined.psbt", combined)
+}
+
+// finalize 完成签名齐全的输入
+func finalize(path string) {
+	packet := read(path)
+	pending, err := packet.Finalize()
+	if err != nil {
+		log.Fatalf("完成签名失败: %v", err)
+	}
+	if len(pending) > 0 {
+		fmt.Printf("以下输入签名不足，尚未完成: %v\n", pending)
+	}
+	write(path, packet)
+}
+
+// extract 提取交易，可选直接广播
+func extract(path string) {
+	packet := read(path)
+	tx, err := packet.Extract()
+	if err != nil {
+		log.Fatalf("提取交易失败: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		log.Fatalf("序列化交易失败: %v", err)
+	}
+	rawTx := fmt.Sprintf("%x", buf.Bytes())
+	fmt.Println("Signed Transaction: ", rawTx)
+
+	// 在本地执行脚本并检查转发策略，未通过时不广播
+	fetcher, err := packet.PrevOutFetcher()
+	if err != nil {
+		log.Fatalf("检查交易失败: %v", err)
+	}
+	report := policy.Check(tx, fetcher, policy.DefaultOptions())
+	if err := report.Err(); err != nil {
+		if *broadcast {
+			log.Fatal(err)
+		}
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	if !*broadcast {
+		return
+	}
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+	txid, err := client.Broadcast(rawTx)
+	if err != nil {
+		log.Fatalf("广播交易失败: %v", err)
+	}
+	fmt.Println("Transaction Hash: ", txid)
+}
+
+// broadcastPSBT 在联网机器上完成离线签好的 PSBT：生成最终见证、检查转发策略、广播，
+// 并把交易记录到钱包数据库
+func broadcastPSBT(path string) {
+	packet := read(path)
+	// 完成签名后输入的派生信息会被清除，先按它找到钱包数据库
+	db, fingerprint, err := openWalletDB(packet)
+	if err != nil {
+		log.Fatalf("打开钱包数据库失败: %v", err)
+	}
+
+	pending, err := packet.Finalize()
+	if err != nil {
+		log.Fatalf("完成签名失败: %v", err)
+	}
+	if len(pending) > 0 {
+		log.Fatalf("以下输入签名不足，无法广播: %v", pending)
+	}
+	tx, err := packet.Extract()
+	if err != nil {
+		log.Fatalf("提取交易失败: %v", err)
+	}
+	fetcher, err := packet.PrevOutFetcher()
+	if err != nil {
+		log.Fatalf("检查交易失败: %v", err)
+	}
+	report := policy.Check(tx, fetcher, policy.DefaultOptions())
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	// 广播前确认带有本钱包派生信息的输出确实是自己的地址，对不上时不广播
+	var change []walletdb.UTXO
+	if db != nil {
+		change, err = changeUTXOs(db, fingerprint, packet, tx.TxHash().String())
+		if err != nil {
+			log.Fatalf("核对找零失败: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		log.Fatalf("序列化交易失败: %v", err)
+	}
+	rawTx := hex.EncodeToString(buf.Bytes())
+	client, err := net.Client()
+	if err != nil {
+		log.Fatalf("创建客户端失败: %v", err)
+	}
+	txid, err := client.Broadcast(rawTx)
+	if err != nil {
+		log.Fatalf("广播交易失败: %v", err)
+	}
+	fmt.Println("Transaction Hash: ", txid)
+
+	if db == nil {
+		return
+	}
+	if err := recordTransaction(db, tx, rawTx, report.Fee, change); err != nil {
+		log.Fatalf("记录交易失败: %v", err)
+	}
+	fmt.Println("交易已记录到", db.Path())
+}
+
+// openWalletDB 打开 -db 指定的钱包数据库，按 PSBT 派生信息中的主密钥指纹确认是同一个钱包。
+// 返回数据库和匹配的指纹，数据库不存在时返回 nil，不会新建。
+func openWalletDB(packet *psbt.Packet) (*walletdb.DB, uint32, error) {
+	if _, err := os.Stat(*dbPath); errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	fingerprints := make(map[uint32]bool)
+	for _, out := range packet.Outputs {
+		for _, d := range out.Bip32Derivation {
+			fingerprints[d.Fingerprint] = true
+		}
+		for _, d := range out.TapBip32Derivation {
+			fingerprints[d.Fingerprint] = true
+		}
+	}
+	for _, in := range packet.Inputs {
+		for _, d := range in.Bip32Derivation {
+			fingerprints[d.Fingerprint] = true
+		}
+		for _, d := range in.TapBip32Derivation {
+			fingerprints[d.Fingerprint] = true
+		}
+	}
+	for fingerprint := range fingerprints {
+		db, err := walletdb.Open(*dbPath, net.Name, fingerprint)
+		if errors.Is(err, walletdb.ErrWalletMismatch) {
+			continue
+		}
+		return db, fingerprint, err
+	}
+	return nil, 0, fmt.Errorf("%w: PSBT 的输入和输出都不属于 %s", walletdb.ErrWalletMismatch, *dbPath)
+}
+
+// changeUTXOs 找出带有本钱包派生信息的输出，按 -watch-only 的描述符重新派生锁定脚本核对后作为新的 UTXO。
+// PSBT 来自其他机器，派生信息不可信：路径必须是钱包数据库已分配的地址，派生出的脚本必须与输出一致，
+// 否则返回错误。没有指定 -watch-only 时不记录找零，下次扫描地址时会从链上找到。
+func changeUTXOs(db *walletdb.DB, fingerprint uint32, packet *psbt.Packet, txid string) ([]walletdb.UTXO, error) {
+	var keys keyring.Source
+	if *watchOnly != "" {
+		wallet, err := keyring.LoadWatchOnly(*watchOnly, net.Params)
+		if err != nil {
+			return nil, fmt.Errorf("加载观察钱包失败: %w", err)
+		}
+		if wallet.MasterFingerprint() != fingerprint {
+			return nil, fmt.Errorf("观察钱包的主密钥指纹 %s 与钱包数据库不一致", hdwallet.FormatFingerprint(wallet.MasterFingerprint()))
+		}
+		keys = wallet
+	}
+
+	var change []walletdb.UTXO
+	for i, out := range packet.Outputs {
+		var indexes []uint32
+		for _, d := range out.TapBip32Derivation {
+			if d.Fingerprint == fingerprint && len(d.LeafHashes) == 0 {
+				indexes = d.Path
+			}
+		}
+		for _, d := range out.Bip32Derivation {
+			if d.Fingerprint == fingerprint {
+				indexes = d.Path
+			}
+		}
+		if indexes == nil {
+			continue
+		}
+		path, err := hdwallet.PathFromIndexes(indexes)
+		if err != nil {
+			return nil, fmt.Errorf("输出 %d: %w", i, err)
+		}
+		if keys == nil {
+			fmt.Printf("输出 %d 声称是找零 (%s)，没有指定 -watch-only，无法核对，不记录\n", i, path)
+			continue
+		}
+		if path.Index >= db.NextIndex(path.Purpose, path.CoinType, path.Account, path.Change) {
+			return nil, fmt.Errorf("输出 %d: 路径 %s 不是钱包数据库分配过的地址", i, path)
+		}
+		key, err := keys.Derive(path)
+		if err != nil {
+			return nil, fmt.Errorf("输出 %d: %w", i, err)
+		}
+		if !bytes.Equal(key.PkScript, out.Script) {
+			return nil, fmt.Errorf("输出 %d 声称是本钱包 %s 的地址 %s，但锁定脚本不一致", i, path, key.Address)
+		}
+		change = append(change, walletdb.UTXO{
+			TxID:     txid,
+			Vout:     uint32(i),
+			Value:    out.Amount,
+			PkScript: hex.EncodeToString(out.Script),
+			Path:     path,
+		})
+	}
+	return change, nil
+}
+
+// recordTransaction 记录交易，标记花费的输入，并把核对过的找零记为新的 UTXO
+func recordTransaction(db *walletdb.DB, tx *wire.MsgTx, rawTx string, fee int64, change []walletdb.UTXO) error {
+	txid := tx.TxHash().String()
+
+	outpoints := make([]string, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		outpoints[i] = in.PreviousOutPoint.String()
+	}
+	if err := db.AddTx(walletdb.Tx{TxID: txid, Raw: rawTx, Fee: fee}); err != nil {
+		return err
+	}
+	if err := db.SpendUTXOs(txid, outpoints...); err != nil {
+		return err
+	}
+	return db.AddUTXOs(change...)
+}
+
+// scriptAddress 返回锁定脚本对应的地址，无法识别时返回脚本类型
+func scriptAddress(pkScript []byte) string {
+	class, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, net.Params)
+	if err != nil || len(addrs) != 1 {
+		return class.String()
+	}
+	return addrs[0].EncodeAddress()
+}
+
+// formatPath 格式化任意长度的 BIP-32 路径
+func formatPath(path []uint32) string {
+	s := "m"
+	for _, index := range path {
+		if index >= 0x80000000 {
+			s += fmt.Sprintf("/%d'", index-0x80000000)
+		} else {
+			s += fmt.Sprintf("/%d", index)
+		}
+	}
+	return s
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"go-btc/coinselect"
+	"go-btc/esplora"
+	"go-btc/hdwallet"
+	"go-btc/helper"
+	"go-btc/network"
+	"go-btc/policy"
+	"go-btc/timelock"
+	"go-btc/txsize"
+	"go-btc/walletdb"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+var (
+	secrets      = helper.RegisterSecretFlags()
+	netOpts      = helper.RegisterNetworkFlags()
+	scriptType   = flag.String("type", string(timelock.P2TR), "时间锁输出的脚本类型: p2tr 或 p2wsh")
+	after        = flag.Uint("after", 0, "绝对时间锁：到达该区块高度（小于 500000000）或 unix 时间后才能花费")
+	older        = flag.Uint("older", 0, "相对时间锁：输出确认该数量的区块后才能花费")
+	olderSeconds = flag.Uint("older-seconds", 0, "相对时间锁：输出确认该秒数后才能花费，按 512 秒向上取整")
+	label        = flag.String("label", "", "address 时为时间锁地址添加标签")
+	to           = flag.String("to", "", "spend 的收款地址，不指定时发送到本钱包的下一个找零地址")
+	feeRate      = flag.Int64("fee-rate", 0, "spend 的费率 (sat/vB)，为 0 时使用 mempool.space 推荐的最快费率")
+	dustRelayFee = flag.Int64("dust-relay-fee", policy.DefaultDustRelayFeeRate, "计算粉尘阈值使用的费率 (sat/vB)")
+	dbPath       = flag.String("db", "wallet.json", "钱包数据库文件")
+	assumeYes    = flag.Bool("yes", false, "跳过广播前的确认")
+)
+
+const usage = `用法: timelock [参数] <命令>
+
+命令:
+  address  创建时间锁地址，发送到该地址的币在 -after 或 -older 指定的时间之前无法花费
+  list     列出钱包创建的时间锁地址、余额以及是否已经可以花费
+  spend    花费所有已到期的时间锁输出，自动设置所需的 nLockTime 和 nSequence
+
+参数:
+`
+
+// wallet 各命令共用的网络、派生器、数据库和 API 客户端
+type wallet struct {
+	net     *network.Network
+	deriver *hdwallet.Deriver
+	db      *walletdb.DB
+	client  *esplora.Client
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var run func(*wallet)
+	switch flag.Arg(0) {
+	case "address":
+		run = newAddress
+	case "list":
+		run = list
+	case "spend":
+		run = spend
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	run(openWallet())
+}
+
+// openWallet 解析网络、加载助记词并打开钱包数据库
+func openWallet() *wallet {
+	net, err := netOpts.Network()
+	if err != nil {
+		log.Fatalf("解析网络失败: %v", err)
+	}
+	mnemonic, passphrase, err := secrets.Load()
+	if err != nil {
+		log.Fatalf("获取助记词失败: %v", err)
+	}
+	deriver, err := hdwallet.NewDeriverFromMnemonic(mnemonic, passphrase, net.Params)
+	if err != nil {
+		log.Fatalf("创建派生器失败: %v", err)
+	}
+	db, err := walletdb.Open(*dbPath, net.Name, deriver.MasterFingerprint())
+	if err != nil {
+		log.Fatalf("打开钱包数据库失败: %v", err)
+	}
+	w := &wallet{net: net, deriver: deriver, db: db}
+	if flag.Arg(0) != "address" {
+		if w.client, err = net.Client(); err != nil {
+			log.Fatalf("创建客户端失败: %v", err)
+		}
+	}
+	return w
+}
+
+// parseLock 按 -after、-older 或 -older-seconds 创建时间锁，三者只能指定一个
+func parseLock() (timelock.Lock, error) {
+	set := 0
+	for _, v := range []uint{*after, *older, *olderSeconds} {
+		if v != 0 {
+			set++
+		}
+	}
+	if set != 1 {
+		return timelock.Lock{}, fmt.Errorf("请指定 -after、-older 或 -older-seconds 中的一个")
+	}
+	switch {
+	case *after >= uint(timelock.LockTimeThreshold):
+		return timelock.AfterTime(uint32(*after))
+	case *after != 0:
+		return timelock.AfterHeight(uint32(*after))
+	case *older != 0:
+		return timelock.OlderBlocks(uint32(*older))
+	default:
+		return timelock.OlderSeconds(uint32(*olderSeconds))
+	}
+}
+
+// newAddress 分配新的签名密钥并创建时间锁地址。
+// P2WSH 使用 BIP-84 收款链上的密钥，taproot 使用 BIP-86 收款链上的密钥。
+func newAddress(w *wallet) {
+	lock, err := parseLock()
+	if err != nil {
+		log.Fatal(err)
+	}
+	typ := timelock.ScriptType(*scriptType)
+	purpose := hdwallet.PurposeBIP86
+	switch typ {
+	case timelock.P2TR:
+	case timelock.P2WSH:
+		purpose = hdwallet.PurposeBIP84
+	default:
+		log.Fatalf("未知的脚本类型 %q，可选 p2tr、p2wsh", *scriptType)
+	}
+
+	path, err := w.db.Issue(purpose, w.net.CoinType(), 0, hdwallet.ChainExternal, "时间锁: "+lock.String())
+	if err != nil {
+		log.Fatalf("分配密钥失败: %v", err)
+	}
+	key, err := w.deriver.Derive(path)
+	if err != nil {
+		log.Fatalf("派生密钥失败: %v", err)
+	}
+	out, err := timelock.NewOutput(lock, typ, key.PubKey, w.net.Params)
+	if err != nil {
+		log.Fatalf("创建时间锁输出失败: %v", err)
+	}
+	if err := w.db.AddLock(walletdb.Lock{
+		Address: out.Address.EncodeAddress(),
+		Kind:    string(lock.Kind),
+		Value:   lock.Value,
+		Type:    string(typ),
+		Path:    path,
+		Label:   *label,
+	}); err != nil {
+		log.Fatalf("记录时间锁失败: %v", err)
+	}
+
+	script, _ := txscript.DisasmString(out.Script)
+	fmt.Println("时间锁地址:", out.Address.EncodeAddress())
+	fmt.Println("解锁条件:", lock)
+	fmt.Println("签名密钥:", path)
+	fmt.Println("脚本:", script)
+	if lock.Kind == timelock.Older {
+		fmt.Println("注意: 相对时间锁从每个输出被确认时开始计算")
+	}
+}
+
+// lockedOutput 时间锁地址上的一个 UTXO
+type lockedOutput struct {
+	record   walletdb.Lock
+	output   *timelock.Output
+	key      *hdwallet.Key
+	outPoint wire.OutPoint
+	prevOut  *wire.TxOut
+	height   int64 // 所在区块高度，未确认为 0
+}
+
+// restore 按数据库记录重新构造时间锁输出，并确认得到的地址与记录一致
+func restore(w *wallet, record walletdb.Lock) (*timelock.Output, *hdwallet.Key, error) {
+	key, err := w.deriver.Derive(record.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	lock := timelock.Lock{Kind: timelock.Kind(record.Kind), Value: record.Value}
+	out, err := timelock.NewOutput(lock, timelock.ScriptType(record.Type), key.PubKey, w.net.Params)
+	if err != nil {
+		return nil, nil, err
+	}
+	if out.Address.EncodeAddress() != record.Address {
+		return nil, nil, fmt.Errorf("时间锁 %s 重新构造出的地址为 %s，数据库记录可能已损坏", record.Address, out.Address.EncodeAddress())
+	}
+	return out, key, nil
+}
+
+// lockedOutputs 查询所有时间锁地址上的 UTXO
+func lockedOutputs(w *wallet) ([]lockedOutput, error) {
+	var outputs []lockedOutput
+	for _, record := range w.db.Locks() {
+		out, key, err := restore(w, record)
+		if err != nil {
+			return nil, err
+		}
+		utxos, err := w.client.UTXOs(record.Address)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range utxos {
+			hash, err := chainhash.NewHashFromStr(u.TxID)
+			if err != nil {
+				return nil, err
+			}
+			outputs = append(outputs, lockedOutput{
+				record:   record,
+				output:   out,
+				key:      key,
+				outPoint: wire.OutPoint{Hash: *hash, Index: u.Vout},
+				prevOut:  wire.NewTxOut(u.Value, out.PkScript),
+				height:   u.Status.BlockHeight,
+			})
+		}
+	}
+	return outputs, nil
+}
+
+// list 列出时间锁地址和其中的 UTXO
+func list(w *wallet) {
+	tip, err := w.client.TipHeight()
+	if err != nil {
+		log.Fatalf("获取区块高度失败: %v", err)
+	}
+	outputs, err := lockedOutputs(w)
+	if err != nil {
+		log.Fatalf("获取时间锁输出失败: %v", err)
+	}
+	fmt.Printf("当前区块高度: %d\n", tip)
+	for _, record := range w.db.Locks() {
+		lock := timelock.Lock{Kind: timelock.Kind(record.Kind), Value: record.Value}
+		fmt.Printf("%s  %s  %s  %s\n", record.Address, record.Type, lock, record.Label)
+		for _, o := range outputs {
+			if o.record.Address != record.Address {
+				continue
+			}
+			status := "未到期"
+			if o.output.Mature(tip, o.height) {
+				status = "可花费"
+			}
+			fmt.Printf("  %s  %d sat  %s\n", o.outPoint, o.prevOut.Value, status)
+		}
+	}
+}
+
+// spend 把所有已到期的时间锁输出发送到一个地址
+func spend(w *wallet) {
+	tip, err := w.client.TipHeight()
+	if err != nil {
+		log.Fatalf("获取区块高度失败: %v", err)
+	}
+	outputs, err := lockedOutputs(w)
+	if err != nil {
+		log.Fatalf("获取时间锁输出失败: %v", err)
+	}
+	var inputs []lockedOutput
+	for _, o := range outputs {
+		if o.output.Mature(tip, o.height) {
+			inputs = append(inputs, o)
+		} else {
+			fmt.Printf("跳过 %s: %s，尚未到期\n", o.outPoint, o.output.Lock)
+		}
+	}
+	if len(inputs) == 0 {
+		log.Fatal("没有可以花费的时间锁输出")
+	}
+
+	// 收款地址，未指定时使用下一个未分配的找零地址，确定广播时再分配，取消或失败时不浪费索引
+	var changeKey *hdwallet.Key
+	var pkScript []byte
+	if *to != "" {
+		addr, err := w.net.DecodeAddress(*to)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pkScript, _ = txscript.PayToAddrScript(addr)
+	} else {
+		changeKey, err = w.deriver.Derive(hdwallet.Path{
+			Purpose:  hdwallet.PurposeBIP86,
+			CoinType: w.net.CoinType(),
+			Change:   hdwallet.ChainInternal,
+			Index:    w.db.NextIndex(hdwallet.PurposeBIP86, w.net.CoinType(), 0, hdwallet.ChainInternal),
+		})
+		if err != nil {
+			log.Fatalf("派生找零地址失败: %v", err)
+		}
+		pkScript = changeKey.PkScript
+	}
+
+	rate := *feeRate
+	if rate == 0 {
+		rates, err := w.client.FeeRates()
+		if err != nil {
+			log.Fatalf("获取推荐费率失败: %v", err)
+		}
+		rate = rates.FastestFee
+	}
+
+	tx, fetcher, err := buildSpend(inputs, pkScript, tip, rate)
+	if err != nil {
+		log.Fatalf("创建交易失败: %v", err)
+	}
+	fee := txFee(tx, fetcher)
+	fmt.Printf("花费 %d 个时间锁输出, nLockTime: %d, 发送 %d sat, 手续费 %d sat\n",
+		len(tx.TxIn), tx.LockTime, tx.TxOut[0].Value, fee)
+	if !*assumeYes {
+		ok, err := helper.Confirm("确认签名并广播")
+		if err != nil {
+			log.Fatalf("读取确认失败: %v", err)
+		}
+		if !ok {
+			log.Fatal("已取消")
+		}
+	}
+
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, in := range inputs {
+		witness, err := in.output.Witness(tx, i, sigHashes, in.prevOut, in.key.PrivKey)
+		if err != nil {
+			log.Fatalf("输入 %d 签名失败: %v", i, err)
+		}
+		tx.TxIn[i].Witness = witness
+	}
+
+	// 脚本引擎会检查 nLockTime / nSequence 是否满足 CLTV / CSV
+	opts := policy.DefaultOptions()
+	opts.DustRelayFeeRate = *dustRelayFee
+	report := policy.Check(tx, fetcher, opts)
+	if err := report.Err(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("广播前检查通过:", report)
+
+	// 广播前分配找零地址，其他进程已分配了这个索引时不广播
+	if changeKey != nil {
+		if err := w.db.Reserve(changeKey.Path, "时间锁到期"); err != nil {
+			log.Fatalf("分配找零地址失败: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		log.Fatalf("序列化交易失败: %v", err)
+	}
+	rawTx := hex.EncodeToString(buf.Bytes())
+	fmt.Println("Signed Transaction: ", rawTx)
+	txid, err := w.client.Broadcast(rawTx)
+	if err != nil {
+		log.Fatalf("广播交易失败: %v", err)
+	}
+	fmt.Println("Transaction Hash: ", txid)
+
+	if err := w.db.AddTx(walletdb.Tx{TxID: txid, Raw: rawTx, Fee: fee, Label: "花费时间锁"}); err != nil {
+		log.Fatalf("记录交易失败: %v", err)
+	}
+	if changeKey != nil {
+		if err := w.db.AddUTXOs(walletdb.UTXO{
+			TxID:     txid,
+			Value:    tx.TxOut[0].Value,
+			PkScript: hex.EncodeToString(pkScript),
+			Path:     changeKey.Path,
+		}); err != nil {
+			log.Fatalf("记录UTXO失败: %v", err)
+		}
+	}
+}
+
+// buildSpend 创建花费时间锁输出的交易，手续费从唯一的输出中扣除。
+// nLockTime 默认为防手续费狙击的当前高度，绝对时间锁要求更大时取锁定值；
+// 按时间计算的绝对时间锁不能与高度混用，此时 nLockTime 从 0 开始。
+func buildSpend(inputs []lockedOutput, pkScript []byte, tip, feeRate int64) (*wire.MsgTx, *txscript.MultiPrevOutFetcher, error) {
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = timelock.AntiFeeSniping(tip)
+	for _, in := range inputs {
+		if lock := in.output.Lock; lock.Kind == timelock.After && !lock.IsHeight() {
+			tx.LockTime = 0
+		}
+	}
+
+	fetcher := txscript.NewMultiPrevOutFetcher(nil)
+	estimator := &txsize.Estimator{}
+	var total int64
+	for i, in := range inputs {
+		txIn := wire.NewTxIn(&in.outPoint, nil, nil)
+		txIn.Sequence = policy.MaxRBFSequence
+		tx.AddTxIn(txIn)
+		if err := in.output.PrepareSpend(tx, i); err != nil {
+			return nil, nil, err
+		}
+		fetcher.AddPrevOut(in.outPoint, in.prevOut)
+		estimator.AddInput(in.output.Input())
+		total += in.prevOut.Value
+	}
+	estimator.AddOutput(pkScript)
+
+	fee := coinselect.Fee(estimator.Weight(), feeRate)
+	out := wire.NewTxOut(total-fee, pkScript)
+	if policy.IsDust(out, *dustRelayFee) {
+		return nil, nil, fmt.Errorf("扣除手续费 %d sat 后只剩 %d sat，低于粉尘阈值", fee, out.Value)
+	}
+	tx.AddTxOut(out)
+	return tx, fetcher, nil
+}
+
+// txFee 返回交易的手续费
+func txFee(tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) int64 {
+	var fee int64
+	for _, in := range tx.TxIn {
+		fee += fetcher.FetchPrevOutput(in.PreviousOutPoint).Value
+	}
+	for _, out := range tx.TxOut {
+		fee -= out.Value
+	}
+	return fee
+}
@@ -0,0 +1,260 @@
+// Package txsize 按重量单位 (WU) 估算交易大小。
+//
+// 非见证数据每字节 4 WU，见证数据每字节 1 WU，虚拟大小 vsize = ceil(weight / 4)。
+// 签名按最大长度估算：ECDSA 签名 DER 编码最长 71 字节（low-S）加 1 字节 sighash 类型，
+// schnorr 签名 64 字节，非默认 sighash 类型时 65 字节，所以估算值不会小于实际值。
+package txsize
+
+import (
+	"fmt"
+
+	"go-btc/hdwallet"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+const (
+	// WitnessScaleFactor 非见证数据相对见证数据的权重
+	WitnessScaleFactor = 4
+
+	// ECDSASigSize DER 编码的 ECDSA 签名加 sighash 类型的最大长度
+	ECDSASigSize = 72
+	// SchnorrSigSize 使用 SigHashDefault 的 schnorr 签名长度
+	SchnorrSigSize = 64
+	// CompressedPubKeySize 压缩公钥长度
+	CompressedPubKeySize = 33
+
+	// 各类锁定脚本的长度
+	P2PKHScriptSize  = 25 // OP_DUP OP_HASH160 <20> OP_EQUALVERIFY OP_CHECKSIG
+	P2SHScriptSize   = 23 // OP_HASH160 <20> OP_EQUAL
+	P2WPKHScriptSize = 22 // OP_0 <20>
+	P2WSHScriptSize  = 34 // OP_0 <32>
+	P2TRScriptSize   = 34 // OP_1 <32>
+
+	// outpointSize 前一笔输出的交易哈希和索引
+	outpointSize = 32 + 4
+	// sequenceSize nSequence
+	sequenceSize = 4
+)
+
+// Input 一个输入的大小：scriptSig 长度和见证中每个元素的长度
+type Input struct {
+	ScriptSigSize int
+	Witness       []int // 每个见证元素的长度，nil 表示非见证输入
+}
+
+// P2PKHInput 花费 P2PKH 输出：scriptSig 为 <sig> <pubkey>
+func P2PKHInput() Input {
+	return Input{ScriptSigSize: 1 + ECDSASigSize + 1 + CompressedPubKeySize}
+}
+
+// NestedP2WPKHInput 花费 P2SH-P2WPKH 输出：scriptSig 只推入 22 字节的赎回脚本，见证为 <sig> <pubkey>
+func NestedP2WPKHInput() Input {
+	return Input{
+		ScriptSigSize: 1 + P2WPKHScriptSize,
+		Witness:       []int{ECDSASigSize, CompressedPubKeySize},
+	}
+}
+
+// P2WPKHInput 花费 P2WPKH 输出：见证为 <sig> <pubkey>
+func P2WPKHInput() Input {
+	return Input{Witness: []int{ECDSASigSize, CompressedPubKeySize}}
+}
+
+// MultisigScriptSize 返回 m-of-n 多签脚本的长度：OP_m <pubkey>... OP_n OP_CHECKMULTISIG
+func MultisigScriptSize(n int) int {
+	return 1 + n*(1+CompressedPubKeySize) + 1 + 1
+}
+
+// P2WSHMultisigInput 花费 m-of-n P2WSH 多签输出：
+// 见证为 OP_CHECKMULTISIG 需要的空元素、m 个签名和见证脚本
+func P2WSHMultisigInput(m, n int) Input {
+	witness := []int{0}
+	for i := 0; i < m; i++ {
+		witness = append(witness, ECDSASigSize)
+	}
+	witness = append(witness, MultisigScriptSize(n))
+	return Input{Witness: witness}
+}
+
+// P2WSHInput 花费任意 P2WSH 输出：stack 为见证脚本之前的元素长度
+func P2WSHInput(stack []int, witnessScriptSize int) Input {
+	witness := append(append([]int(nil), stack...), witnessScriptSize)
+	return Input{Witness: witness}
+}
+
+// TaprootKeyPathInput 花费 P2TR 输出的密钥路径：见证只有一个 schnorr 签名，
+// 使用非默认 sighash 类型时签名多 1 字节
+func TaprootKeyPathInput(sigHashDefault bool) Input {
+	size := SchnorrSigSize
+	if !sigHashDefault {
+		size++
+	}
+	return Input{Witness: []int{size}}
+}
+
+// ControlBlockSize 返回深度为 depth 的脚本树叶子对应的控制块长度
+func ControlBlockSize(depth int) int {
+	return 33 + 32*depth
+}
+
+// TaprootScriptPathInput 花费 P2TR 输出的脚本路径：见证为脚本需要的元素、叶子脚本和控制块
+func TaprootScriptPathInput(stack []int, leafScriptSize, depth int) Input {
+	witness := append(append([]int(nil), stack...), leafScriptSize, ControlBlockSize(depth))
+	return Input{Witness: witness}
+}
+
+// InputForPurpose 返回钱包各地址类型的输入大小
+func InputForPurpose(purpose hdwallet.Purpose) (Input, error) {
+	switch purpose {
+	case hdwallet.PurposeBIP44:
+		return P2PKHInput(), nil
+	case hdwallet.PurposeBIP49:
+		return NestedP2WPKHInput(), nil
+	case hdwallet.PurposeBIP84:
+		return P2WPKHInput(), nil
+	case hdwallet.PurposeBIP86:
+		return TaprootKeyPathInput(true), nil
+	default:
+		return Input{}, fmt.Errorf("不支持的 purpose: %d", uint32(purpose))
+	}
+}
+
+// InputForPkScript 根据锁定脚本推断输入大小。P2SH 按 P2SH-P2WPKH 估算，
+// P2TR 按密钥路径估算；P2WSH 需要知道见证脚本，请使用 P2WSHInput 或 P2WSHMultisigInput。
+func InputForPkScript(pkScript []byte) (Input, error) {
+	switch txscript.GetScriptClass(pkScript) {
+	case txscript.PubKeyHashTy:
+		return P2PKHInput(), nil
+	case txscript.ScriptHashTy:
+		return NestedP2WPKHInput(), nil
+	case txscript.WitnessV0PubKeyHashTy:
+		return P2WPKHInput(), nil
+	case txscript.WitnessV1TaprootTy:
+		return TaprootKeyPathInput(true), nil
+	default:
+		return Input{}, fmt.Errorf("无法估算锁定脚本 %x 的输入大小", pkScript)
+	}
+}
+
+// HasWitness 输入是否带有见证数据
+func (in Input) HasWitness() bool {
+	return in.Witness != nil
+}
+
+// WitnessSize 返回见证数据的字节数：元素个数加上每个元素的长度前缀和内容
+func (in Input) WitnessSize() int {
+	if !in.HasWitness() {
+		return 0
+	}
+	size := wire.VarIntSerializeSize(uint64(len(in.Witness)))
+	for _, item := range in.Witness {
+		size += wire.VarIntSerializeSize(uint64(item)) + item
+	}
+	return size
+}
+
+// BaseSize 返回输入的非见证部分字节数
+func (in Input) BaseSize() int {
+	return outpointSize + wire.VarIntSerializeSize(uint64(in.ScriptSigSize)) + in.ScriptSigSize + sequenceSize
+}
+
+// Weight 返回输入的重量，包括见证数据
+func (in Input) Weight() int64 {
+	return int64(in.BaseSize()*WitnessScaleFactor + in.WitnessSize())
+}
+
+// OutputWeight 返回输出的重量：金额 8 字节、脚本长度和锁定脚本
+func OutputWeight(pkScript []byte) int64 {
+	return outputWeight(len(pkScript))
+}
+
+func outputWeight(scriptSize int) int64 {
+	return int64((8 + wire.VarIntSerializeSize(uint64(scriptSize)) + scriptSize) * WitnessScaleFactor)
+}
+
+// OverheadWeight 返回交易固定部分的重量：版本、锁定时间、输入输出计数，
+// 隔离见证交易另有 2 字节的标记和标志
+func OverheadWeight(numInputs, numOutputs int, segwit bool) int64 {
+	size := 4 + 4 + wire.VarIntSerializeSize(uint64(numInputs)) + wire.VarIntSerializeSize(uint64(numOutputs))
+	weight := int64(size * WitnessScaleFactor)
+	if segwit {
+		weight += 2
+	}
+	return weight
+}
+
+// VSize 把重量换算为虚拟大小，向上取整
+func VSize(weight int64) int64 {
+	return (weight + WitnessScaleFactor - 1) / WitnessScaleFactor
+}
+
+// Estimator 累加输入和输出，估算整笔交易的重量
+type Estimator struct {
+	inputs      []Input
+	outputSizes []int
+}
+
+// AddInput 添加输入
+func (e *Estimator) AddInput(in Input) *Estimator {
+	e.inputs = append(e.inputs, in)
+	return e
+}
+
+// AddOutput 添加输出
+func (e *Estimator) AddOutput(pkScript []byte) *Estimator {
+	e.outputSizes = append(e.outputSizes, len(pkScript))
+	return e
+}
+
+// AddOutputSize 按锁定脚本长度添加输出，例如 P2TRScriptSize
+func (e *Estimator) AddOutputSize(scriptSize int) *Estimator {
+	e.outputSizes = append(e.outputSizes, scriptSize)
+	return e
+}
+
+// Weight 返回估算的交易重量。只要有一个见证输入，整笔交易就按隔离见证格式序列化，
+// 此时非见证输入也要写入 1 字节的空见证。
+func (e *Estimator) Weight() int64 {
+	segwit := false
+	for _, in := range e.inputs {
+		if in.HasWitness() {
+			segwit = true
+		}
+	}
+
+	weight := OverheadWeight(len(e.inputs), len(e.outputSizes), segwit)
+	for _, in := range e.inputs {
+		weight += in.Weight()
+		if segwit && !in.HasWitness() {
+			weight++
+		}
+	}
+	for _, size := range e.outputSizes {
+		weight += outputWeight(size)
+	}
+	return weight
+}
+
+// VSize 返回估算的虚拟大小
+func (e *Estimator) VSize() int64 {
+	return VSize(e.Weight())
+}
+
+// Fee 返回按费率 (sat/vB) 估算的手续费
+func (e *Estimator) Fee(feeRate int64) int64 {
+	return e.VSize() * feeRate
+}
+
+// TxWeight 返回已签名交易的实际重量
+func TxWeight(tx *wire.MsgTx) int64 {
+	base := tx.SerializeSizeStripped()
+	total := tx.SerializeSize()
+	return int64(base*(WitnessScaleFactor-1) + total)
+}
+
+// TxVSize 返回已签名交易的实际虚拟大小
+func TxVSize(tx *wire.MsgTx) int64 {
+	return VSize(TxWeight(tx))
+}
@@ -0,0 +1,194 @@
+package txsize_test
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+
+	"go-btc/hdwallet"
+	"go-btc/signer"
+	"go-btc/timelock"
+	"go-btc/txsize"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// ecdsaSlack 每个 ECDSA 签名估算值最多比实际多出的字节数：
+// 估算按 72 字节，low-S 签名的 r 不超过 32 字节时为 71 字节，r 或 s 首字节为 0 时再短 1 字节
+const ecdsaSlack = txsize.ECDSASigSize - 70
+
+// spend 一个待签名的输入：前一笔输出的锁定脚本、估算大小和签名方法
+type spend struct {
+	name     string
+	pkScript []byte
+	size     txsize.Input
+	slack    int64 // 估算值允许超出实际值的权重
+	prepare  func(tx *wire.MsgTx, i int) error
+	sign     func(tx *wire.MsgTx, i int, sigHashes *txscript.TxSigHashes, prevOut *wire.TxOut) error
+}
+
+func walletSpend(t *testing.T, d *hdwallet.Deriver, purpose hdwallet.Purpose, hashType txscript.SigHashType) spend {
+	t.Helper()
+	key, err := d.Derive(hdwallet.Path{Purpose: purpose, CoinType: 1, Change: hdwallet.ChainExternal})
+	if err != nil {
+		t.Fatalf("派生 %s 密钥失败: %v", purpose, err)
+	}
+	size, err := txsize.InputForPurpose(purpose)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := spend{name: purpose.String(), pkScript: key.PkScript, size: size}
+	switch purpose {
+	case hdwallet.PurposeBIP44:
+		// 签名在 scriptSig 中，按非见证数据计算权重
+		s.slack = ecdsaSlack * txsize.WitnessScaleFactor
+	case hdwallet.PurposeBIP49, hdwallet.PurposeBIP84:
+		s.slack = ecdsaSlack
+	case hdwallet.PurposeBIP86:
+		if hashType != txscript.SigHashDefault {
+			s.name += "/" + signer.SigHashName(hashType)
+			s.size = txsize.TaprootKeyPathInput(false)
+		}
+	}
+	if hashType == txscript.SigHashDefault && purpose != hdwallet.PurposeBIP86 {
+		hashType = txscript.SigHashAll
+	}
+	s.sign = func(tx *wire.MsgTx, i int, sigHashes *txscript.TxSigHashes, prevOut *wire.TxOut) error {
+		return signer.SignInput(tx, i, sigHashes, prevOut, key, hashType)
+	}
+	return s
+}
+
+func timelockSpend(t *testing.T, d *hdwallet.Deriver, scriptType timelock.ScriptType, lock timelock.Lock) spend {
+	t.Helper()
+	key, err := d.Derive(hdwallet.Path{Purpose: hdwallet.PurposeBIP86, CoinType: 1, Change: hdwallet.ChainExternal, Index: 1})
+	if err != nil {
+		t.Fatalf("派生密钥失败: %v", err)
+	}
+	out, err := timelock.NewOutput(lock, scriptType, key.PubKey, &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatalf("构造时间锁输出失败: %v", err)
+	}
+	s := spend{name: string(scriptType) + " " + lock.String(), pkScript: out.PkScript, size: out.Input()}
+	if scriptType == timelock.P2WSH {
+		s.slack = ecdsaSlack
+	}
+	s.sign = func(tx *wire.MsgTx, i int, sigHashes *txscript.TxSigHashes, prevOut *wire.TxOut) error {
+		witness, err := out.Witness(tx, i, sigHashes, prevOut, key.PrivKey)
+		if err != nil {
+			return err
+		}
+		tx.TxIn[i].Witness = witness
+		return nil
+	}
+	s.prepare = out.PrepareSpend
+	return s
+}
+
+// 签名后的交易权重不超过估算值，且只比估算值少 ECDSA 签名长度的浮动
+func TestEstimatorSignedWeight(t *testing.T) {
+	d, err := hdwallet.NewDeriverFromMnemonic(testMnemonic, "", &chaincfg.TestNet3Params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := timelock.AfterHeight(2_500_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older, err := timelock.OlderBlocks(144)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p2pkh := walletSpend(t, d, hdwallet.PurposeBIP44, txscript.SigHashDefault)
+	nested := walletSpend(t, d, hdwallet.PurposeBIP49, txscript.SigHashDefault)
+	p2wpkh := walletSpend(t, d, hdwallet.PurposeBIP84, txscript.SigHashDefault)
+	p2tr := walletSpend(t, d, hdwallet.PurposeBIP86, txscript.SigHashDefault)
+	p2trAll := walletSpend(t, d, hdwallet.PurposeBIP86, txscript.SigHashAll)
+	wshAfter := timelockSpend(t, d, timelock.P2WSH, after)
+	wshOlder := timelockSpend(t, d, timelock.P2WSH, older)
+	trAfter := timelockSpend(t, d, timelock.P2TR, after)
+	trOlder := timelockSpend(t, d, timelock.P2TR, older)
+
+	tests := []struct {
+		name   string
+		spends []spend
+	}{
+		{"p2pkh", []spend{p2pkh}},
+		{"p2sh-p2wpkh", []spend{nested}},
+		{"p2wpkh", []spend{p2wpkh}},
+		{"p2tr", []spend{p2tr}},
+		{"p2tr SIGHASH_ALL", []spend{p2trAll}},
+		{"p2wsh after", []spend{wshAfter}},
+		{"p2wsh older", []spend{wshOlder}},
+		{"p2tr after", []spend{trAfter}},
+		{"p2tr older", []spend{trOlder}},
+		{"混合输入", []spend{p2pkh, nested, p2wpkh, p2tr, p2trAll, wshAfter, trOlder}},
+		{"多个 p2pkh", []spend{p2pkh, p2pkh, p2pkh}},
+	}
+	outputs := [][]byte{p2wpkh.pkScript, p2tr.pkScript, p2pkh.pkScript}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := wire.NewMsgTx(2)
+			fetcher := txscript.NewMultiPrevOutFetcher(nil)
+			var est txsize.Estimator
+			var slack int64
+			for i, s := range tt.spends {
+				op := wire.OutPoint{Hash: chainhash.DoubleHashH([]byte(tt.name)), Index: uint32(i)}
+				tx.AddTxIn(wire.NewTxIn(&op, nil, nil))
+				fetcher.AddPrevOut(op, wire.NewTxOut(100_000, s.pkScript))
+				est.AddInput(s.size)
+				slack += s.slack
+			}
+			for _, pkScript := range outputs {
+				tx.AddTxOut(wire.NewTxOut(10_000, pkScript))
+				est.AddOutput(pkScript)
+			}
+			for i, s := range tt.spends {
+				if s.prepare != nil {
+					if err := s.prepare(tx, i); err != nil {
+						t.Fatalf("输入 %d (%s): %v", i, s.name, err)
+					}
+				}
+			}
+
+			sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+			for i, s := range tt.spends {
+				prevOut := fetcher.FetchPrevOutput(tx.TxIn[i].PreviousOutPoint)
+				if err := s.sign(tx, i, sigHashes, prevOut); err != nil {
+					t.Fatalf("输入 %d (%s) 签名失败: %v", i, s.name, err)
+				}
+			}
+			verify(t, tx, fetcher)
+
+			got, want := est.Weight(), txsize.TxWeight(tx)
+			if got < want {
+				t.Fatalf("估算权重 %d 小于实际权重 %d", got, want)
+			}
+			if got-want > slack {
+				t.Fatalf("估算权重 %d 比实际权重 %d 多 %d，超过签名长度的浮动 %d", got, want, got-want, slack)
+			}
+		})
+	}
+}
+
+// verify 用脚本引擎执行每个输入，确保测量的是有效交易
+func verify(t *testing.T, tx *wire.MsgTx, fetcher txscript.PrevOutputFetcher) {
+	t.Helper()
+	sigHashes := txscript.NewTxSigHashes(tx, fetcher)
+	for i, txIn := range tx.TxIn {
+		prevOut := fetcher.FetchPrevOutput(txIn.PreviousOutPoint)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i, txscript.StandardVerifyFlags, nil, sigHashes,
+			prevOut.Value, fetcher)
+		if err != nil {
+			t.Fatalf("输入 %d: %v", i, err)
+		}
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("输入 %d 验证失败: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,536 @@
+// Package walletdb 把钱包状态保存到本地 JSON 文件：每个账户每条链已分配的地址索引、
+// 地址标签、已知的 UTXO 和交易。
+//
+// 地址只会从下一个未分配的索引开始分配，分配后立即落盘，所以同一个收款或找零地址
+// 不会被重复使用。每次修改都在文件锁 (<path>.lock) 内重新读取文件、修改后写回，
+// 多个进程同时使用同一个数据库也不会分配到同一个地址；写入时先写临时文件再替换原文件，
+// 中途崩溃不会损坏数据库。
+package walletdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go-btc/hdwallet"
+	"go-btc/internal/fileutil"
+)
+
+// SchemaVersion 当前的数据库格式版本
+const SchemaVersion = 1
+
+var (
+	// ErrUnsupportedVersion 数据库由更新版本的程序创建
+	ErrUnsupportedVersion = errors.New("不支持的钱包数据库版本")
+
+	// ErrWalletMismatch 数据库属于另一个钱包或另一个网络
+	ErrWalletMismatch = errors.New("钱包数据库与当前钱包不匹配")
+
+	// ErrIndexTaken 要保留的地址索引已被分配，通常是其他进程同时分配了地址
+	ErrIndexTaken = errors.New("地址索引已被分配")
+)
+
+// migrations 按版本升级旧格式的数据库，migrations[v] 把版本 v 升级到 v+1
+var migrations = map[int]func(raw map[string]json.RawMessage) error{}
+
+// Chain 一条链（收款链或找零链）的状态
+type Chain struct {
+	NextIndex uint32            `json:"next_index"` // 下一个未分配的地址索引
+	Labels    map[uint32]string `json:"labels,omitempty"`
+}
+
+// Account 一个账户的状态
+type Account struct {
+	Purpose  hdwallet.Purpose `json:"purpose"`
+	CoinType uint32           `json:"coin_type"`
+	Account  uint32           `json:"account"`
+	External Chain            `json:"external"`
+	Internal Chain            `json:"internal"`
+}
+
+// chain 返回指定的链
+func (a *Account) chain(change uint32) *Chain {
+	if change == hdwallet.ChainInternal {
+		return &a.Internal
+	}
+	return &a.External
+}
+
+// UTXO 钱包已知的一个输出
+type UTXO struct {
+	TxID     string        `json:"txid"`
+	Vout     uint32        `json:"vout"`
+	Value    int64         `json:"value"`
+	PkScript string        `json:"pk_script"` // 十六进制
+	Path     hdwallet.Path `json:"path"`
+	Height   int64         `json:"height,omitempty"`   // 确认高度，0 表示未确认
+	SpentBy  string        `json:"spent_by,omitempty"` // 花费它的交易，空表示未花费
+}
+
+// Outpoint 返回 txid:vout 形式的输出标识
+func (u *UTXO) Outpoint() string {
+	return fmt.Sprintf("%s:%d", u.TxID, u.Vout)
+}
+
+// Tx 钱包创建或收到的交易
+type Tx struct {
+	TxID   string `json:"txid"`
+	Raw    string `json:"raw,omitempty"` // 十六进制序列化交易
+	Fee    int64  `json:"fee,omitempty"`
+	Label  string `json:"label,omitempty"`
+	Time   int64  `json:"time"` // 记录时间 (unix 秒)
+	Height int64  `json:"height,omitempty"`
+
+	ReplacedBy string `json:"replaced_by,omitempty"` // 被 RBF 替换时为替换交易的 txid
+}
+
+// Lock 钱包创建的一个时间锁地址，花费时按这些字段重新构造脚本
+type Lock struct {
+	Address string        `json:"address"`
+	Kind    string        `json:"kind"`  // after (CLTV) 或 older (CSV)
+	Value   uint32        `json:"value"` // 区块高度、unix 时间或 BIP-68 编码的 nSequence
+	Type    string        `json:"type"`  // p2wsh 或 p2tr
+	Path    hdwallet.Path `json:"path"`  // 签名公钥的派生路径
+	Label   string        `json:"label,omitempty"`
+	Time    int64         `json:"time"` // 创建时间 (unix 秒)
+}
+
+// state 数据库文件的内容
+type state struct {
+	Version     int                 `json:"version"`
+	Network     string              `json:"network"`
+	Fingerprint string              `json:"fingerprint"`
+	Accounts    map[string]*Account `json:"accounts"`
+	UTXOs       map[string]*UTXO    `json:"utxos"`
+	Txs         map[string]*Tx      `json:"txs"`
+	Locks       map[string]*Lock    `json:"locks,omitempty"` // 地址 -> 时间锁
+}
+
+// DB 钱包数据库
+type DB struct {
+	path  string
+	mu    sync.Mutex
+	state *state
+}
+
+// Open 打开钱包数据库，文件不存在时创建。
+// network 和 fingerprint 用于确认数据库属于当前钱包，避免不同助记词或网络的数据混在一起。
+func Open(path, network string, fingerprint uint32) (*DB, error) {
+	db := &DB{path: path}
+	fp := hdwallet.FormatFingerprint(fingerprint)
+
+	// 持有文件锁，避免两个进程同时创建数据库
+	unlock, err := fileutil.Lock(db.lockPath())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		db.state = &state{
+			Version:     SchemaVersion,
+			Network:     network,
+			Fingerprint: fp,
+			Accounts:    make(map[string]*Account),
+			UTXOs:       make(map[string]*UTXO),
+			Txs:         make(map[string]*Tx),
+			Locks:       make(map[string]*Lock),
+		}
+		if err := db.save(); err != nil {
+			return nil, err
+		}
+		return db, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取钱包数据库失败: %w", err)
+	}
+
+	db.state, err = decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if db.state.Network != network || db.state.Fingerprint != fp {
+		return nil, fmt.Errorf("%w: 数据库为 %s/%s，当前为 %s/%s",
+			ErrWalletMismatch, db.state.Network, db.state.Fingerprint, network, fp)
+	}
+	return db, nil
+}
+
+// decode 解析数据库文件，必要时升级旧版本格式
+func decode(data []byte) (*state, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析钱包数据库失败: %w", err)
+	}
+	var version int
+	if err := json.Unmarshal(raw["version"], &version); err != nil {
+		return nil, fmt.Errorf("解析钱包数据库版本失败: %w", err)
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	for ; version < SchemaVersion; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return nil, fmt.Errorf("%w: 无法从版本 %d 升级", ErrUnsupportedVersion, version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("升级钱包数据库版本 %d 失败: %w", version, err)
+		}
+	}
+	raw["version"], _ = json.Marshal(SchemaVersion)
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("解析钱包数据库失败: %w", err)
+	}
+	if s.Accounts == nil {
+		s.Accounts = make(map[string]*Account)
+	}
+	if s.UTXOs == nil {
+		s.UTXOs = make(map[string]*UTXO)
+	}
+	if s.Txs == nil {
+		s.Txs = make(map[string]*Tx)
+	}
+	if s.Locks == nil {
+		s.Locks = make(map[string]*Lock)
+	}
+	return s, nil
+}
+
+// Path 返回数据库文件路径
+func (db *DB) Path() string {
+	return db.path
+}
+
+// account 返回账户状态，不存在时创建
+func (db *DB) account(purpose hdwallet.Purpose, coinType, account uint32) *Account {
+	key := fmt.Sprintf("%d'/%d'/%d'", uint32(purpose), coinType, account)
+	a, ok := db.state.Accounts[key]
+	if !ok {
+		a = &Account{Purpose: purpose, CoinType: coinType, Account: account}
+		db.state.Accounts[key] = a
+	}
+	return a
+}
+
+// NextIndex 返回链上下一个未分配的地址索引，不会分配该地址
+func (db *DB) NextIndex(purpose hdwallet.Purpose, coinType, account, change uint32) uint32 {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.account(purpose, coinType, account).chain(change).NextIndex
+}
+
+// Issue 分配链上下一个未使用的地址并立即保存，返回它的路径。
+// 同一个索引只会分配一次，收款地址和找零地址都不会被重复使用。
+func (db *DB) Issue(purpose hdwallet.Purpose, coinType, account, change uint32, label string) (hdwallet.Path, error) {
+	var path hdwallet.Path
+	err := db.update(func() error {
+		chain := db.account(purpose, coinType, account).chain(change)
+		path = hdwallet.Path{
+			Purpose:  purpose,
+			CoinType: coinType,
+			Account:  account,
+			Change:   change,
+			Index:    chain.NextIndex,
+		}
+		if err := path.Validate(); err != nil {
+			return err
+		}
+
+		chain.NextIndex++
+		if label != "" {
+			if chain.Labels == nil {
+				chain.Labels = make(map[uint32]string)
+			}
+			chain.Labels[path.Index] = label
+		}
+		return nil
+	})
+	if err != nil {
+		return hdwallet.Path{}, err
+	}
+	return path, nil
+}
+
+// Reserve 分配指定的地址并立即保存，path 必须正是链上下一个未分配的索引。
+// 用于先按 NextIndex 派生找零地址、交易确定后再分配的场景：余额不足或取消时不会浪费索引；
+// 期间其他进程已分配了这个索引时返回 ErrIndexTaken，调用方不能再使用该地址。
+func (db *DB) Reserve(path hdwallet.Path, label string) error {
+	if err := path.Validate(); err != nil {
+		return err
+	}
+	return db.update(func() error {
+		chain := db.account(path.Purpose, path.CoinType, path.Account).chain(path.Change)
+		if path.Index != chain.NextIndex {
+			return fmt.Errorf("%w: %s，下一个未分配的索引为 %d", ErrIndexTaken, path, chain.NextIndex)
+		}
+
+		chain.NextIndex++
+		if label != "" {
+			if chain.Labels == nil {
+				chain.Labels = make(map[uint32]string)
+			}
+			chain.Labels[path.Index] = label
+		}
+		return nil
+	})
+}
+
+// MarkUsed 记录在链上发现的已使用地址（例如扫描结果），
+// 之后分配的索引都会大于它
+func (db *DB) MarkUsed(paths ...hdwallet.Path) error {
+	return db.update(func() error {
+		for _, path := range paths {
+			chain := db.account(path.Purpose, path.CoinType, path.Account).chain(path.Change)
+			if path.Index >= chain.NextIndex {
+				chain.NextIndex = path.Index + 1
+			}
+		}
+		return nil
+	})
+}
+
+// Issued 返回链上已分配的地址路径，按索引排序
+func (db *DB) Issued(purpose hdwallet.Purpose, coinType, account, change uint32) []hdwallet.Path {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	chain := db.account(purpose, coinType, account).chain(change)
+	paths := make([]hdwallet.Path, chain.NextIndex)
+	for i := range paths {
+		paths[i] = hdwallet.Path{
+			Purpose:  purpose,
+			CoinType: coinType,
+			Account:  account,
+			Change:   change,
+			Index:    uint32(i),
+		}
+	}
+	return paths
+}
+
+// SetLabel 设置地址标签，label 为空时删除标签
+func (db *DB) SetLabel(path hdwallet.Path, label string) error {
+	return db.update(func() error {
+		chain := db.account(path.Purpose, path.CoinType, path.Account).chain(path.Change)
+		if label == "" {
+			delete(chain.Labels, path.Index)
+		} else {
+			if chain.Labels == nil {
+				chain.Labels = make(map[uint32]string)
+			}
+			chain.Labels[path.Index] = label
+		}
+		return nil
+	})
+}
+
+// Label 返回地址标签
+func (db *DB) Label(path hdwallet.Path) string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.account(path.Purpose, path.CoinType, path.Account).chain(path.Change).Labels[path.Index]
+}
+
+// AddUTXOs 记录 UTXO，已存在的输出只更新确认高度，不会改变花费状态
+func (db *DB) AddUTXOs(utxos ...UTXO) error {
+	return db.update(func() error {
+		for i := range utxos {
+			u := utxos[i]
+			if old, ok := db.state.UTXOs[u.Outpoint()]; ok {
+				old.Height = u.Height
+				continue
+			}
+			db.state.UTXOs[u.Outpoint()] = &u
+		}
+		return nil
+	})
+}
+
+// SpendUTXOs 把输出标记为已被 spendingTxID 花费
+func (db *DB) SpendUTXOs(spendingTxID string, outpoints ...string) error {
+	return db.update(func() error {
+		for _, op := range outpoints {
+			if u, ok := db.state.UTXOs[op]; ok {
+				u.SpentBy = spendingTxID
+			}
+		}
+		return nil
+	})
+}
+
+// UTXOs 返回未花费的输出，按 txid:vout 排序
+func (db *DB) UTXOs() []UTXO {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var utxos []UTXO
+	for _, u := range db.state.UTXOs {
+		if u.SpentBy == "" {
+			utxos = append(utxos, *u)
+		}
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		return utxos[i].Outpoint() < utxos[j].Outpoint()
+	})
+	return utxos
+}
+
+// AddTx 记录交易，已存在时覆盖；Time 为 0 时使用当前时间
+func (db *DB) AddTx(tx Tx) error {
+	return db.update(func() error {
+		if tx.Time == 0 {
+			tx.Time = time.Now().Unix()
+		}
+		db.state.Txs[tx.TxID] = &tx
+		return nil
+	})
+}
+
+// Tx 返回已记录的交易
+func (db *DB) Tx(txid string) (*Tx, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, ok := db.state.Txs[txid]
+	if !ok {
+		return nil, false
+	}
+	copied := *tx
+	return &copied, true
+}
+
+// Txs 返回所有交易，按记录时间排序
+func (db *DB) Txs() []Tx {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	txs := make([]Tx, 0, len(db.state.Txs))
+	for _, tx := range db.state.Txs {
+		txs = append(txs, *tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].Time != txs[j].Time {
+			return txs[i].Time < txs[j].Time
+		}
+		return txs[i].TxID < txs[j].TxID
+	})
+	return txs
+}
+
+// ReplaceTx 记录 oldTxID 已被 newTxID 替换 (RBF)：删除原交易创建的输出，
+// 原交易花费的输出恢复为未花费，再由调用方按替换交易重新标记
+func (db *DB) ReplaceTx(oldTxID, newTxID string) error {
+	return db.update(func() error {
+		if tx, ok := db.state.Txs[oldTxID]; ok {
+			tx.ReplacedBy = newTxID
+		}
+		for op, u := range db.state.UTXOs {
+			switch {
+			case u.TxID == oldTxID:
+				delete(db.state.UTXOs, op)
+			case u.SpentBy == oldTxID:
+				u.SpentBy = ""
+			}
+		}
+		return nil
+	})
+}
+
+// AddLock 记录时间锁地址；Time 为 0 时使用当前时间
+func (db *DB) AddLock(lock Lock) error {
+	return db.update(func() error {
+		if lock.Time == 0 {
+			lock.Time = time.Now().Unix()
+		}
+		db.state.Locks[lock.Address] = &lock
+		return nil
+	})
+}
+
+// Locks 返回所有时间锁地址，按创建时间排序
+func (db *DB) Locks() []Lock {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	locks := make([]Lock, 0, len(db.state.Locks))
+	for _, lock := range db.state.Locks {
+		locks = append(locks, *lock)
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].Time != locks[j].Time {
+			return locks[i].Time < locks[j].Time
+		}
+		return locks[i].Address < locks[j].Address
+	})
+	return locks
+}
+
+// update 在文件锁内重新读取数据库、执行 fn 并写回，其他进程同时运行时（例如 timelock address
+// 和 transaction 同时分配地址）不会读到同一个下一索引，也不会覆盖彼此的修改。
+// fn 返回错误或写入失败时不保存，内存状态恢复为文件中的内容。
+func (db *DB) update(fn func() error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	unlock, err := fileutil.Lock(db.lockPath())
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := db.reload(); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		db.reload()
+		return err
+	}
+	if err := db.save(); err != nil {
+		db.reload()
+		return err
+	}
+	return nil
+}
+
+// reload 从文件重新读取状态，调用方需持有锁
+func (db *DB) reload() error {
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return fmt.Errorf("读取钱包数据库失败: %w", err)
+	}
+	s, err := decode(data)
+	if err != nil {
+		return err
+	}
+	if s.Network != db.state.Network || s.Fingerprint != db.state.Fingerprint {
+		return fmt.Errorf("%w: 数据库文件已被替换为 %s/%s", ErrWalletMismatch, s.Network, s.Fingerprint)
+	}
+	db.state = s
+	return nil
+}
+
+// lockPath 返回跨进程文件锁的路径
+func (db *DB) lockPath() string {
+	return db.path + ".lock"
+}
+
+// save 序列化并原子地写入文件，调用方需持有锁
+func (db *DB) save() error {
+	data, err := json.MarshalIndent(db.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化钱包数据库失败: %w", err)
+	}
+	return fileutil.WriteAtomic(db.path, data)
+}